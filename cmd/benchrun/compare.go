@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strings"
+	"text/tabwriter"
+)
+
+// comparison is one benchmark's change in one unit between two runs
+type comparison struct {
+	name string
+	unit string
+	// base and head are the medians of each run's samples
+	base, head float64
+	// delta is the change of the median in percent
+	delta float64
+	p     float64
+	n1    int
+	n2    int
+	// significant is set when p is below alpha, so the change is unlikely to
+	// be noise
+	significant bool
+	// regression is set for a significant change for the worse larger than
+	// the threshold
+	regression bool
+}
+
+// higherIsBetter reports whether larger values of unit are improvements,
+// which is the case for rates such as MB/s and for ratios such as
+// compression_ratio
+func higherIsBetter(unit string) bool {
+	return strings.HasSuffix(unit, "/s") || strings.HasSuffix(unit, "_ratio")
+}
+
+// compareResults compares every benchmark and unit present in both runs.
+// threshold is the percentage change for the worse that counts as a
+// regression once it is significant at alpha.
+func compareResults(base, head *Results, threshold, alpha float64) []comparison {
+	baseByKey := make(map[string]*Benchmark, len(base.Benchmarks))
+	for _, b := range base.Benchmarks {
+		baseByKey[b.key()] = b
+	}
+
+	var comparisons []comparison
+	for _, h := range head.Benchmarks {
+		b := baseByKey[h.key()]
+		if b == nil {
+			continue
+		}
+		for unit, headSamples := range h.Samples {
+			baseSamples := b.Samples[unit]
+			if len(baseSamples) == 0 || len(headSamples) == 0 {
+				continue
+			}
+			c := comparison{
+				name: h.key(),
+				unit: unit,
+				base: median(baseSamples),
+				head: median(headSamples),
+				p:    mannWhitneyU(baseSamples, headSamples),
+				n1:   len(baseSamples),
+				n2:   len(headSamples),
+			}
+			switch {
+			case c.base != 0:
+				c.delta = (c.head - c.base) / math.Abs(c.base) * 100
+			case c.head != 0:
+				c.delta = math.Inf(1)
+			}
+			c.significant = c.p < alpha
+			worse := c.delta > threshold
+			if higherIsBetter(unit) {
+				worse = c.delta < -threshold
+			}
+			c.regression = c.significant && worse
+			comparisons = append(comparisons, c)
+		}
+	}
+
+	slices.SortStableFunc(comparisons, func(a, b comparison) int {
+		if rank := unitRank(a.unit) - unitRank(b.unit); rank != 0 {
+			return rank
+		}
+		return strings.Compare(a.unit, b.unit)
+	})
+	return comparisons
+}
+
+// unitRank puts the standard units ahead of custom metrics
+func unitRank(unit string) int {
+	switch unit {
+	case "ns/op":
+		return 0
+	case "B/op":
+		return 1
+	case "allocs/op":
+		return 2
+	}
+	return 3
+}
+
+// writeComparison prints comparisons as one table per unit, benchstat
+// style. Changes that are not significant show as ~.
+func writeComparison(w io.Writer, base, head *Results, comparisons []comparison, alpha float64) error {
+	for _, diff := range environmentDiff(base.Env, head.Env) {
+		fmt.Fprintf(w, "warning: %s\n", diff)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	unit := ""
+	for _, c := range comparisons {
+		if c.unit != unit {
+			if unit != "" {
+				fmt.Fprintln(tw, "\t\t\t\t\t")
+			}
+			unit = c.unit
+			fmt.Fprintf(tw, "%s\tbase\thead\tdelta\t\t\n", unit)
+		}
+		delta := "~"
+		if c.significant {
+			delta = fmt.Sprintf("%+.2f%%", c.delta)
+		}
+		note := ""
+		if c.regression {
+			note = "REGRESSION"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t(p=%.3f n=%d+%d)\t%s\n",
+			c.name, formatValue(c.base), formatValue(c.head), delta, c.p, c.n1, c.n2, note)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	// The smallest two-sided p-value the exact test can give is when the
+	// samples do not overlap at all
+	if slices.ContainsFunc(comparisons, func(c comparison) bool { return 2/binomial(c.n1+c.n2, c.n1) >= alpha }) {
+		fmt.Fprintf(w, "note: some benchmarks have too few samples to be significant at alpha=%g; use a higher -count\n", alpha)
+	}
+	return nil
+}
+
+func formatValue(v float64) string {
+	switch {
+	case v == 0:
+		return "0"
+	case math.Abs(v) >= 100:
+		return fmt.Sprintf("%.0f", v)
+	case math.Abs(v) >= 10:
+		return fmt.Sprintf("%.1f", v)
+	default:
+		return fmt.Sprintf("%.3g", v)
+	}
+}
+
+// environmentDiff describes the differences between two environments that
+// make a comparison between them unreliable
+func environmentDiff(base, head Environment) []string {
+	var diffs []string
+	check := func(what, a, b string) {
+		if a != b {
+			diffs = append(diffs, fmt.Sprintf("%s differs: %q vs %q", what, a, b))
+		}
+	}
+	check("Go version", base.GoVersion, head.GoVersion)
+	check("GOOS", base.GOOS, head.GOOS)
+	check("GOARCH", base.GOARCH, head.GOARCH)
+	check("CPU", base.CPU, head.CPU)
+	check("GOMAXPROCS", fmt.Sprint(base.GOMAXPROCS), fmt.Sprint(head.GOMAXPROCS))
+	if head.Dirty {
+		diffs = append(diffs, "head results were measured on a tree with uncommitted changes")
+	}
+	return diffs
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const benchOutput = `goos: linux
+goarch: amd64
+pkg: golang-content/httpclient
+cpu: Test CPU @ 3.00GHz
+BenchmarkMatrix/backend=nethttp/payload=small-8         	   50000	     31373 ns/op	        31.00 body_bytes/op	    4064 B/op	      36 allocs/op
+BenchmarkMatrix/backend=nethttp/payload=small-8         	   50000	     31001 ns/op	        31.00 body_bytes/op	    4064 B/op	      36 allocs/op
+BenchmarkThroughput-8   	    1000	   1000000 ns/op	 120.50 MB/s
+BenchmarkPool/MaxConnsPerHost-4-8   	    1000	   1000000 ns/op
+some log line from a benchmark
+PASS
+ok  	golang-content/httpclient	3.021s
+pkg: golang-content/jsoncompare
+BenchmarkMatrix/backend=nethttp/payload=small-8         	   50000	     12 ns/op
+`
+
+func TestParseBenchOutput(t *testing.T) {
+	results, err := parseBenchOutput(strings.NewReader(benchOutput))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	env := results.Env
+	if env.GOOS != "linux" || env.GOARCH != "amd64" || env.CPU != "Test CPU @ 3.00GHz" || env.GOMAXPROCS != 8 {
+		t.Errorf("environment = %+v", env)
+	}
+	if len(results.Benchmarks) != 4 {
+		t.Fatalf("got %d benchmarks, want 4", len(results.Benchmarks))
+	}
+
+	matrix := results.Benchmarks[0]
+	if matrix.key() != "golang-content/httpclient.BenchmarkMatrix/backend=nethttp/payload=small" {
+		t.Errorf("key = %q", matrix.key())
+	}
+	if got := matrix.Samples["ns/op"]; len(got) != 2 || got[0] != 31373 || got[1] != 31001 {
+		t.Errorf("ns/op samples = %v", got)
+	}
+	if got := matrix.Samples["body_bytes/op"]; len(got) != 2 || got[0] != 31 {
+		t.Errorf("body_bytes/op samples = %v", got)
+	}
+	if got := results.Benchmarks[1].Samples["MB/s"]; len(got) != 1 || got[0] != 120.5 {
+		t.Errorf("MB/s samples = %v", got)
+	}
+	if got := results.Benchmarks[2].Name; got != "BenchmarkPool/MaxConnsPerHost-4" {
+		t.Errorf("name with a number = %q", got)
+	}
+	// The same name in another package is another benchmark
+	if got := results.Benchmarks[3]; got.Package != "golang-content/jsoncompare" {
+		t.Errorf("third benchmark package = %q", got.Package)
+	}
+
+	// With GOMAXPROCS=1 there is no suffix to strip
+	results, err = parseBenchOutput(strings.NewReader("BenchmarkPool/MaxConnsPerHost-4 \t 10 \t 5 ns/op\nBenchmarkX \t 10 \t 5 ns/op\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := results.Benchmarks[0].Name; got != "BenchmarkPool/MaxConnsPerHost-4" || results.Env.GOMAXPROCS != 1 {
+		t.Errorf("name = %q, GOMAXPROCS = %d", got, results.Env.GOMAXPROCS)
+	}
+
+	if _, err := parseBenchOutput(strings.NewReader("PASS\n")); err == nil {
+		t.Error("output without benchmarks parsed without error")
+	}
+}
+
+// sampled returns results holding one benchmark with the given samples
+func sampled(unit string, samples ...float64) *Results {
+	return &Results{
+		Env: Environment{GoVersion: "go1.24", GOOS: "linux", GOARCH: "amd64", CPU: "cpu", GOMAXPROCS: 8},
+		Benchmarks: []*Benchmark{{
+			Package: "pkg",
+			Name:    "BenchmarkX",
+			Samples: map[string][]float64{unit: samples},
+		}},
+	}
+}
+
+func TestCompareResults(t *testing.T) {
+	tests := []struct {
+		name            string
+		base, head      *Results
+		wantSignificant bool
+		wantRegression  bool
+	}{
+		{
+			name:            "slower",
+			base:            sampled("ns/op", 100, 101, 102, 99, 100),
+			head:            sampled("ns/op", 120, 121, 119, 122, 120),
+			wantSignificant: true,
+			wantRegression:  true,
+		},
+		{
+			name:            "faster",
+			base:            sampled("ns/op", 120, 121, 119, 122, 120),
+			head:            sampled("ns/op", 100, 101, 102, 99, 100),
+			wantSignificant: true,
+		},
+		{
+			// Significant but below the 5% threshold
+			name:            "slightly slower",
+			base:            sampled("ns/op", 100, 101, 102, 99, 100.5),
+			head:            sampled("ns/op", 103, 104, 103.5, 104.5, 103.2),
+			wantSignificant: true,
+		},
+		{
+			// A large change in the medians that the samples cannot support
+			name: "noisy",
+			base: sampled("ns/op", 100, 300, 100, 300, 110),
+			head: sampled("ns/op", 120, 310, 90, 290, 130),
+		},
+		{
+			name: "too few samples",
+			base: sampled("ns/op", 100, 101),
+			head: sampled("ns/op", 200, 201),
+		},
+		{
+			name:            "lower throughput",
+			base:            sampled("MB/s", 100, 101, 102, 99, 100),
+			head:            sampled("MB/s", 80, 81, 79, 82, 80),
+			wantSignificant: true,
+			wantRegression:  true,
+		},
+		{
+			name:            "higher throughput",
+			base:            sampled("MB/s", 80, 81, 79, 82, 80),
+			head:            sampled("MB/s", 100, 101, 102, 99, 100),
+			wantSignificant: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			comparisons := compareResults(tt.base, tt.head, 5, 0.05)
+			if len(comparisons) != 1 {
+				t.Fatalf("got %d comparisons, want 1", len(comparisons))
+			}
+			c := comparisons[0]
+			if c.significant != tt.wantSignificant || c.regression != tt.wantRegression {
+				t.Errorf("significant = %v, regression = %v (delta %.1f%%, p %.3f); want %v, %v",
+					c.significant, c.regression, c.delta, c.p, tt.wantSignificant, tt.wantRegression)
+			}
+		})
+	}
+}
+
+func TestCompareCommand(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, results *Results) string {
+		path := filepath.Join(dir, name)
+		if err := saveResults(path, results); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	base := write("base.json", sampled("ns/op", 100, 101, 102, 99, 100))
+	slower := write("slower.json", sampled("ns/op", 120, 121, 119, 122, 120))
+	otherCPU := sampled("ns/op", 100, 102, 101, 99, 100)
+	otherCPU.Env.CPU = "other cpu"
+	same := write("same.json", otherCPU)
+
+	var out bytes.Buffer
+	err := run([]string{"compare", base, slower}, &out, &out)
+	if !errors.Is(err, errRegression) {
+		t.Errorf("regression: err = %v, want errRegression", err)
+	}
+	if !strings.Contains(out.String(), "REGRESSION") || !strings.Contains(out.String(), "+20.00%") {
+		t.Errorf("regression output:\n%s", out.String())
+	}
+
+	// A higher threshold lets the same change through
+	out.Reset()
+	if err := run([]string{"compare", "-threshold", "25", base, slower}, &out, &out); err != nil {
+		t.Errorf("threshold 25: err = %v", err)
+	}
+
+	out.Reset()
+	if err := run([]string{"compare", base, same}, &out, &out); err != nil {
+		t.Errorf("no change: err = %v", err)
+	}
+	if !strings.Contains(out.String(), "warning: CPU differs") || !strings.Contains(out.String(), "~") {
+		t.Errorf("no change output:\n%s", out.String())
+	}
+
+	if err := run([]string{"compare", base, filepath.Join(dir, "missing.json")}, &out, &out); err == nil || errors.Is(err, errRegression) {
+		t.Errorf("missing file: err = %v, want a non-regression error", err)
+	}
+}
+
+func TestRunInput(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "bench.txt")
+	if err := os.WriteFile(input, []byte(benchOutput), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	output := filepath.Join(dir, "results.json")
+
+	var out bytes.Buffer
+	if err := run([]string{"run", "-input", input, "-o", output}, &out, &out); err != nil {
+		t.Fatal(err)
+	}
+	results, err := loadResults(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results.Benchmarks) != 4 || results.Env.GoVersion == "" || results.Env.Date.IsZero() {
+		t.Errorf("saved results = %+v", results)
+	}
+
+	// Comparing a run with itself finds nothing
+	if err := run([]string{"run", "-input", input, "-baseline", output}, &out, &out); err != nil {
+		t.Errorf("comparison with itself: err = %v", err)
+	}
+}
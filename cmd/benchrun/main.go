@@ -0,0 +1,204 @@
+// Benchrun runs the repository's benchmarks, saves the results with the
+// environment they were measured in, and compares them against a saved
+// baseline. It can also render results as a self-contained HTML report
+// with charts and tables comparing backends, codecs and APIs.
+//
+// Comparisons follow benchstat: each benchmark's median is compared and a
+// Mann-Whitney U test decides whether the change is significant. Benchrun
+// exits with status 1 when a significant change for the worse exceeds the
+// threshold, so it can gate CI.
+//
+//	benchrun run -count 10 -o base.json ./httpclient/...
+//	benchrun run -count 10 -baseline base.json -o head.json ./httpclient/...
+//	benchrun compare -threshold 10 base.json head.json
+//	benchrun report -o report.html head.json
+//	go test -bench . -count 10 ./... | tee out.txt; benchrun run -input out.txt -o head.json
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+// errRegression is returned when a comparison finds a regression
+var errRegression = errors.New("benchmarks regressed")
+
+func main() {
+	err := run(os.Args[1:], os.Stdout, os.Stderr)
+	switch {
+	case err == nil:
+	case errors.Is(err, errRegression):
+		fmt.Fprintln(os.Stderr, "benchrun:", err)
+		os.Exit(1)
+	default:
+		fmt.Fprintln(os.Stderr, "benchrun:", err)
+		os.Exit(2)
+	}
+}
+
+func run(args []string, stdout, stderr io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: benchrun run|compare|report [flags]")
+	}
+	switch args[0] {
+	case "run":
+		return runCommand(args[1:], stdout, stderr)
+	case "compare":
+		return compareCommand(args[1:], stdout, stderr)
+	case "report":
+		return reportCommand(args[1:], stdout, stderr)
+	default:
+		return fmt.Errorf("unknown command %q; want run, compare or report", args[0])
+	}
+}
+
+// comparisonFlags are the flags shared by run and compare
+type comparisonFlags struct {
+	threshold *float64
+	alpha     *float64
+}
+
+func addComparisonFlags(flags *flag.FlagSet) comparisonFlags {
+	return comparisonFlags{
+		threshold: flags.Float64("threshold", 5, "percentage change for the worse that counts as a regression"),
+		alpha:     flags.Float64("alpha", 0.05, "significance level of the Mann-Whitney U test"),
+	}
+}
+
+func runCommand(args []string, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet("benchrun run", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	bench := flags.String("bench", ".", "benchmarks to run, as for go test -bench")
+	count := flags.Int("count", 10, "samples per benchmark")
+	benchtime := flags.String("benchtime", "", "go test -benchtime for each sample")
+	output := flags.String("o", "", "file to save the results to")
+	input := flags.String("input", "", "parse saved go test -bench output instead of running benchmarks")
+	baseline := flags.String("baseline", "", "results file to compare against")
+	cmp := addComparisonFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	pkgs := flags.Args()
+	if len(pkgs) == 0 {
+		pkgs = []string{"./..."}
+	}
+
+	var results *Results
+	var err error
+	if *input != "" {
+		f, err := os.Open(*input)
+		if err != nil {
+			return err
+		}
+		results, err = parseBenchOutput(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("error parsing %s: %w", *input, err)
+		}
+		fillEnvironment(&results.Env)
+	} else {
+		if *count < 1 {
+			return fmt.Errorf("-count must be at least 1")
+		}
+		results, err = runBenchmarks(pkgs, *bench, *benchtime, *count, stderr)
+		if err != nil {
+			return err
+		}
+	}
+
+	if *output != "" {
+		if err := saveResults(*output, results); err != nil {
+			return fmt.Errorf("error saving results: %w", err)
+		}
+	}
+	if *baseline == "" {
+		return nil
+	}
+	base, err := loadResults(*baseline)
+	if err != nil {
+		return err
+	}
+	return compare(stdout, base, results, cmp)
+}
+
+func compareCommand(args []string, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet("benchrun compare", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	cmp := addComparisonFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 2 {
+		return fmt.Errorf("usage: benchrun compare [flags] base.json head.json")
+	}
+
+	base, err := loadResults(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	head, err := loadResults(flags.Arg(1))
+	if err != nil {
+		return err
+	}
+	return compare(stdout, base, head, cmp)
+}
+
+func reportCommand(args []string, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet("benchrun report", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	output := flags.String("o", "", "file to write the report to; standard output if empty")
+	title := flags.String("title", "Benchmark report", "report title")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		return fmt.Errorf("usage: benchrun report [flags] results.json...")
+	}
+
+	var runs []*Results
+	for _, path := range flags.Args() {
+		results, err := loadResults(path)
+		if err != nil {
+			return err
+		}
+		runs = append(runs, results)
+	}
+	data := buildReport(*title, flags.Args(), runs)
+
+	if *output == "" {
+		return writeReport(stdout, data)
+	}
+	f, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	if err := writeReport(f, data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func compare(w io.Writer, base, head *Results, cmp comparisonFlags) error {
+	comparisons := compareResults(base, head, *cmp.threshold, *cmp.alpha)
+	if len(comparisons) == 0 {
+		return fmt.Errorf("no benchmarks in common")
+	}
+	if err := writeComparison(w, base, head, comparisons, *cmp.alpha); err != nil {
+		return err
+	}
+
+	regressions := 0
+	for _, c := range comparisons {
+		if c.regression {
+			regressions++
+		}
+	}
+	if regressions > 0 {
+		return fmt.Errorf("%d of %d %w by more than %g%%", regressions, len(comparisons), errRegression, *cmp.threshold)
+	}
+	return nil
+}
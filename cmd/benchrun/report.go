@@ -0,0 +1,363 @@
+package main
+
+import (
+	_ "embed"
+	"fmt"
+	"html/template"
+	"io"
+	"math"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"golang-content/httpclient"
+)
+
+//go:embed report.html.tmpl
+var reportTemplateText string
+
+var reportTemplate = template.Must(template.New("report").Parse(reportTemplateText))
+
+// comparedDimensions are the dimensions that get a side-by-side table when
+// a benchmark group has more than one value for them
+var comparedDimensions = []string{"backend", "codec", "api"}
+
+// dimension is one named parameter of a benchmark, such as backend=fasthttp
+type dimension struct {
+	key, value string
+}
+
+// numberedSegment matches sub-benchmark names such as Parallelism-8
+var numberedSegment = regexp.MustCompile(`^([A-Za-z]+)-(\d+)$`)
+
+// parseName splits a benchmark name into its group, the part shared by the
+// benchmarks that are charted together, and its dimensions. It understands
+// the naming schemes of this repository's benchmarks:
+//
+//	BenchmarkMatrix/backend=fasthttp/payload=small    key=value segments
+//	BenchmarkPoolSizing/net/http/MaxConnsPerHost-4   backend names and Name-N segments
+//	BenchmarkJsonIter_SmallFile                       Benchmark<codec>_<payload>
+//
+// Any other segment becomes a variant.
+func parseName(name string) (group string, dims []dimension) {
+	segments := strings.Split(name, "/")
+	group = segments[0]
+	if len(segments) == 1 {
+		if codec, payload, ok := strings.Cut(strings.TrimPrefix(group, "Benchmark"), "_"); ok {
+			return "Benchmark<codec>_" + payload, []dimension{{"codec", codec}, {"payload", payload}}
+		}
+		return group, nil
+	}
+
+	variants := 0
+	for i := 1; i < len(segments); i++ {
+		segment := segments[i]
+		if i+1 < len(segments) && segment+"/"+segments[i+1] == string(httpclient.BackendStandard) {
+			segment += "/" + segments[i+1]
+			i++
+		}
+		if key, value, ok := strings.Cut(segment, "="); ok {
+			dims = append(dims, dimension{key, value})
+			continue
+		}
+		if m := numberedSegment.FindStringSubmatch(segment); m != nil {
+			dims = append(dims, dimension{m[1], m[2]})
+			continue
+		}
+		switch httpclient.Backend(segment) {
+		case httpclient.BackendStandard, httpclient.BackendFastHTTP:
+			dims = append(dims, dimension{"backend", segment})
+			continue
+		}
+		variants++
+		key := "variant"
+		if variants > 1 {
+			key = fmt.Sprintf("variant%d", variants)
+		}
+		dims = append(dims, dimension{key, segment})
+	}
+	return group, dims
+}
+
+// formatDimensions joins dims back into a label, leaving out skip
+func formatDimensions(dims []dimension, skip string) string {
+	var parts []string
+	for _, d := range dims {
+		if d.key != skip {
+			parts = append(parts, d.key+"="+d.value)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// nonAnchor matches the characters left out of element ids
+var nonAnchor = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+
+func anchor(s string) string {
+	return strings.Trim(nonAnchor.ReplaceAllString(s, "-"), "-")
+}
+
+// reportData is what the report template renders
+type reportData struct {
+	Title     string
+	Generated time.Time
+	Runs      []reportRun
+	Packages  []*reportPackage
+}
+
+type reportRun struct {
+	Source string
+	Env    Environment
+}
+
+func (r reportRun) ShortCommit() string {
+	if len(r.Env.Commit) > 12 {
+		return r.Env.Commit[:12]
+	}
+	return r.Env.Commit
+}
+
+type reportPackage struct {
+	Name   string
+	Groups []*reportGroup
+}
+
+// reportGroup is the benchmarks sharing a top-level name, charted together
+type reportGroup struct {
+	Name string
+	// Anchor is the group's element id in the page
+	Anchor  string
+	Charts  []chart
+	Tables  []comparisonTable
+	members []groupMember
+}
+
+type groupMember struct {
+	label string
+	dims  []dimension
+	bench *Benchmark
+}
+
+// chart is a horizontal bar chart of one unit across a group, with bars at
+// the median and whiskers spanning the samples
+type chart struct {
+	Unit       string
+	Width      int
+	Height     int
+	LabelWidth int
+	Bars       []chartBar
+}
+
+type chartBar struct {
+	Label string
+	Value string
+	Y     int
+	// Length, Min and Max are in pixels from the start of the bar area
+	Length, Min, Max float64
+}
+
+// comparisonTable shows one unit side by side across the values of a
+// dimension, relative to the first value
+type comparisonTable struct {
+	Dimension string
+	Unit      string
+	Columns   []string
+	Rows      []comparisonRow
+}
+
+type comparisonRow struct {
+	Label string
+	Cells []comparisonCell
+}
+
+type comparisonCell struct {
+	Value string
+	Ratio string
+	// Class is better, worse or empty when within 5% of the first column
+	Class string
+}
+
+const (
+	chartBarHeight = 18
+	chartBarArea   = 480
+	chartCharWidth = 7
+)
+
+// buildReport lays out the benchmarks of every run. A benchmark present in
+// more than one run is shown with the samples of the last.
+func buildReport(title string, sources []string, runs []*Results) *reportData {
+	data := &reportData{Title: title, Generated: time.Now().UTC()}
+	byKey := make(map[string]*Benchmark)
+	var order []string
+	for i, run := range runs {
+		data.Runs = append(data.Runs, reportRun{Source: sources[i], Env: run.Env})
+		for _, b := range run.Benchmarks {
+			if _, ok := byKey[b.key()]; !ok {
+				order = append(order, b.key())
+			}
+			byKey[b.key()] = b
+		}
+	}
+
+	packages := make(map[string]*reportPackage)
+	groups := make(map[string]*reportGroup)
+	for _, key := range order {
+		b := byKey[key]
+		pkg := packages[b.Package]
+		if pkg == nil {
+			pkg = &reportPackage{Name: b.Package}
+			packages[b.Package] = pkg
+			data.Packages = append(data.Packages, pkg)
+		}
+		name, dims := parseName(b.Name)
+		group := groups[b.Package+"."+name]
+		if group == nil {
+			group = &reportGroup{Name: name, Anchor: anchor(b.Package + "." + name)}
+			groups[b.Package+"."+name] = group
+			pkg.Groups = append(pkg.Groups, group)
+		}
+		label := formatDimensions(dims, "")
+		if label == "" {
+			label = b.Name
+		}
+		group.members = append(group.members, groupMember{label: label, dims: dims, bench: b})
+	}
+
+	for _, pkg := range data.Packages {
+		for _, group := range pkg.Groups {
+			for _, unit := range groupUnits(group) {
+				group.Charts = append(group.Charts, buildChart(group, unit))
+			}
+			for _, dim := range comparedDimensions {
+				for _, unit := range groupUnits(group) {
+					if table, ok := buildComparison(group, dim, unit); ok {
+						group.Tables = append(group.Tables, table)
+					}
+				}
+			}
+		}
+	}
+	return data
+}
+
+// groupUnits returns the units reported by any member of group, standard
+// units first
+func groupUnits(group *reportGroup) []string {
+	var units []string
+	for _, m := range group.members {
+		for unit := range m.bench.Samples {
+			if !slices.Contains(units, unit) {
+				units = append(units, unit)
+			}
+		}
+	}
+	slices.SortFunc(units, func(a, b string) int {
+		if rank := unitRank(a) - unitRank(b); rank != 0 {
+			return rank
+		}
+		return strings.Compare(a, b)
+	})
+	return units
+}
+
+func buildChart(group *reportGroup, unit string) chart {
+	c := chart{Unit: unit}
+	var scale float64
+	for _, m := range group.members {
+		for _, v := range m.bench.Samples[unit] {
+			scale = math.Max(scale, v)
+		}
+		c.LabelWidth = max(c.LabelWidth, len(m.label)*chartCharWidth+12)
+	}
+	if scale == 0 {
+		scale = 1
+	}
+	pixels := func(v float64) float64 {
+		return math.Round(v/scale*chartBarArea*10) / 10
+	}
+
+	for _, m := range group.members {
+		samples := m.bench.Samples[unit]
+		if len(samples) == 0 {
+			continue
+		}
+		c.Bars = append(c.Bars, chartBar{
+			Label:  m.label,
+			Value:  formatValue(median(samples)),
+			Y:      len(c.Bars) * chartBarHeight,
+			Length: pixels(median(samples)),
+			Min:    pixels(slices.Min(samples)),
+			Max:    pixels(slices.Max(samples)),
+		})
+	}
+	// Room for the value printed after the longest bar
+	c.Width = c.LabelWidth + chartBarArea + 80
+	c.Height = len(c.Bars) * chartBarHeight
+	return c
+}
+
+// buildComparison tabulates unit across the values of dim, one row per
+// combination of the other dimensions. It reports false when the group
+// does not vary dim.
+func buildComparison(group *reportGroup, dim, unit string) (comparisonTable, bool) {
+	table := comparisonTable{Dimension: dim, Unit: unit}
+	rows := make(map[string]int)
+	medians := make(map[string]map[string]float64)
+	for _, m := range group.members {
+		i := slices.IndexFunc(m.dims, func(d dimension) bool { return d.key == dim })
+		samples := m.bench.Samples[unit]
+		if i < 0 || len(samples) == 0 {
+			continue
+		}
+		value := m.dims[i].value
+		if !slices.Contains(table.Columns, value) {
+			table.Columns = append(table.Columns, value)
+		}
+		label := formatDimensions(m.dims, dim)
+		if _, ok := rows[label]; !ok {
+			rows[label] = len(table.Rows)
+			table.Rows = append(table.Rows, comparisonRow{Label: label})
+			medians[label] = make(map[string]float64)
+		}
+		medians[label][value] = median(samples)
+	}
+	if len(table.Columns) < 2 {
+		return table, false
+	}
+
+	for i := range table.Rows {
+		row := &table.Rows[i]
+		values := medians[row.Label]
+		base, hasBase := values[table.Columns[0]]
+		for _, column := range table.Columns {
+			v, ok := values[column]
+			if !ok {
+				row.Cells = append(row.Cells, comparisonCell{Value: "–"})
+				continue
+			}
+			cell := comparisonCell{Value: formatValue(v)}
+			if hasBase && base != 0 {
+				ratio := v / base
+				cell.Ratio = fmt.Sprintf("%.2f×", ratio)
+				better := ratio < 1
+				if higherIsBetter(unit) {
+					better = ratio > 1
+				}
+				switch {
+				case math.Abs(ratio-1) < 0.05:
+				case better:
+					cell.Class = "better"
+				default:
+					cell.Class = "worse"
+				}
+			}
+			row.Cells = append(row.Cells, cell)
+		}
+	}
+	return table, true
+}
+
+func writeReport(w io.Writer, data *reportData) error {
+	return reportTemplate.Execute(w, data)
+}
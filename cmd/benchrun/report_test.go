@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseName(t *testing.T) {
+	tests := []struct {
+		name      string
+		wantGroup string
+		wantDims  []dimension
+	}{
+		{
+			"BenchmarkMatrix/backend=fasthttp/payload=small/conc=serial",
+			"BenchmarkMatrix",
+			[]dimension{{"backend", "fasthttp"}, {"payload", "small"}, {"conc", "serial"}},
+		},
+		{
+			"BenchmarkPoolSizing/net/http/MaxConnsPerHost-4",
+			"BenchmarkPoolSizing",
+			[]dimension{{"backend", "net/http"}, {"MaxConnsPerHost", "4"}},
+		},
+		{
+			"BenchmarkUnixSocketVsTCP/fasthttp/UnixSocket/Parallelism-64",
+			"BenchmarkUnixSocketVsTCP",
+			[]dimension{{"backend", "fasthttp"}, {"variant", "UnixSocket"}, {"Parallelism", "64"}},
+		},
+		{
+			"BenchmarkHedgedTailLatency/net/http/Hedge-Delay-2ms",
+			"BenchmarkHedgedTailLatency",
+			[]dimension{{"backend", "net/http"}, {"variant", "Hedge-Delay-2ms"}},
+		},
+		{
+			"BenchmarkJsonIter_SmallFile",
+			"Benchmark<codec>_SmallFile",
+			[]dimension{{"codec", "JsonIter"}, {"payload", "SmallFile"}},
+		},
+		{"BenchmarkPlain", "BenchmarkPlain", nil},
+	}
+	for _, tt := range tests {
+		group, dims := parseName(tt.name)
+		if group != tt.wantGroup || !reflect.DeepEqual(dims, tt.wantDims) {
+			t.Errorf("parseName(%q) = %q, %v; want %q, %v", tt.name, group, dims, tt.wantGroup, tt.wantDims)
+		}
+	}
+}
+
+// reportResults holds benchmarks from both of the repository's packages
+func reportResults() *Results {
+	bench := func(pkg, name string, samples map[string][]float64) *Benchmark {
+		return &Benchmark{Package: pkg, Name: name, Samples: samples}
+	}
+	return &Results{
+		Env: Environment{GoVersion: "go1.24", GOOS: "linux", GOARCH: "amd64", CPU: "cpu", GOMAXPROCS: 8, Commit: "0123456789abcdef"},
+		Benchmarks: []*Benchmark{
+			bench("golang-content/httpclient", "BenchmarkMatrix/backend=nethttp/encoding=gzip", map[string][]float64{
+				"ns/op": {100, 110, 90}, "allocs/op": {10, 10, 10}, "compression_ratio": {12, 12, 12},
+			}),
+			bench("golang-content/httpclient", "BenchmarkMatrix/backend=fasthttp/encoding=gzip", map[string][]float64{
+				"ns/op": {50, 55, 45}, "allocs/op": {12, 12, 12}, "compression_ratio": {12, 12, 12},
+			}),
+			bench("golang-content/httpclient", "BenchmarkMatrix/backend=nethttp/encoding=identity", map[string][]float64{
+				"ns/op": {80, 80, 80}, "allocs/op": {10, 10, 10},
+			}),
+			bench("golang-content/jsoncompare", "BenchmarkStandardJSON_SmallFile", map[string][]float64{"ns/op": {200}}),
+			bench("golang-content/jsoncompare", "BenchmarkJsonIter_SmallFile", map[string][]float64{"ns/op": {100}}),
+		},
+	}
+}
+
+func TestBuildReport(t *testing.T) {
+	data := buildReport("report", []string{"results.json"}, []*Results{reportResults()})
+	if len(data.Packages) != 2 {
+		t.Fatalf("got %d packages, want 2", len(data.Packages))
+	}
+
+	matrix := data.Packages[0].Groups[0]
+	var units []string
+	for _, c := range matrix.Charts {
+		units = append(units, c.Unit)
+	}
+	if want := []string{"ns/op", "allocs/op", "compression_ratio"}; !reflect.DeepEqual(units, want) {
+		t.Errorf("chart units = %v, want %v", units, want)
+	}
+	ns := matrix.Charts[0]
+	if len(ns.Bars) != 3 {
+		t.Fatalf("got %d ns/op bars, want 3", len(ns.Bars))
+	}
+	// The largest sample spans the whole bar area
+	if bar := ns.Bars[0]; bar.Value != "100" || bar.Max != chartBarArea || bar.Length >= bar.Max || bar.Min >= bar.Length {
+		t.Errorf("nethttp bar = %+v", bar)
+	}
+	// Only the gzip cases report a compression ratio
+	if n := len(matrix.Charts[2].Bars); n != 2 {
+		t.Errorf("got %d compression_ratio bars, want 2", n)
+	}
+
+	if len(matrix.Tables) != 3 {
+		t.Fatalf("got %d tables, want one per unit", len(matrix.Tables))
+	}
+	table := matrix.Tables[0]
+	if table.Dimension != "backend" || !reflect.DeepEqual(table.Columns, []string{"nethttp", "fasthttp"}) {
+		t.Errorf("table = %+v", table)
+	}
+	want := []comparisonRow{
+		{Label: "encoding=gzip", Cells: []comparisonCell{{"100", "1.00×", ""}, {"50.0", "0.50×", "better"}}},
+		{Label: "encoding=identity", Cells: []comparisonCell{{"80.0", "1.00×", ""}, {"–", "", ""}}},
+	}
+	if !reflect.DeepEqual(table.Rows, want) {
+		t.Errorf("ns/op rows = %+v, want %+v", table.Rows, want)
+	}
+	if cell := matrix.Tables[1].Rows[0].Cells[1]; cell.Class != "worse" {
+		t.Errorf("more allocations: class = %q, want worse", cell.Class)
+	}
+
+	codecs := data.Packages[1].Groups[0]
+	if codecs.Name != "Benchmark<codec>_SmallFile" || len(codecs.Tables) != 1 || codecs.Tables[0].Dimension != "codec" {
+		t.Errorf("jsoncompare group = %+v", codecs)
+	}
+}
+
+func TestBuildReportAPIs(t *testing.T) {
+	results := &Results{Benchmarks: []*Benchmark{
+		{Package: "golang-content/httpclient", Name: "BenchmarkBufferedAPI/backend=nethttp/payload=small/api=Do", Samples: map[string][]float64{"allocs/op": {60}}},
+		{Package: "golang-content/httpclient", Name: "BenchmarkBufferedAPI/backend=nethttp/payload=small/api=DoBuffer", Samples: map[string][]float64{"allocs/op": {30}}},
+	}}
+	data := buildReport("report", []string{"results.json"}, []*Results{results})
+
+	group := data.Packages[0].Groups[0]
+	if len(group.Tables) != 1 || group.Tables[0].Dimension != "api" {
+		t.Fatalf("tables = %+v, want one comparing APIs", group.Tables)
+	}
+	if columns := group.Tables[0].Columns; !reflect.DeepEqual(columns, []string{"Do", "DoBuffer"}) {
+		t.Errorf("columns = %v", columns)
+	}
+}
+
+func TestReportCommand(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "results.json")
+	if err := saveResults(input, reportResults()); err != nil {
+		t.Fatal(err)
+	}
+	output := filepath.Join(dir, "report.html")
+
+	var out bytes.Buffer
+	if err := run([]string{"report", "-title", "Nightly", "-o", output, input}, &out, &out); err != nil {
+		t.Fatal(err)
+	}
+	html, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"<title>Nightly</title>",
+		"0123456789ab",
+		`<h3 id="golang-content-httpclient.BenchmarkMatrix">`,
+		"Benchmark&lt;codec&gt;_SmallFile",
+		"<h4>ns/op by backend</h4>",
+		"<h4>compression_ratio</h4>",
+		"<svg",
+	} {
+		if !strings.Contains(string(html), want) {
+			t.Errorf("report does not contain %q", want)
+		}
+	}
+	// Self-contained: nothing is fetched from elsewhere
+	if strings.Contains(string(html), "src=") || strings.Contains(string(html), "<link") {
+		t.Error("report references external resources")
+	}
+}
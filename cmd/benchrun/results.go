@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Results is one run of benchmarks as stored on disk
+type Results struct {
+	Env        Environment  `json:"env"`
+	Benchmarks []*Benchmark `json:"benchmarks"`
+}
+
+// Environment records what the results were measured on, so that
+// comparisons across machines or toolchains can be flagged
+type Environment struct {
+	GoVersion  string    `json:"go_version"`
+	GOOS       string    `json:"goos"`
+	GOARCH     string    `json:"goarch"`
+	CPU        string    `json:"cpu"`
+	GOMAXPROCS int       `json:"gomaxprocs"`
+	Commit     string    `json:"commit,omitempty"`
+	Dirty      bool      `json:"dirty,omitempty"`
+	Date       time.Time `json:"date"`
+}
+
+// Benchmark holds every sample of one benchmark, per unit
+type Benchmark struct {
+	Package string               `json:"package"`
+	Name    string               `json:"name"`
+	Samples map[string][]float64 `json:"samples"`
+}
+
+// key identifies a benchmark across runs
+func (b *Benchmark) key() string {
+	return b.Package + "." + b.Name
+}
+
+// procsSuffix is the -GOMAXPROCS suffix go test appends to benchmark names
+// when GOMAXPROCS is above 1
+var procsSuffix = regexp.MustCompile(`-\d+$`)
+
+// benchLine is one parsed result line of go test -bench output
+type benchLine struct {
+	pkg    string
+	name   string
+	fields []string
+}
+
+// parseBenchOutput reads go test -bench output. Samples of the same
+// benchmark are merged in order, and the -GOMAXPROCS suffix is moved from
+// the names to the environment.
+func parseBenchOutput(r io.Reader) (*Results, error) {
+	results := &Results{Env: Environment{GOMAXPROCS: 1}}
+	var lines []benchLine
+	pkg := ""
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64<<10), 1<<20)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if key, value, ok := strings.Cut(line, ": "); ok {
+			switch key {
+			case "pkg":
+				pkg = value
+			case "cpu":
+				results.Env.CPU = value
+			case "goos":
+				results.Env.GOOS = value
+			case "goarch":
+				results.Env.GOARCH = value
+			}
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) < 4 || !strings.HasPrefix(fields[0], "Benchmark") || len(fields)%2 != 0 {
+			continue
+		}
+		if _, err := strconv.Atoi(fields[1]); err != nil {
+			continue
+		}
+		lines = append(lines, benchLine{pkg: pkg, name: fields[0], fields: fields[2:]})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(lines) == 0 {
+		return nil, fmt.Errorf("no benchmark results found")
+	}
+
+	// go test only adds the suffix when GOMAXPROCS is above 1, so a name
+	// such as Parallelism-4 is only a suffix when every name shares it
+	suffix := procsSuffix.FindString(lines[0].name)
+	for _, l := range lines {
+		if suffix == "" || !strings.HasSuffix(l.name, suffix) {
+			suffix = ""
+			break
+		}
+	}
+	if suffix != "" {
+		results.Env.GOMAXPROCS, _ = strconv.Atoi(suffix[1:])
+	}
+
+	byKey := make(map[string]*Benchmark)
+	for _, l := range lines {
+		name := strings.TrimSuffix(l.name, suffix)
+		bench := byKey[l.pkg+"."+name]
+		if bench == nil {
+			bench = &Benchmark{Package: l.pkg, Name: name, Samples: make(map[string][]float64)}
+			byKey[bench.key()] = bench
+			results.Benchmarks = append(results.Benchmarks, bench)
+		}
+		for i := 0; i < len(l.fields); i += 2 {
+			value, err := strconv.ParseFloat(l.fields[i], 64)
+			if err != nil {
+				return nil, fmt.Errorf("bad value %q for %s", l.fields[i], l.name)
+			}
+			bench.Samples[l.fields[i+1]] = append(bench.Samples[l.fields[i+1]], value)
+		}
+	}
+	return results, nil
+}
+
+// runBenchmarks runs go test -bench on pkgs and parses its output. The raw
+// output is copied to log as it arrives.
+func runBenchmarks(pkgs []string, bench, benchtime string, count int, log io.Writer) (*Results, error) {
+	args := []string{"test", "-run", "^$", "-bench", bench, "-benchmem", "-count", strconv.Itoa(count)}
+	if benchtime != "" {
+		args = append(args, "-benchtime", benchtime)
+	}
+	args = append(args, pkgs...)
+
+	var out bytes.Buffer
+	cmd := exec.Command("go", args...)
+	cmd.Stdout = io.MultiWriter(&out, log)
+	cmd.Stderr = log
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("go %s: %w", strings.Join(args, " "), err)
+	}
+
+	results, err := parseBenchOutput(&out)
+	if err != nil {
+		return nil, err
+	}
+	fillEnvironment(&results.Env)
+	return results, nil
+}
+
+// fillEnvironment adds the toolchain and source revision to env
+func fillEnvironment(env *Environment) {
+	env.Date = time.Now().UTC()
+	env.GoVersion = runtime.Version()
+	if out, err := exec.Command("go", "env", "GOVERSION").Output(); err == nil {
+		env.GoVersion = strings.TrimSpace(string(out))
+	}
+	if out, err := exec.Command("git", "rev-parse", "HEAD").Output(); err == nil {
+		env.Commit = strings.TrimSpace(string(out))
+	}
+	if out, err := exec.Command("git", "status", "--porcelain").Output(); err == nil {
+		env.Dirty = len(bytes.TrimSpace(out)) > 0
+	}
+}
+
+func loadResults(path string) (*Results, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var results Results
+	if err := json.Unmarshal(data, &results); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %w", path, err)
+	}
+	return &results, nil
+}
+
+func saveResults(path string, results *Results) error {
+	data, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
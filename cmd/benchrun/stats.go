@@ -0,0 +1,140 @@
+package main
+
+import (
+	"math"
+	"slices"
+)
+
+func median(xs []float64) float64 {
+	if len(xs) == 0 {
+		return math.NaN()
+	}
+	sorted := slices.Sorted(slices.Values(xs))
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
+
+// exactLimit is the largest sample size the exact U distribution is
+// computed for; larger samples use the normal approximation
+const exactLimit = 50
+
+// mannWhitneyU returns the two-sided p-value of the Mann-Whitney U test
+// that xs and ys come from the same distribution, as benchstat does. It
+// makes no assumption about the shape of the distributions, which suits
+// benchmark timings with their long right tails.
+func mannWhitneyU(xs, ys []float64) float64 {
+	n1, n2 := len(xs), len(ys)
+	if n1 == 0 || n2 == 0 {
+		return 1
+	}
+
+	// Rank the pooled samples, giving ties their average rank
+	type sample struct {
+		value float64
+		first bool
+	}
+	pooled := make([]sample, 0, n1+n2)
+	for _, x := range xs {
+		pooled = append(pooled, sample{x, true})
+	}
+	for _, y := range ys {
+		pooled = append(pooled, sample{y, false})
+	}
+	slices.SortFunc(pooled, func(a, b sample) int {
+		switch {
+		case a.value < b.value:
+			return -1
+		case a.value > b.value:
+			return 1
+		}
+		return 0
+	})
+
+	var rankSum1, tieCorrection float64
+	ties := false
+	for i := 0; i < len(pooled); {
+		j := i
+		for j < len(pooled) && pooled[j].value == pooled[i].value {
+			j++
+		}
+		rank := float64(i+j+1) / 2
+		for k := i; k < j; k++ {
+			if pooled[k].first {
+				rankSum1 += rank
+			}
+		}
+		if t := float64(j - i); t > 1 {
+			ties = true
+			tieCorrection += t*t*t - t
+		}
+		i = j
+	}
+
+	u1 := rankSum1 - float64(n1*(n1+1))/2
+	u := math.Min(u1, float64(n1*n2)-u1)
+
+	if !ties && n1 <= exactLimit && n2 <= exactLimit {
+		// P(U <= u) under the null hypothesis, doubled for two sides
+		return math.Min(1, 2*uCDF(n1, n2, int(u)))
+	}
+
+	n := float64(n1 + n2)
+	mean := float64(n1*n2) / 2
+	variance := float64(n1*n2) / 12 * ((n + 1) - tieCorrection/(n*(n-1)))
+	if variance == 0 {
+		// Every sample is equal
+		return 1
+	}
+	// Continuity correction towards the mean
+	z := (u - mean + 0.5) / math.Sqrt(variance)
+	return math.Min(1, 2*normalCDF(z))
+}
+
+// uCDF returns P(U <= u) for samples of sizes n1 and n2 without ties. The
+// number of orderings giving each U follows the recurrence
+// f(n1, n2, u) = f(n1-1, n2, u-n2) + f(n1, n2-1, u).
+func uCDF(n1, n2, u int) float64 {
+	// counts[j][v] holds f(i, j, v) for the current i
+	counts := make([][]float64, n2+1)
+	for j := range counts {
+		counts[j] = make([]float64, u+1)
+		counts[j][0] = 1
+	}
+	for i := 1; i <= n1; i++ {
+		next := make([][]float64, n2+1)
+		for j := 0; j <= n2; j++ {
+			next[j] = make([]float64, u+1)
+			for v := 0; v <= u; v++ {
+				if v >= j {
+					next[j][v] += counts[j][v-j]
+				}
+				if j > 0 {
+					next[j][v] += next[j-1][v]
+				}
+			}
+		}
+		counts = next
+	}
+
+	var atMost float64
+	for _, c := range counts[n2] {
+		atMost += c
+	}
+	total := binomial(n1+n2, n1)
+	return atMost / total
+}
+
+func binomial(n, k int) float64 {
+	result := 1.0
+	for i := 1; i <= k; i++ {
+		result = result * float64(n-k+i) / float64(i)
+	}
+	return result
+}
+
+func normalCDF(z float64) float64 {
+	return 0.5 * math.Erfc(-z/math.Sqrt2)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestMannWhitneyU(t *testing.T) {
+	tests := []struct {
+		name   string
+		xs, ys []float64
+		want   float64
+	}{
+		// Fully separated samples of 5 give the smallest possible p-value,
+		// 2 orderings out of C(10, 5) = 252
+		{"separated", []float64{1, 2, 3, 4, 5}, []float64{6, 7, 8, 9, 10}, 2.0 / 252},
+		{"separated reversed", []float64{6, 7, 8, 9, 10}, []float64{1, 2, 3, 4, 5}, 2.0 / 252},
+		// U = 1: P(U <= 1) = 2/252, doubled
+		{"one swap", []float64{1, 2, 3, 4, 6}, []float64{5, 7, 8, 9, 10}, 4.0 / 252},
+		// U = 10: P(U <= 10) = 87/252, doubled
+		{"interleaved", []float64{1, 3, 5, 7, 9}, []float64{2, 4, 6, 8, 10}, 174.0 / 252},
+		{"identical", []float64{5, 5, 5}, []float64{5, 5, 5}, 1},
+		{"empty", nil, []float64{1, 2}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mannWhitneyU(tt.xs, tt.ys); math.Abs(got-tt.want) > 0.001 {
+				t.Errorf("p = %.4f, want %.4f", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMannWhitneyUNormalApproximation(t *testing.T) {
+	// Ties force the normal approximation, which for clearly separated
+	// samples must still be significant and for equal ones must not
+	xs := []float64{10, 10, 11, 11, 12, 12, 13, 13, 14, 14}
+	ys := []float64{20, 20, 21, 21, 22, 22, 23, 23, 24, 24}
+	if p := mannWhitneyU(xs, ys); p >= 0.001 {
+		t.Errorf("separated samples: p = %.4f, want < 0.001", p)
+	}
+	if p := mannWhitneyU(xs, xs); p < 0.9 {
+		t.Errorf("equal samples: p = %.4f, want about 1", p)
+	}
+
+	// Without ties, the approximation is close to the exact test
+	var big1, big2 []float64
+	for i := range 60 {
+		big1 = append(big1, float64(2*i))
+		big2 = append(big2, float64(2*i+1))
+	}
+	if p := mannWhitneyU(big1, big2); p < 0.5 {
+		t.Errorf("interleaved large samples: p = %.4f, want not significant", p)
+	}
+}
+
+func TestMedian(t *testing.T) {
+	if got := median([]float64{3, 1, 2}); got != 2 {
+		t.Errorf("odd median = %v, want 2", got)
+	}
+	if got := median([]float64{4, 1, 3, 2}); got != 2.5 {
+		t.Errorf("even median = %v, want 2.5", got)
+	}
+}
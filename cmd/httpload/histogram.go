@@ -0,0 +1,108 @@
+package main
+
+import (
+	"math"
+	"math/bits"
+	"time"
+)
+
+// subBucketBits sets the histogram's precision: every power of two is split
+// into 1<<subBucketBits linear buckets, keeping the error under 1%
+const subBucketBits = 7
+
+const subBuckets = 1 << subBucketBits
+
+// histogram is an HDR-style log-linear latency histogram. It records
+// nanosecond values with constant relative precision in fixed memory,
+// whatever the range of latencies.
+type histogram struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+	min    int64
+	max    int64
+}
+
+func newHistogram() *histogram {
+	return &histogram{
+		counts: make([]uint64, (64-subBucketBits+1)*subBuckets),
+		min:    math.MaxInt64,
+	}
+}
+
+// bucketIndex maps v to its bucket. Values below subBuckets get a bucket
+// each; above that, each power of two gets subBuckets buckets.
+func bucketIndex(v uint64) int {
+	if v < subBuckets {
+		return int(v)
+	}
+	shift := bits.Len64(v) - subBucketBits - 1
+	return (shift+1)*subBuckets + int(v>>shift) - subBuckets
+}
+
+// bucketRange returns the lowest value in bucket i and the bucket's width
+func bucketRange(i int) (low, width uint64) {
+	if i < subBuckets {
+		return uint64(i), 1
+	}
+	shift := i/subBuckets - 1
+	return uint64(i%subBuckets+subBuckets) << shift, 1 << shift
+}
+
+func (h *histogram) record(d time.Duration) {
+	v := max(int64(d), 0)
+	h.counts[bucketIndex(uint64(v))]++
+	h.count++
+	h.sum += float64(v)
+	h.min = min(h.min, v)
+	h.max = max(h.max, v)
+}
+
+func (h *histogram) merge(other *histogram) {
+	for i, n := range other.counts {
+		h.counts[i] += n
+	}
+	h.count += other.count
+	h.sum += other.sum
+	h.min = min(h.min, other.min)
+	h.max = max(h.max, other.max)
+}
+
+// percentile returns the latency below which a fraction q of the recorded
+// values fall, as the midpoint of its bucket
+func (h *histogram) percentile(q float64) time.Duration {
+	if h.count == 0 {
+		return 0
+	}
+	target := uint64(math.Ceil(q * float64(h.count)))
+	target = max(target, 1)
+
+	var seen uint64
+	for i, n := range h.counts {
+		seen += n
+		if seen >= target {
+			low, width := bucketRange(i)
+			mid := int64(low + width/2)
+			return time.Duration(min(max(mid, h.min), h.max))
+		}
+	}
+	return time.Duration(h.max)
+}
+
+func (h *histogram) mean() time.Duration {
+	if h.count == 0 {
+		return 0
+	}
+	return time.Duration(h.sum / float64(h.count))
+}
+
+func (h *histogram) minimum() time.Duration {
+	if h.count == 0 {
+		return 0
+	}
+	return time.Duration(h.min)
+}
+
+func (h *histogram) maximum() time.Duration {
+	return time.Duration(h.max)
+}
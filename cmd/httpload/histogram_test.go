@@ -0,0 +1,58 @@
+package main
+
+import (
+	"math/rand/v2"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestBucketIndexRoundTrip(t *testing.T) {
+	for _, v := range []uint64{0, 1, 127, 128, 255, 256, 1000, 123456789, 1 << 40, 1<<63 + 12345} {
+		low, width := bucketRange(bucketIndex(v))
+		if v < low || v-low >= width {
+			t.Errorf("value %d mapped to bucket [%d, %d)", v, low, low+width)
+		}
+		if v >= subBuckets && float64(width)/float64(v) > 1.0/subBuckets {
+			t.Errorf("value %d has bucket width %d, more than 1/%d of it", v, width, subBuckets)
+		}
+	}
+}
+
+func TestHistogramPercentiles(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	h := newHistogram()
+	var values []time.Duration
+	for range 10000 {
+		d := time.Duration(rng.ExpFloat64() * float64(5*time.Millisecond))
+		values = append(values, d)
+		h.record(d)
+	}
+	slices.Sort(values)
+
+	for _, q := range []float64{0.5, 0.9, 0.99, 0.999} {
+		want := values[int(q*float64(len(values)))-1]
+		got := h.percentile(q)
+		if diff := float64(got-want) / float64(want); diff > 0.01 || diff < -0.01 {
+			t.Errorf("p%g = %v, want %v within 1%%", q*100, got, want)
+		}
+	}
+	if h.minimum() != values[0] || h.maximum() != values[len(values)-1] {
+		t.Errorf("min, max = %v, %v; want %v, %v", h.minimum(), h.maximum(), values[0], values[len(values)-1])
+	}
+}
+
+func TestHistogramMerge(t *testing.T) {
+	a, b := newHistogram(), newHistogram()
+	for i := 1; i <= 100; i++ {
+		a.record(time.Duration(i) * time.Millisecond)
+		b.record(time.Duration(i+100) * time.Millisecond)
+	}
+	a.merge(b)
+	if a.count != 200 || a.minimum() != time.Millisecond || a.maximum() != 200*time.Millisecond {
+		t.Errorf("merged count %d, min %v, max %v", a.count, a.minimum(), a.maximum())
+	}
+	if p50 := a.percentile(0.5); p50 < 99*time.Millisecond || p50 > 101*time.Millisecond {
+		t.Errorf("merged p50 = %v, want about 100ms", p50)
+	}
+}
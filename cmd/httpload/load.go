@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/valyala/fasthttp"
+	"golang-content/httpclient"
+)
+
+// loadConfig describes one load test
+type loadConfig struct {
+	request *httpclient.Request
+	// rate is the open-model arrival rate in requests per second; zero runs
+	// a closed model where each worker sends its next request as soon as
+	// the previous one completes
+	rate float64
+	// concurrency is the number of workers, which in the open model bounds
+	// the requests in flight
+	concurrency int
+	duration    time.Duration
+}
+
+// result is what one load test observed
+type result struct {
+	backend  httpclient.Backend
+	elapsed  time.Duration
+	requests uint64
+	// dropped counts open-model arrivals that could not even be queued
+	// because every worker was busy and the queue was full
+	dropped  uint64
+	statuses map[int]uint64
+	errors   map[string]uint64
+	latency  *histogram
+}
+
+func newResult(backend httpclient.Backend) *result {
+	return &result{
+		backend:  backend,
+		statuses: make(map[int]uint64),
+		errors:   make(map[string]uint64),
+		latency:  newHistogram(),
+	}
+}
+
+func (r *result) record(resp httpclient.HTTPResponse, latency time.Duration) {
+	r.requests++
+	r.latency.record(latency)
+	if resp.Error != nil {
+		r.errors[errorClass(resp.Error)]++
+		return
+	}
+	r.statuses[resp.StatusCode]++
+}
+
+func (r *result) merge(other *result) {
+	r.requests += other.requests
+	r.dropped += other.dropped
+	for status, n := range other.statuses {
+		r.statuses[status] += n
+	}
+	for class, n := range other.errors {
+		r.errors[class] += n
+	}
+	r.latency.merge(other.latency)
+}
+
+func (r *result) errorCount() uint64 {
+	var n uint64
+	for _, count := range r.errors {
+		n += count
+	}
+	return n
+}
+
+// errorClass groups errors by cause, since their messages carry addresses
+// and ports that would make every error distinct
+func errorClass(err error) string {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout(), errors.Is(err, fasthttp.ErrTimeout):
+		return "timeout"
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return "connection refused"
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, fasthttp.ErrConnectionClosed):
+		return "connection reset"
+	default:
+		return "other"
+	}
+}
+
+// runLoad sends load through client until cfg.duration has passed, then
+// waits for the requests in flight
+func runLoad(ctx context.Context, client *httpclient.Client, cfg loadConfig) *result {
+	workers := make([]*result, cfg.concurrency)
+	for i := range workers {
+		workers[i] = newResult(client.Backend())
+	}
+
+	start := time.Now()
+	deadline := start.Add(cfg.duration)
+	var wg sync.WaitGroup
+	var arrivals chan time.Time
+	if cfg.rate > 0 {
+		arrivals = make(chan time.Time, 1<<16)
+	}
+
+	for _, worker := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if arrivals == nil {
+				for ctx.Err() == nil && time.Now().Before(deadline) {
+					sent := time.Now()
+					resp := client.Do(ctx, cfg.request)
+					worker.record(resp, time.Since(sent))
+				}
+				return
+			}
+			// Latency counts from when the request was due, not from when a
+			// worker got to it, so that queueing behind slow responses is
+			// not hidden (coordinated omission)
+			for due := range arrivals {
+				resp := client.Do(ctx, cfg.request)
+				worker.record(resp, time.Since(due))
+			}
+		}()
+	}
+
+	var dropped uint64
+	if arrivals != nil {
+		dropped = schedule(ctx, arrivals, cfg.rate, deadline)
+		close(arrivals)
+	}
+	wg.Wait()
+
+	total := newResult(client.Backend())
+	total.elapsed = time.Since(start)
+	total.dropped = dropped
+	for _, worker := range workers {
+		total.merge(worker)
+	}
+	return total
+}
+
+// schedule queues an arrival every 1/rate seconds until deadline and
+// returns how many arrivals the full queue turned away
+func schedule(ctx context.Context, arrivals chan<- time.Time, rate float64, deadline time.Time) uint64 {
+	interval := time.Duration(float64(time.Second) / rate)
+	var dropped uint64
+	next := time.Now()
+	for next.Before(deadline) {
+		if wait := time.Until(next); wait > 0 {
+			select {
+			case <-time.After(wait):
+			case <-ctx.Done():
+				return dropped
+			}
+		}
+		// Queue every arrival that fell due while sleeping, which keeps the
+		// rate when it exceeds the timer resolution
+		for now := time.Now(); !next.After(now) && next.Before(deadline); next = next.Add(interval) {
+			select {
+			case arrivals <- next:
+			default:
+				dropped++
+			}
+		}
+	}
+	return dropped
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"golang-content/httpclient"
+	"golang-content/httpclient/httpclienttest"
+)
+
+func TestRunLoad(t *testing.T) {
+	server := httpclienttest.NewServer()
+	defer server.Close()
+	server.Handle("", "*").Fail(0.2, http.StatusServiceUnavailable).Latency(2 * time.Millisecond)
+
+	for _, backend := range []httpclient.Backend{httpclient.BackendStandard, httpclient.BackendFastHTTP} {
+		client, err := httpclient.NewClient(backend)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req := &httpclient.Request{Method: http.MethodGet, URL: server.URL}
+
+		t.Run(string(backend)+"/closed", func(t *testing.T) {
+			r := runLoad(context.Background(), client, loadConfig{request: req, concurrency: 4, duration: 200 * time.Millisecond})
+			if r.requests == 0 || r.statuses[http.StatusOK]+r.statuses[http.StatusServiceUnavailable] != r.requests {
+				t.Errorf("requests %d, statuses %v, errors %v", r.requests, r.statuses, r.errors)
+			}
+			if r.statuses[http.StatusServiceUnavailable] == 0 {
+				t.Error("no injected failures were counted")
+			}
+			if r.latency.percentile(0.5) < 2*time.Millisecond {
+				t.Errorf("p50 = %v, below the server latency", r.latency.percentile(0.5))
+			}
+		})
+
+		t.Run(string(backend)+"/open", func(t *testing.T) {
+			r := runLoad(context.Background(), client, loadConfig{request: req, rate: 200, concurrency: 4, duration: 250 * time.Millisecond})
+			if r.requests < 40 || r.requests > 60 {
+				t.Errorf("sent %d requests at 200/s for 250ms, want about 50", r.requests)
+			}
+		})
+	}
+}
+
+func TestErrorClasses(t *testing.T) {
+	server := httpclienttest.NewServer()
+	server.Handle("", "/reset").Reset(1)
+	server.Handle("", "/slow").Latency(time.Second)
+	defer server.Close()
+
+	for _, backend := range []httpclient.Backend{httpclient.BackendStandard, httpclient.BackendFastHTTP} {
+		client, err := httpclient.NewClient(backend, httpclient.WithTimeout(50*time.Millisecond))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for path, want := range map[string]string{"/reset": "connection reset", "/slow": "timeout"} {
+			resp := client.Get(context.Background(), server.URL+path, nil)
+			if got := errorClass(resp.Error); got != want {
+				t.Errorf("%s %s: class of %v = %q, want %q", backend, path, resp.Error, got, want)
+			}
+		}
+	}
+}
+
+func TestWriteReport(t *testing.T) {
+	a, b := newResult(httpclient.BackendStandard), newResult(httpclient.BackendFastHTTP)
+	for _, r := range []*result{a, b} {
+		r.elapsed = time.Second
+		r.record(httpclient.HTTPResponse{StatusCode: http.StatusOK}, time.Millisecond)
+	}
+	b.record(httpclient.HTTPResponse{StatusCode: http.StatusBadGateway}, 3*time.Millisecond)
+
+	var out bytes.Buffer
+	if err := writeReport(&out, []*result{a, b}); err != nil {
+		t.Fatal(err)
+	}
+	report := out.String()
+	for _, want := range []string{"net/http", "fasthttp", "latency p99.9", "status 502", "2.0/s"} {
+		if !strings.Contains(report, want) {
+			t.Errorf("report lacks %q:\n%s", want, report)
+		}
+	}
+}
+
+func TestRunFlags(t *testing.T) {
+	for _, args := range [][]string{
+		{},
+		{"-url", "http://localhost", "-c", "0"},
+		{"-url", "http://localhost", "-H", "no-colon"},
+		{"-url", "http://localhost", "-backend", "curl", "-d", "10ms"},
+	} {
+		if err := run(args); err == nil {
+			t.Errorf("run(%q) succeeded", args)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+// Httpload sends HTTP load through the httpclient backends and reports
+// latency percentiles, status codes and errors.
+//
+// Without -rate it runs a closed model: -c workers each send a request as
+// soon as their previous one completes. With -rate it runs an open model:
+// requests arrive at a fixed rate whatever the response times, and latency
+// includes the time a request waited for a free worker.
+//
+//	httpload -url http://localhost:8080/items -c 32 -d 30s
+//	httpload -url http://localhost:8080/items -rate 2000 -backend both
+//	httpload -url http://localhost:8080/items -X POST -H 'Content-Type: application/json' -body @item.json
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"golang-content/httpclient"
+)
+
+// headerFlags collects repeated -H "Key: Value" flags
+type headerFlags map[string]string
+
+func (h headerFlags) String() string {
+	var pairs []string
+	for key, value := range h {
+		pairs = append(pairs, key+": "+value)
+	}
+	return strings.Join(pairs, ", ")
+}
+
+func (h headerFlags) Set(value string) error {
+	key, val, ok := strings.Cut(value, ":")
+	if !ok || strings.TrimSpace(key) == "" {
+		return fmt.Errorf("header %q is not in Key: Value form", value)
+	}
+	h[strings.TrimSpace(key)] = strings.TrimSpace(val)
+	return nil
+}
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, "httpload:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	headers := headerFlags{}
+	flags := flag.NewFlagSet("httpload", flag.ContinueOnError)
+	target := flags.String("url", "", "target URL (required)")
+	method := flags.String("X", "GET", "request method")
+	body := flags.String("body", "", "request body, or @file to read it from a file")
+	flags.Var(headers, "H", "request header as 'Key: Value'; may be repeated")
+	rate := flags.Float64("rate", 0, "open-model arrival rate in requests per second; 0 runs a closed model")
+	concurrency := flags.Int("c", 10, "closed-model workers, or the open-model limit on requests in flight")
+	duration := flags.Duration("d", 10*time.Second, "how long to send load")
+	timeout := flags.Duration("timeout", 10*time.Second, "per-request timeout")
+	backend := flags.String("backend", string(httpclient.BackendStandard), `backend: "net/http", "fasthttp" or "both" to compare them`)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *target == "" {
+		return fmt.Errorf("-url is required")
+	}
+	if *concurrency < 1 {
+		return fmt.Errorf("-c must be at least 1")
+	}
+	if *rate < 0 || *duration <= 0 {
+		return fmt.Errorf("-rate must not be negative and -d must be positive")
+	}
+
+	backends := []httpclient.Backend{httpclient.Backend(*backend)}
+	if *backend == "both" {
+		backends = []httpclient.Backend{httpclient.BackendStandard, httpclient.BackendFastHTTP}
+	}
+
+	req := &httpclient.Request{Method: strings.ToUpper(*method), URL: *target, Headers: headers}
+	if *body != "" {
+		req.Body = []byte(*body)
+		if path, ok := strings.CutPrefix(*body, "@"); ok {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return fmt.Errorf("error reading body: %w", err)
+			}
+			req.Body = data
+		}
+	}
+	cfg := loadConfig{request: req, rate: *rate, concurrency: *concurrency, duration: *duration}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	var results []*result
+	for _, b := range backends {
+		client, err := httpclient.NewClient(b,
+			httpclient.WithTimeout(*timeout),
+			httpclient.WithMaxIdleConnsPerHost(*concurrency),
+		)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "sending load through %s for %s\n", b, *duration)
+		results = append(results, runLoad(ctx, client, cfg))
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return writeReport(os.Stdout, results)
+}
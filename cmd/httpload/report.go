@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"text/tabwriter"
+	"time"
+)
+
+// reportPercentiles are the latency percentiles in the report
+var reportPercentiles = []float64{0.5, 0.9, 0.99, 0.999}
+
+// writeReport prints results side by side, one column per backend
+func writeReport(w io.Writer, results []*result) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	row := func(label string, cell func(*result) string) {
+		fmt.Fprintf(tw, "%-14s\t", label)
+		for _, r := range results {
+			fmt.Fprintf(tw, "%s\t", cell(r))
+		}
+		fmt.Fprintln(tw)
+	}
+
+	row("", func(r *result) string { return string(r.backend) })
+	row("requests", func(r *result) string { return fmt.Sprint(r.requests) })
+	row("duration", func(r *result) string { return r.elapsed.Round(time.Millisecond).String() })
+	row("throughput", func(r *result) string {
+		return fmt.Sprintf("%.1f/s", float64(r.requests)/r.elapsed.Seconds())
+	})
+	row("errors", func(r *result) string { return fmt.Sprint(r.errorCount()) })
+	if slices.ContainsFunc(results, func(r *result) bool { return r.dropped > 0 }) {
+		row("dropped", func(r *result) string { return fmt.Sprint(r.dropped) })
+	}
+
+	row("latency min", func(r *result) string { return formatLatency(r.latency.minimum()) })
+	row("latency mean", func(r *result) string { return formatLatency(r.latency.mean()) })
+	for _, q := range reportPercentiles {
+		row(fmt.Sprintf("latency p%g", q*100), func(r *result) string { return formatLatency(r.latency.percentile(q)) })
+	}
+	row("latency max", func(r *result) string { return formatLatency(r.latency.maximum()) })
+
+	for _, status := range unionKeys(results, func(r *result) map[int]uint64 { return r.statuses }) {
+		row(fmt.Sprintf("status %d", status), func(r *result) string { return fmt.Sprint(r.statuses[status]) })
+	}
+	for _, class := range unionKeys(results, func(r *result) map[string]uint64 { return r.errors }) {
+		row("error "+class, func(r *result) string { return fmt.Sprint(r.errors[class]) })
+	}
+	return tw.Flush()
+}
+
+func formatLatency(d time.Duration) string {
+	switch {
+	case d >= time.Second:
+		return d.Round(time.Millisecond).String()
+	case d >= time.Millisecond:
+		return d.Round(10 * time.Microsecond).String()
+	default:
+		return d.Round(time.Microsecond).String()
+	}
+}
+
+// unionKeys returns the sorted keys present in any result's map
+func unionKeys[K int | string](results []*result, counts func(*result) map[K]uint64) []K {
+	var keys []K
+	for _, r := range results {
+		for key := range counts(r) {
+			if !slices.Contains(keys, key) {
+				keys = append(keys, key)
+			}
+		}
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -4,6 +4,7 @@ go 1.24
 
 require (
 	github.com/json-iterator/go v1.1.12
+	github.com/valyala/bytebufferpool v1.0.0
 	github.com/valyala/fasthttp v1.62.0
 )
 
@@ -12,5 +13,4 @@ require (
 	github.com/klauspost/compress v1.18.0 // indirect
 	github.com/modern-go/concurrent v0.0.0-20180228061459-e0a39a4cb421 // indirect
 	github.com/modern-go/reflect2 v1.0.2 // indirect
-	github.com/valyala/bytebufferpool v1.0.0 // indirect
 )
@@ -0,0 +1,152 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"sync"
+)
+
+// ErrBatchAborted is the error given to requests that were not sent because
+// a fail-fast batch stopped early
+var ErrBatchAborted = errors.New("batch aborted")
+
+// BatchPolicy decides what a batch does when a request fails
+type BatchPolicy int
+
+const (
+	// CollectAll sends every request regardless of failures
+	CollectAll BatchPolicy = iota
+	// FailFast cancels the remaining requests after the first failure
+	FailFast
+)
+
+// BatchOptions configures DoBatch, StreamBatch and BatchSeq
+type BatchOptions struct {
+	// Concurrency bounds the requests in flight at once; defaults to 10
+	Concurrency int
+	Policy      BatchPolicy
+
+	// IsFailure decides whether a response fails the batch under FailFast;
+	// defaults to a non-nil Error
+	IsFailure func(HTTPResponse) bool
+}
+
+// BatchResult is one completed request of a batch
+type BatchResult struct {
+	// Index is the position of the request in the slice passed to the batch
+	Index    int
+	Response HTTPResponse
+}
+
+// DoBatch sends the requests with bounded parallelism and returns the
+// responses in the same order as reqs
+func (c *Client) DoBatch(ctx context.Context, reqs []*Request, opts BatchOptions) []HTTPResponse {
+	responses := make([]HTTPResponse, len(reqs))
+	c.runBatch(ctx, reqs, opts, func(result BatchResult) bool {
+		responses[result.Index] = result.Response
+		return true
+	})
+	return responses
+}
+
+// StreamBatch sends the requests with bounded parallelism and delivers each
+// result as it completes. The channel is closed once every request has a
+// result, so callers must drain it, or once ctx is done, in which case
+// results not yet received are dropped and callers may stop reading.
+func (c *Client) StreamBatch(ctx context.Context, reqs []*Request, opts BatchOptions) <-chan BatchResult {
+	results := make(chan BatchResult, max(opts.concurrency(), 1))
+	go func() {
+		defer close(results)
+		c.runBatch(ctx, reqs, opts, func(result BatchResult) bool {
+			select {
+			case results <- result:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		})
+	}()
+	return results
+}
+
+// BatchSeq returns an iterator over the results in completion order, keyed by
+// request index. Stopping the iteration early cancels the requests still
+// pending.
+func (c *Client) BatchSeq(ctx context.Context, reqs []*Request, opts BatchOptions) iter.Seq2[int, HTTPResponse] {
+	return func(yield func(int, HTTPResponse) bool) {
+		c.runBatch(ctx, reqs, opts, func(result BatchResult) bool {
+			return yield(result.Index, result.Response)
+		})
+	}
+}
+
+func (o BatchOptions) concurrency() int {
+	if o.Concurrency > 0 {
+		return o.Concurrency
+	}
+	return 10
+}
+
+func (o BatchOptions) failed(resp HTTPResponse) bool {
+	if o.IsFailure != nil {
+		return o.IsFailure(resp)
+	}
+	return resp.Error != nil
+}
+
+// runBatch fans the requests out to a bounded set of workers and passes each
+// result to emit from a single goroutine. Returning false from emit cancels
+// the rest of the batch and suppresses further results.
+func (c *Client) runBatch(ctx context.Context, reqs []*Request, opts BatchOptions, emit func(BatchResult) bool) {
+	if len(reqs) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	indexes := make(chan int)
+	results := make(chan BatchResult)
+
+	var wg sync.WaitGroup
+	for i := 0; i < min(opts.concurrency(), len(reqs)); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indexes {
+				var resp HTTPResponse
+				if err := context.Cause(ctx); err != nil {
+					resp = HTTPResponse{Error: fmt.Errorf("error making request: %w", err)}
+				} else {
+					resp = c.Do(ctx, reqs[index])
+				}
+				results <- BatchResult{Index: index, Response: resp}
+			}
+		}()
+	}
+
+	go func() {
+		defer close(indexes)
+		for index := range reqs {
+			indexes <- index
+		}
+	}()
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	emitting := true
+	for result := range results {
+		if opts.Policy == FailFast && opts.failed(result.Response) {
+			cancel(ErrBatchAborted)
+		}
+		if emitting && !emit(result) {
+			emitting = false
+			cancel(ErrBatchAborted)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// setupConcurrencyTestServer echoes the request path and records the highest
+// number of requests it served at once
+func setupConcurrencyTestServer(delay time.Duration, peak *atomic.Int64) *httptest.Server {
+	var active atomic.Int64
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := active.Add(1)
+		defer active.Add(-1)
+		for {
+			old := peak.Load()
+			if n <= old || peak.CompareAndSwap(old, n) {
+				break
+			}
+		}
+		time.Sleep(delay)
+		w.Write([]byte(r.URL.Path))
+	}))
+}
+
+func batchRequests(baseURL string, n int) []*Request {
+	reqs := make([]*Request, n)
+	for i := range reqs {
+		reqs[i] = &Request{Method: http.MethodGet, URL: fmt.Sprintf("%s/item/%d", baseURL, i)}
+	}
+	return reqs
+}
+
+func TestClientDoBatch(t *testing.T) {
+	var peak atomic.Int64
+	server := setupConcurrencyTestServer(5*time.Millisecond, &peak)
+	defer server.Close()
+
+	for _, backend := range []Backend{BackendStandard, BackendFastHTTP} {
+		t.Run(string(backend), func(t *testing.T) {
+			peak.Store(0)
+			client, err := NewClient(backend)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			responses := client.DoBatch(context.Background(), batchRequests(server.URL, 40), BatchOptions{Concurrency: 4})
+			for i, resp := range responses {
+				if resp.Error != nil {
+					t.Fatal(resp.Error)
+				}
+				if want := fmt.Sprintf("/item/%d", i); string(resp.Body) != want {
+					t.Errorf("responses[%d] = %q, want %q", i, resp.Body, want)
+				}
+			}
+			if got := peak.Load(); got > 4 {
+				t.Errorf("peak concurrency = %d, want at most 4", got)
+			}
+		})
+	}
+}
+
+func TestClientBatchFailFast(t *testing.T) {
+	var peak atomic.Int64
+	server := setupConcurrencyTestServer(5*time.Millisecond, &peak)
+	defer server.Close()
+
+	client, err := NewClient(BackendStandard)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reqs := batchRequests(server.URL, 20)
+	reqs[0].URL = "http://127.0.0.1:0/unreachable"
+
+	responses := client.DoBatch(context.Background(), reqs, BatchOptions{Concurrency: 2, Policy: FailFast})
+	if responses[0].Error == nil {
+		t.Fatal("expected the unreachable request to fail")
+	}
+	if !errors.Is(responses[len(responses)-1].Error, ErrBatchAborted) {
+		t.Errorf("last response error = %v, want ErrBatchAborted", responses[len(responses)-1].Error)
+	}
+
+	// CollectAll keeps going past the failure
+	responses = client.DoBatch(context.Background(), reqs, BatchOptions{Concurrency: 2})
+	for _, resp := range responses[1:] {
+		if resp.Error != nil {
+			t.Errorf("CollectAll response error = %v", resp.Error)
+		}
+	}
+}
+
+func TestClientStreamBatch(t *testing.T) {
+	var peak atomic.Int64
+	server := setupConcurrencyTestServer(time.Millisecond, &peak)
+	defer server.Close()
+
+	client, err := NewClient(BackendFastHTTP)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seen := make(map[int]bool)
+	for result := range client.StreamBatch(context.Background(), batchRequests(server.URL, 15), BatchOptions{Concurrency: 3}) {
+		if result.Response.Error != nil {
+			t.Fatal(result.Response.Error)
+		}
+		seen[result.Index] = true
+	}
+	if len(seen) != 15 {
+		t.Errorf("got %d results, want 15", len(seen))
+	}
+}
+
+func TestClientStreamBatchCancel(t *testing.T) {
+	var peak atomic.Int64
+	server := setupConcurrencyTestServer(time.Millisecond, &peak)
+	defer server.Close()
+
+	client, err := NewClient(BackendStandard)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The caller takes one result, cancels and walks away. The batch must
+	// finish without anyone reading, so at most the results already
+	// buffered are left to drain.
+	ctx, cancel := context.WithCancel(context.Background())
+	results := client.StreamBatch(ctx, batchRequests(server.URL, 20), BatchOptions{Concurrency: 2})
+	<-results
+	cancel()
+	time.Sleep(100 * time.Millisecond)
+
+	drained := 0
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case _, ok := <-results:
+			if !ok {
+				if drained > cap(results) {
+					t.Errorf("drained %d results after cancelling, want at most the %d buffered", drained, cap(results))
+				}
+				return
+			}
+			drained++
+		case <-timeout:
+			t.Fatal("results channel not closed after cancelling")
+		}
+	}
+}
+
+func TestClientBatchSeqStopsEarly(t *testing.T) {
+	var peak atomic.Int64
+	server := setupConcurrencyTestServer(time.Millisecond, &peak)
+	defer server.Close()
+
+	client, err := NewClient(BackendStandard)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	count := 0
+	for _, resp := range client.BatchSeq(context.Background(), batchRequests(server.URL, 50), BatchOptions{Concurrency: 2}) {
+		if resp.Error != nil {
+			t.Fatal(resp.Error)
+		}
+		count++
+		if count == 3 {
+			break
+		}
+	}
+	if count != 3 {
+		t.Errorf("iterated %d results, want 3", count)
+	}
+}
+
+func TestClientBatchContextCancel(t *testing.T) {
+	var peak atomic.Int64
+	server := setupConcurrencyTestServer(20*time.Millisecond, &peak)
+	defer server.Close()
+
+	client, err := NewClient(BackendStandard)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+
+	responses := client.DoBatch(ctx, batchRequests(server.URL, 20), BatchOptions{Concurrency: 1})
+	if !errors.Is(responses[len(responses)-1].Error, context.DeadlineExceeded) {
+		t.Errorf("last response error = %v, want context.DeadlineExceeded", responses[len(responses)-1].Error)
+	}
+}
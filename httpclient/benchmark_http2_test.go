@@ -0,0 +1,115 @@
+package httpclient
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// setupTLSTestServer creates a TLS test server that negotiates HTTP/2 when
+// enableHTTP2 is set and HTTP/1.1 otherwise
+func setupTLSTestServer(enableHTTP2 bool) *httptest.Server {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Proto", r.Proto)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"message": "success"}`))
+	})
+
+	server := httptest.NewUnstartedServer(handler)
+	server.EnableHTTP2 = enableHTTP2
+	server.StartTLS()
+	return server
+}
+
+// setupH2CTestServer creates a cleartext server accepting HTTP/2 with prior
+// knowledge alongside HTTP/1.1
+func setupH2CTestServer() *httptest.Server {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Proto", r.Proto)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"message": "success"}`))
+	})
+
+	server := httptest.NewUnstartedServer(handler)
+	protocols := new(http.Protocols)
+	protocols.SetHTTP1(true)
+	protocols.SetUnencryptedHTTP2(true)
+	server.Config.Protocols = protocols
+	server.Start()
+	return server
+}
+
+// trustTestServer returns an option trusting the test server's certificate
+func trustTestServer(server *httptest.Server) Option {
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+	return WithTLS(TLSOptions{RootCAs: roots})
+}
+
+func BenchmarkHTTPVersionComparison(b *testing.B) {
+	type variant struct {
+		name   string
+		server func() *httptest.Server
+		opts   func(server *httptest.Server) []Option
+	}
+
+	variants := []variant{
+		{
+			name:   "HTTP1-TLS",
+			server: func() *httptest.Server { return setupTLSTestServer(false) },
+			opts:   func(s *httptest.Server) []Option { return []Option{trustTestServer(s)} },
+		},
+		{
+			name:   "HTTP2-TLS",
+			server: func() *httptest.Server { return setupTLSTestServer(true) },
+			opts:   func(s *httptest.Server) []Option { return []Option{trustTestServer(s), WithHTTP2()} },
+		},
+		{
+			name:   "HTTP1-Cleartext",
+			server: setupH2CTestServer,
+			opts:   func(s *httptest.Server) []Option { return nil },
+		},
+		{
+			name:   "H2C",
+			server: setupH2CTestServer,
+			opts:   func(s *httptest.Server) []Option { return []Option{WithH2C()} },
+		},
+	}
+
+	ctx := context.Background()
+	headers := map[string]string{
+		"User-Agent": "Benchmark-Client",
+	}
+
+	for _, v := range variants {
+		for _, parallelism := range []int{1, 8, 64} {
+			b.Run(fmt.Sprintf("%s/Parallelism-%d", v.name, parallelism), func(b *testing.B) {
+				server := v.server()
+				defer server.Close()
+
+				client, err := NewClient(BackendStandard, v.opts(server)...)
+				if err != nil {
+					b.Fatal(err)
+				}
+
+				b.SetParallelism(parallelism)
+				b.ResetTimer()
+				b.RunParallel(func(pb *testing.PB) {
+					for pb.Next() {
+						resp := client.Get(ctx, server.URL, headers)
+						if resp.Error != nil {
+							b.Error(resp.Error)
+							return
+						}
+					}
+				})
+				b.StopTimer()
+
+				reportPoolStats(b, client, server.URL)
+			})
+		}
+	}
+}
@@ -0,0 +1,284 @@
+package httpclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang-content/httpclient/httpclienttest"
+)
+
+// The benchmark matrix crosses every value of each dimension below and runs
+// one sub-benchmark per combination, named in benchstat's key=value form:
+//
+//	BenchmarkMatrix/backend=fasthttp/method=GET/payload=large/encoding=gzip/conc=parallel
+//
+// so results can be filtered with -bench and compared with e.g.
+// benchstat -col /backend. Adding a backend, payload or concurrency level is
+// one entry in its slice.
+
+// benchBackends are the backends under test: a Client on each backend, and
+// the package-level StandardGet/StandardPost and FastHTTPGet/FastHTTPPost
+// functions, whose results stay comparable with their earlier benchmarks
+var benchBackends = []struct {
+	name    string
+	backend Backend
+	// pkg sends through the package-level functions instead of a Client
+	pkg bool
+}{
+	{"nethttp", BackendStandard, false},
+	{"fasthttp", BackendFastHTTP, false},
+	{"nethttp-pkg", BackendStandard, true},
+	{"fasthttp-pkg", BackendFastHTTP, true},
+}
+
+var benchMethods = []string{http.MethodGet, http.MethodPost}
+
+// benchPayloads are the response bodies served, by name
+var benchPayloads = []struct {
+	name string
+	body func() []byte
+}{
+	{"small", func() []byte { return []byte(`{"message":"success","count":1}`) }},
+	{"medium", generateMediumJSON},
+	{"large", generateLargeJSON},
+}
+
+var benchEncodings = []string{"identity", "gzip"}
+
+// benchConcurrency runs each benchmark serially or with b.RunParallel at the
+// given multiple of GOMAXPROCS
+var benchConcurrency = []struct {
+	name        string
+	parallelism int
+}{
+	{"serial", 0},
+	{"parallel", 1},
+}
+
+const benchTimeout = 5 * time.Second
+
+// benchRequestBody is the body of POST requests
+var benchRequestBody = map[string]interface{}{
+	"test": "data",
+	"num":  123,
+}
+
+func BenchmarkMatrix(b *testing.B) {
+	ctx := context.Background()
+
+	// One server per payload and encoding, shared by the sub-benchmarks
+	servers := make(map[string]*httptest.Server)
+	defer func() {
+		for _, server := range servers {
+			server.Close()
+		}
+	}()
+	payloadSizes := make(map[string]int)
+	for _, payload := range benchPayloads {
+		body := payload.body()
+		payloadSizes[payload.name] = len(body)
+		for _, encoding := range benchEncodings {
+			servers[payload.name+"/"+encoding] = setupJSONTestServer(body, encoding == "gzip")
+		}
+	}
+
+	for _, backend := range benchBackends {
+		for _, method := range benchMethods {
+			for _, payload := range benchPayloads {
+				for _, encoding := range benchEncodings {
+					for _, conc := range benchConcurrency {
+						name := fmt.Sprintf("backend=%s/method=%s/payload=%s/encoding=%s/conc=%s", backend.name, method, payload.name, encoding, conc.name)
+						b.Run(name, func(b *testing.B) {
+							url := servers[payload.name+"/"+encoding].URL
+							headers := map[string]string{"User-Agent": "Benchmark-Client"}
+							if encoding == "gzip" {
+								headers["Accept-Encoding"] = "gzip"
+							}
+							var send func() HTTPResponse
+							if backend.pkg {
+								send = packageSender(ctx, backend.backend, method, url, headers)
+							} else {
+								client, err := NewClient(backend.backend, WithTimeout(benchTimeout))
+								if err != nil {
+									b.Fatal(err)
+								}
+								send = func() HTTPResponse {
+									if method == http.MethodPost {
+										return client.Post(ctx, url, headers, benchRequestBody)
+									}
+									return client.Get(ctx, url, headers)
+								}
+							}
+							probe := runBenchCase(b, conc.parallelism, send)
+							// Asking for gzip explicitly leaves the body compressed
+							if encoding == "gzip" {
+								b.ReportMetric(float64(payloadSizes[payload.name])/float64(len(probe.Body)), "compression_ratio")
+							}
+						})
+					}
+				}
+			}
+		}
+	}
+}
+
+// packageSender sends requests through the package-level function for the
+// backend and method
+func packageSender(ctx context.Context, backend Backend, method, url string, headers map[string]string) func() HTTPResponse {
+	switch {
+	case backend == BackendFastHTTP && method == http.MethodPost:
+		return func() HTTPResponse { return FastHTTPPost(url, headers, benchRequestBody, benchTimeout) }
+	case backend == BackendFastHTTP:
+		return func() HTTPResponse { return FastHTTPGet(url, headers, benchTimeout) }
+	case method == http.MethodPost:
+		return func() HTTPResponse { return StandardPost(ctx, url, headers, benchRequestBody, benchTimeout) }
+	default:
+		return func() HTTPResponse { return StandardGet(ctx, url, headers, benchTimeout) }
+	}
+}
+
+// runBenchCase calls send b.N times, serially when parallelism is 0, and
+// reports the response body size and the bytes that crossed the wire,
+// headers and framing included. It returns the response of an untimed
+// probe request.
+func runBenchCase(b *testing.B, parallelism int, send func() HTTPResponse) HTTPResponse {
+	// The body size is the same for every request, so one probe measures it
+	// without adding bookkeeping to the timed loop. It also opens the first
+	// connection, so the timed loop starts from a warm pool.
+	probe := send()
+	if probe.Error != nil {
+		b.Fatal(probe.Error)
+	}
+
+	var wireBytes atomic.Int64
+	b.ResetTimer()
+	if parallelism == 0 {
+		for i := 0; i < b.N; i++ {
+			resp := send()
+			if resp.Error != nil {
+				b.Fatal(resp.Error)
+			}
+			wireBytes.Add(resp.WireBytesRead + resp.WireBytesWritten)
+		}
+	} else {
+		b.SetParallelism(parallelism)
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				resp := send()
+				if resp.Error != nil {
+					b.Error(resp.Error)
+					return
+				}
+				wireBytes.Add(resp.WireBytesRead + resp.WireBytesWritten)
+			}
+		})
+	}
+	b.StopTimer()
+
+	b.ReportMetric(float64(len(probe.Body)), "body_bytes/op")
+	// The package-level functions do not count wire bytes
+	if probe.WireBytesRead+probe.WireBytesWritten > 0 {
+		b.ReportMetric(float64(wireBytes.Load())/float64(b.N), "wire_bytes/op")
+	}
+	return probe
+}
+
+// setupJSONTestServer serves payload as JSON, gzipped if compress is set.
+// Requests are not recorded, so the server adds no per-request copies or
+// locking to the numbers.
+func setupJSONTestServer(payload []byte, compress bool) *httptest.Server {
+	server := httpclienttest.NewServer()
+	server.Record(false)
+	route := server.Handle("", "*").Header("Content-Type", "application/json").RespondBytes(http.StatusOK, payload)
+	if compress {
+		route.Gzip()
+	}
+	return server.Server
+}
+
+// Helper functions to generate different payload sizes
+func generateMediumJSON() []byte {
+	items := make([]map[string]interface{}, 20)
+	for i := 0; i < 20; i++ {
+		items[i] = map[string]interface{}{
+			"id":          i,
+			"name":        fmt.Sprintf("Item %d", i),
+			"description": fmt.Sprintf("This is item %d with some additional text to increase payload size", i),
+			"tags":        []string{"tag1", "tag2", "tag3", "tag4", "tag5"},
+			"metadata": map[string]interface{}{
+				"createdAt": time.Now().Format(time.RFC3339),
+				"updatedAt": time.Now().Format(time.RFC3339),
+				"active":    true,
+				"priority":  i % 5,
+			},
+		}
+	}
+
+	data := map[string]interface{}{
+		"items":  items,
+		"count":  len(items),
+		"status": "success",
+	}
+
+	jsonBytes, _ := json.Marshal(data)
+	return jsonBytes
+}
+
+func generateLargeJSON() []byte {
+	items := make([]map[string]interface{}, 100)
+	for i := 0; i < 100; i++ {
+		items[i] = map[string]interface{}{
+			"id":          i,
+			"uuid":        fmt.Sprintf("uuid-%d-%d", i, time.Now().UnixNano()),
+			"name":        fmt.Sprintf("Item %d", i),
+			"description": fmt.Sprintf("This is item %d with a much longer description to increase payload size substantially. Including additional text with repeated information to make it even larger.", i),
+			"tags":        []string{"tag1", "tag2", "tag3", "tag4", "tag5", "tag6", "tag7", "tag8"},
+			"metadata": map[string]interface{}{
+				"createdAt":      time.Now().Format(time.RFC3339),
+				"updatedAt":      time.Now().Format(time.RFC3339),
+				"active":         true,
+				"priority":       i % 5,
+				"category":       fmt.Sprintf("Category %d", i%10),
+				"subcategory":    fmt.Sprintf("Subcategory %d", i%20),
+				"views":          i * 100,
+				"favoriteCount":  i % 50,
+				"commentCount":   i % 25,
+				"lastModifiedBy": fmt.Sprintf("user-%d", i%15),
+				"regions":        []string{"us-east", "us-west", "eu-central", "ap-south"},
+			},
+			"details": map[string]interface{}{
+				"manufacturer": fmt.Sprintf("Company %d", i%10),
+				"origin":       fmt.Sprintf("Country %d", i%30),
+				"year":         2020 + (i % 5),
+				"dimensions": map[string]interface{}{
+					"width":  10.5 + float64(i%10),
+					"height": 20.5 + float64(i%15),
+					"depth":  5.5 + float64(i%8),
+					"weight": 2.5 + float64(i%10),
+				},
+			},
+		}
+	}
+
+	data := map[string]interface{}{
+		"items":       items,
+		"count":       len(items),
+		"status":      "success",
+		"totalPages":  10,
+		"currentPage": 1,
+		"pageSize":    100,
+		"metadata": map[string]interface{}{
+			"apiVersion": "1.0.0",
+			"timestamp":  time.Now().Format(time.RFC3339),
+		},
+	}
+
+	jsonBytes, _ := json.Marshal(data)
+	return jsonBytes
+}
@@ -0,0 +1,74 @@
+package httpclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func BenchmarkUnixSocketVsTCP(b *testing.B) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"message": "success"}`))
+	})
+
+	type transport struct {
+		name  string
+		setup func(b *testing.B) (server *httptest.Server, url string, opts []Option)
+	}
+
+	transports := []transport{
+		{
+			name: "TCP-Loopback",
+			setup: func(b *testing.B) (*httptest.Server, string, []Option) {
+				server := httptest.NewServer(handler)
+				return server, server.URL, nil
+			},
+		},
+		{
+			name: "UnixSocket",
+			setup: func(b *testing.B) (*httptest.Server, string, []Option) {
+				server, path := setupUnixTestServer(b, handler)
+				return server, "http://sidecar.internal", []Option{WithUnixSocket(path)}
+			},
+		},
+	}
+
+	ctx := context.Background()
+	headers := map[string]string{
+		"User-Agent": "Benchmark-Client",
+	}
+
+	for _, backend := range []Backend{BackendStandard, BackendFastHTTP} {
+		for _, tr := range transports {
+			for _, parallelism := range []int{1, 8, 64} {
+				b.Run(fmt.Sprintf("%s/%s/Parallelism-%d", backend, tr.name, parallelism), func(b *testing.B) {
+					server, url, opts := tr.setup(b)
+					defer server.Close()
+
+					client, err := NewClient(backend, opts...)
+					if err != nil {
+						b.Fatal(err)
+					}
+
+					b.SetParallelism(parallelism)
+					b.ResetTimer()
+					b.RunParallel(func(pb *testing.PB) {
+						for pb.Next() {
+							resp := client.Get(ctx, url, headers)
+							if resp.Error != nil {
+								b.Error(resp.Error)
+								return
+							}
+						}
+					})
+					b.StopTimer()
+
+					reportPoolStats(b, client, url)
+				})
+			}
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package httpclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/valyala/bytebufferpool"
+	"github.com/valyala/fasthttp"
+)
+
+// BufferedResponse is the response of DoBuffer. Its headers stay in the
+// backend's own response and are only read when asked for, rather than
+// copied into a map. It must be returned with Release once the headers are
+// no longer needed, and must not be used after that.
+type BufferedResponse struct {
+	StatusCode int
+	// WireBytesRead and WireBytesWritten are as in HTTPResponse
+	WireBytesRead    int64
+	WireBytesWritten int64
+
+	fast     *fasthttp.Response
+	standard http.Header
+	copied   map[string]string
+}
+
+var bufferedResponsePool = sync.Pool{
+	New: func() any { return new(BufferedResponse) },
+}
+
+// Header returns the first value of the response header key
+func (r *BufferedResponse) Header(key string) string {
+	if r.fast != nil {
+		return string(r.fast.Header.Peek(key))
+	}
+	if r.copied != nil {
+		value, _ := lookupHeader(r.copied, key)
+		return value
+	}
+	return r.standard.Get(key)
+}
+
+// VisitHeaders calls f for each response header in turn. The slices are
+// only valid during the call.
+func (r *BufferedResponse) VisitHeaders(f func(key, value []byte)) {
+	if r.fast != nil {
+		r.fast.Header.VisitAll(f)
+		return
+	}
+	var k, v []byte
+	for key, value := range r.copied {
+		k = append(k[:0], key...)
+		v = append(v[:0], value...)
+		f(k, v)
+	}
+	for key, values := range r.standard {
+		k = append(k[:0], key...)
+		for _, value := range values {
+			v = append(v[:0], value...)
+			f(k, v)
+		}
+	}
+}
+
+// Release returns the response and the backend resources it holds to their
+// pools
+func (r *BufferedResponse) Release() {
+	if r.fast != nil {
+		fasthttp.ReleaseResponse(r.fast)
+	}
+	*r = BufferedResponse{}
+	bufferedResponsePool.Put(r)
+}
+
+// DoBuffer is a low-allocation alternative to Do for hot paths. The
+// response body is appended to body, which the caller owns and can reuse
+// across requests, and headers are read lazily from the returned response.
+// req is not retained or modified, so one Request and its Headers map can be
+// reused for every call.
+//
+// It sends exactly one request: redirects are returned rather than
+// followed, and hedging and coalescing do not apply. A Signer still signs
+// the request, at the cost of a copy of it. Recording and fault injection
+// work on whole responses, so with either configured the request goes
+// through Do's path and the response is copied, losing the savings.
+func (c *Client) DoBuffer(ctx context.Context, req *Request, body *bytebufferpool.ByteBuffer) (*BufferedResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("error making request: %w", err)
+	}
+	if c.vcr != nil || c.faults != nil {
+		return c.bufferCopy(ctx, req, body)
+	}
+	if c.signer != nil {
+		req = req.Clone()
+		if err := c.signer.Sign(req); err != nil {
+			return nil, fmt.Errorf("error signing request: %w", err)
+		}
+	}
+
+	defer c.reportStats(req.URL)
+	if c.backend == BackendFastHTTP {
+		return c.bufferFastHTTP(ctx, req, body)
+	}
+	return c.bufferStandard(ctx, req, body)
+}
+
+// bufferCopy sends a single attempt the way Do does and copies the result
+// into a BufferedResponse
+func (c *Client) bufferCopy(ctx context.Context, req *Request, body *bytebufferpool.ByteBuffer) (*BufferedResponse, error) {
+	resp := c.sendOnce(ctx, req)
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+	body.Write(resp.Body)
+
+	buffered := bufferedResponsePool.Get().(*BufferedResponse)
+	buffered.StatusCode = resp.StatusCode
+	buffered.WireBytesRead = resp.WireBytesRead
+	buffered.WireBytesWritten = resp.WireBytesWritten
+	buffered.copied = resp.Headers
+	if buffered.copied == nil {
+		buffered.copied = map[string]string{}
+	}
+	return buffered, nil
+}
+
+func (c *Client) bufferStandard(ctx context.Context, r *Request, body *bytebufferpool.ByteBuffer) (*BufferedResponse, error) {
+	resp, finish, err := c.standardRoundTrip(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	_, err = body.ReadFrom(resp.Body)
+	wireRead, wireWritten := finish()
+	if err != nil {
+		return nil, fmt.Errorf("error reading body: %w", err)
+	}
+
+	buffered := bufferedResponsePool.Get().(*BufferedResponse)
+	buffered.StatusCode = resp.StatusCode
+	buffered.WireBytesRead = wireRead
+	buffered.WireBytesWritten = wireWritten
+	buffered.standard = resp.Header
+	return buffered, nil
+}
+
+func (c *Client) bufferFastHTTP(ctx context.Context, r *Request, body *bytebufferpool.ByteBuffer) (*BufferedResponse, error) {
+	req := fasthttp.AcquireRequest()
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseRequest(req)
+
+	if err := c.fastRoundTrip(ctx, r, req, resp); err != nil {
+		fasthttp.ReleaseResponse(resp)
+		return nil, err
+	}
+	body.Write(resp.Body())
+	// The body has been copied out, so the response only keeps its headers
+	resp.ResetBody()
+
+	buffered := bufferedResponsePool.Get().(*BufferedResponse)
+	buffered.StatusCode = resp.StatusCode()
+	buffered.WireBytesRead, buffered.WireBytesWritten = wireBytes(resp.LocalAddr())
+	buffered.fast = resp
+	return buffered, nil
+}
@@ -0,0 +1,257 @@
+package httpclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/valyala/bytebufferpool"
+
+	"golang-content/httpclient/httpclienttest"
+)
+
+func TestClientDoBuffer(t *testing.T) {
+	server := httpclienttest.NewServer()
+	defer server.Close()
+	server.Handle(http.MethodPost, "/echo").
+		Header("X-Request-Id", "abc").
+		ExpectHeader("X-Api-Key", "secret").
+		ExpectBody(`{"n":1}`).
+		Respond(http.StatusCreated, "created")
+	server.Handle(http.MethodGet, "/redirect").Header("Location", "/echo").Respond(http.StatusFound, "")
+
+	for _, backend := range []Backend{BackendStandard, BackendFastHTTP} {
+		t.Run(string(backend), func(t *testing.T) {
+			client, err := NewClient(backend)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx := context.Background()
+			req := &Request{
+				Method:  http.MethodPost,
+				URL:     server.URL + "/echo",
+				Headers: map[string]string{"X-Api-Key": "secret"},
+				Body:    []byte(`{"n":1}`),
+			}
+
+			body := bytebufferpool.Get()
+			defer bytebufferpool.Put(body)
+			body.WriteString("prefix:")
+
+			resp, err := client.DoBuffer(ctx, req, body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != http.StatusCreated {
+				t.Errorf("status = %d, want 201", resp.StatusCode)
+			}
+			if got := body.String(); got != "prefix:created" {
+				t.Errorf("body = %q, want the response appended to the buffer", got)
+			}
+			if got := resp.Header("X-Request-Id"); got != "abc" {
+				t.Errorf("X-Request-Id = %q, want abc", got)
+			}
+			headers := make(map[string]string)
+			resp.VisitHeaders(func(key, value []byte) {
+				headers[string(key)] = string(value)
+			})
+			if headers["X-Request-Id"] != "abc" || headers["Content-Length"] != "7" {
+				t.Errorf("visited headers = %v", headers)
+			}
+			if resp.WireBytesRead == 0 || resp.WireBytesWritten == 0 {
+				t.Errorf("wire bytes = %d read, %d written, want both counted", resp.WireBytesRead, resp.WireBytesWritten)
+			}
+			resp.Release()
+
+			// The same request and buffer serve the next call
+			body.Reset()
+			resp, err = client.DoBuffer(ctx, req, body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := body.String(); got != "created" {
+				t.Errorf("second body = %q, want created", got)
+			}
+			resp.Release()
+			if len(req.Headers) != 1 || string(req.Body) != `{"n":1}` {
+				t.Errorf("request was modified: %+v", req)
+			}
+
+			// Redirects are returned, not followed
+			resp, err = client.DoBuffer(ctx, &Request{Method: http.MethodGet, URL: server.URL + "/redirect"}, body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != http.StatusFound || resp.Header("Location") != "/echo" {
+				t.Errorf("redirect: status = %d, Location = %q", resp.StatusCode, resp.Header("Location"))
+			}
+			resp.Release()
+
+			canceled, cancel := context.WithCancel(ctx)
+			cancel()
+			if _, err := client.DoBuffer(canceled, req, body); err == nil {
+				t.Error("canceled context: got no error")
+			}
+		})
+	}
+	server.Verify(t)
+}
+
+func TestClientDoBufferFaultsAndVCR(t *testing.T) {
+	server := setupVCRTestServer()
+	defer server.Close()
+
+	for _, backend := range []Backend{BackendStandard, BackendFastHTTP} {
+		t.Run(string(backend), func(t *testing.T) {
+			ctx := context.Background()
+			body := bytebufferpool.Get()
+			defer bytebufferpool.Put(body)
+
+			injector := NewFaultInjector(Fault{Kind: FaultStatus, Probability: 1, StatusCode: http.StatusServiceUnavailable})
+			client, err := NewClient(backend, WithFaultInjector(injector))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := client.DoBuffer(ctx, &Request{Method: http.MethodGet, URL: server.URL + "/text"}, body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != http.StatusServiceUnavailable {
+				t.Errorf("with a status fault got %d, want 503", resp.StatusCode)
+			}
+			resp.Release()
+
+			// A recording made through DoBuffer replays through it too, with
+			// the server gone
+			vcrServer := setupVCRTestServer()
+			path := filepath.Join(t.TempDir(), "cassette.json")
+			for _, mode := range []VCRMode{VCRRecord, VCRReplay} {
+				if mode == VCRReplay {
+					vcrServer.Close()
+				}
+				cassette, err := LoadCassette(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				client, err := NewClient(backend, WithVCR(cassette, VCROptions{Mode: mode}))
+				if err != nil {
+					t.Fatal(err)
+				}
+				body.Reset()
+				resp, err := client.DoBuffer(ctx, &Request{Method: http.MethodPost, URL: vcrServer.URL + "/echo", Body: []byte("hi")}, body)
+				if err != nil {
+					t.Fatalf("mode %d: %v", mode, err)
+				}
+				if resp.StatusCode != http.StatusOK || body.String() != "POST hi tenant=" {
+					t.Errorf("mode %d: got %d %q", mode, resp.StatusCode, body.String())
+				}
+				resp.Release()
+			}
+		})
+	}
+}
+
+func TestClientDoBufferAllocations(t *testing.T) {
+	server := setupJSONTestServer(generateLargeJSON(), false)
+	defer server.Close()
+
+	for _, backend := range []Backend{BackendStandard, BackendFastHTTP} {
+		t.Run(string(backend), func(t *testing.T) {
+			client, err := NewClient(backend)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx := context.Background()
+			req := &Request{Method: http.MethodGet, URL: server.URL, Headers: map[string]string{"User-Agent": "test"}}
+			body := bytebufferpool.Get()
+			defer bytebufferpool.Put(body)
+
+			do := testing.AllocsPerRun(50, func() {
+				if resp := client.Do(ctx, req); resp.Error != nil {
+					t.Fatal(resp.Error)
+				}
+			})
+			buffered := testing.AllocsPerRun(50, func() {
+				body.Reset()
+				resp, err := client.DoBuffer(ctx, req, body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				resp.Release()
+			})
+			if buffered >= do {
+				t.Errorf("DoBuffer made %.0f allocations per request, Do %.0f; want fewer", buffered, do)
+			}
+		})
+	}
+}
+
+// BenchmarkBufferedAPI compares allocations of DoBuffer with Do and with
+// the package-level functions, which allocate a header map and a body per
+// call. The in-process server's allocations are counted too, so allocs/op
+// differences are the client's.
+func BenchmarkBufferedAPI(b *testing.B) {
+	ctx := context.Background()
+	apis := []string{"package", "Do", "DoBuffer"}
+
+	for _, payload := range benchPayloads {
+		server := setupJSONTestServer(payload.body(), false)
+		defer server.Close()
+		headers := map[string]string{"User-Agent": "Benchmark-Client"}
+
+		for _, backend := range benchBackends {
+			// The package API is one of the compared APIs below
+			if backend.pkg {
+				continue
+			}
+			client, err := NewClient(backend.backend, WithTimeout(benchTimeout))
+			if err != nil {
+				b.Fatal(err)
+			}
+			req := &Request{Method: http.MethodGet, URL: server.URL, Headers: headers}
+
+			for _, api := range apis {
+				b.Run(fmt.Sprintf("backend=%s/payload=%s/api=%s", backend.name, payload.name, api), func(b *testing.B) {
+					var send func() error
+					switch api {
+					case "package":
+						send = func() error {
+							if backend.backend == BackendFastHTTP {
+								return FastHTTPGet(server.URL, headers, benchTimeout).Error
+							}
+							return StandardGet(ctx, server.URL, headers, benchTimeout).Error
+						}
+					case "Do":
+						send = func() error { return client.Do(ctx, req).Error }
+					case "DoBuffer":
+						body := bytebufferpool.Get()
+						defer bytebufferpool.Put(body)
+						send = func() error {
+							body.Reset()
+							resp, err := client.DoBuffer(ctx, req, body)
+							if err != nil {
+								return err
+							}
+							resp.Release()
+							return nil
+						}
+					}
+
+					// Warms the connection pool and the buffer
+					if err := send(); err != nil {
+						b.Fatal(err)
+					}
+					b.ReportAllocs()
+					b.ResetTimer()
+					for i := 0; i < b.N; i++ {
+						if err := send(); err != nil {
+							b.Fatal(err)
+						}
+					}
+				})
+			}
+		}
+	}
+}
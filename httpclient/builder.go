@@ -0,0 +1,255 @@
+package httpclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+)
+
+// RequestBuilder builds a Request from a base URL, an RFC 6570 path
+// template, query parameters, headers and a body, escaping each part so
+// callers never concatenate URLs by hand. Methods chain, and the first error
+// is kept and returned by Build.
+//
+//	req, err := httpclient.NewRequestBuilder("https://api.example.com/v1").
+//		Path("/repos/{owner}/{repo}/issues{?state}").
+//		Param("owner", "golang").
+//		Param("repo", "go").
+//		Param("state", "open").
+//		Query("label", "bug", "help wanted").
+//		Build()
+//
+// The resulting Request runs on either backend.
+type RequestBuilder struct {
+	method  string
+	baseURL string
+	path    string
+	params  map[string]any
+	query   []queryParam
+	headers map[string]string
+	body    []byte
+	json    bool
+	err     error
+}
+
+// queryParam is one key=value pair of the query, kept in the order added
+type queryParam struct {
+	key, value string
+}
+
+// NewRequestBuilder starts a GET request relative to baseURL
+func NewRequestBuilder(baseURL string) *RequestBuilder {
+	return &RequestBuilder{
+		method:  http.MethodGet,
+		baseURL: baseURL,
+		params:  make(map[string]any),
+		headers: make(map[string]string),
+	}
+}
+
+// Method sets the request method
+func (b *RequestBuilder) Method(method string) *RequestBuilder {
+	b.method = method
+	return b
+}
+
+// Path sets the RFC 6570 template appended to the base URL, such as
+// /users/{id}/repos{?sort,page} or /files{/path*}. A template that expands
+// to an absolute URL replaces the base URL.
+func (b *RequestBuilder) Path(template string) *RequestBuilder {
+	b.path = template
+	return b
+}
+
+// Param sets a template variable. Strings, booleans, numbers, time.Time,
+// time.Duration and fmt.Stringers are single values; slices are lists and
+// maps with string keys are associative arrays. Nil leaves the variable
+// undefined, which drops it from the expansion.
+func (b *RequestBuilder) Param(name string, value any) *RequestBuilder {
+	b.params[name] = value
+	return b
+}
+
+// Query adds values to the query parameter key, after any parameters the
+// path template expands to. Each value is formatted as in Param, and
+// slices add the key once per element, so repeated keys such as
+// ?id=1&id=2 come from Query("id", 1, 2) or Query("id", []int{1, 2}).
+func (b *RequestBuilder) Query(key string, values ...any) *RequestBuilder {
+	for _, value := range values {
+		if err := b.addQuery(key, value); err != nil && b.err == nil {
+			b.err = fmt.Errorf("query parameter %s: %w", key, err)
+		}
+	}
+	return b
+}
+
+// SetQuery replaces the values of the query parameter key
+func (b *RequestBuilder) SetQuery(key string, values ...any) *RequestBuilder {
+	kept := b.query[:0]
+	for _, param := range b.query {
+		if param.key != key {
+			kept = append(kept, param)
+		}
+	}
+	b.query = kept
+	return b.Query(key, values...)
+}
+
+func (b *RequestBuilder) addQuery(key string, value any) error {
+	if s, ok := formatScalar(value); ok {
+		b.query = append(b.query, queryParam{key, s})
+		return nil
+	}
+	rv := reflect.ValueOf(value)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return fmt.Errorf("unsupported type %T", value)
+	}
+	for i := 0; i < rv.Len(); i++ {
+		s, ok := formatScalar(rv.Index(i).Interface())
+		if !ok {
+			return fmt.Errorf("unsupported list element %T", rv.Index(i).Interface())
+		}
+		b.query = append(b.query, queryParam{key, s})
+	}
+	return nil
+}
+
+// Header sets a request header. Keys are canonicalized, so setting a header
+// twice in different cases keeps only the last value.
+func (b *RequestBuilder) Header(key, value string) *RequestBuilder {
+	b.headers[http.CanonicalHeaderKey(key)] = value
+	return b
+}
+
+// Body sets the raw request body
+func (b *RequestBuilder) Body(body []byte) *RequestBuilder {
+	b.body = body
+	b.json = false
+	return b
+}
+
+// JSON sets the body to v encoded as JSON, with a Content-Type of
+// application/json unless a header sets another
+func (b *RequestBuilder) JSON(v interface{}) *RequestBuilder {
+	body, err := StandardJSONCodec.Marshal(v)
+	if err != nil && b.err == nil {
+		b.err = fmt.Errorf("error marshaling request body: %w", err)
+	}
+	b.body = body
+	b.json = true
+	return b
+}
+
+// Build returns the Request, or the first error met while building it
+func (b *RequestBuilder) Build() (*Request, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+
+	path, err := expandTemplate(b.path, b.params)
+	if err != nil {
+		return nil, fmt.Errorf("error expanding path template: %w", err)
+	}
+	target := joinURL(b.baseURL, path)
+
+	if len(b.query) > 0 {
+		target = appendQuery(target, b.query)
+	}
+	return b.request(target)
+}
+
+// appendQuery adds params to the query of target, after any it already has
+func appendQuery(target string, params []queryParam) string {
+	var query strings.Builder
+	for _, param := range params {
+		if query.Len() > 0 {
+			query.WriteByte('&')
+		}
+		query.WriteString(encodeTemplate(param.key, false))
+		query.WriteByte('=')
+		query.WriteString(encodeTemplate(param.value, false))
+	}
+
+	target, fragment, hasFragment := strings.Cut(target, "#")
+	switch {
+	case !strings.Contains(target, "?"):
+		target += "?"
+	case !strings.HasSuffix(target, "?") && !strings.HasSuffix(target, "&"):
+		target += "&"
+	}
+	target += query.String()
+	if hasFragment {
+		target += "#" + fragment
+	}
+	return target
+}
+
+func (b *RequestBuilder) request(target string) (*Request, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing url: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("url %q is not an absolute http or https URL", target)
+	}
+
+	headers := make(map[string]string, len(b.headers)+1)
+	for key, value := range b.headers {
+		headers[key] = value
+	}
+	if _, exists := headers["Content-Type"]; b.json && !exists {
+		headers["Content-Type"] = "application/json"
+	}
+	return &Request{Method: b.method, URL: target, Headers: headers, Body: b.body}, nil
+}
+
+// Do builds the request and sends it with c
+func (b *RequestBuilder) Do(ctx context.Context, c *Client) HTTPResponse {
+	req, err := b.Build()
+	if err != nil {
+		return HTTPResponse{Error: fmt.Errorf("error building request: %w", err)}
+	}
+	return c.Do(ctx, req)
+}
+
+// joinURL appends the escaped path to the path of base with exactly one
+// slash between them. A query in path follows any query base has, a fragment
+// in path replaces base's, and absolute URLs replace base. Bases that do not
+// parse are concatenated as they are, for request to reject.
+func joinURL(base, path string) string {
+	if path == "" {
+		return base
+	}
+	ref, err := url.Parse(path)
+	if err != nil {
+		return base + path
+	}
+	if ref.IsAbs() {
+		return path
+	}
+	u, err := url.Parse(base)
+	if err != nil || base == "" {
+		return base + path
+	}
+
+	if escaped := ref.EscapedPath(); escaped != "" {
+		u.RawPath = strings.TrimSuffix(u.EscapedPath(), "/") + "/" + strings.TrimPrefix(escaped, "/")
+		u.Path, err = url.PathUnescape(u.RawPath)
+		if err != nil {
+			return base + path
+		}
+	}
+	switch {
+	case u.RawQuery == "":
+		u.RawQuery = ref.RawQuery
+	case ref.RawQuery != "":
+		u.RawQuery += "&" + ref.RawQuery
+	}
+	if ref.Fragment != "" {
+		u.Fragment, u.RawFragment = ref.Fragment, ref.RawFragment
+	}
+	return u.String()
+}
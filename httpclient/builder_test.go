@@ -0,0 +1,182 @@
+package httpclient
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"golang-content/httpclient/httpclienttest"
+)
+
+func TestRequestBuilder(t *testing.T) {
+	since := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		builder *RequestBuilder
+		want    string
+	}{
+		{
+			name:    "path segments are escaped",
+			builder: NewRequestBuilder("https://api.example.com/v1/").Path("/users/{id}/files/{name}").Param("id", 42).Param("name", "a/b c?.txt"),
+			want:    "https://api.example.com/v1/users/42/files/a%2Fb%20c%3F.txt",
+		},
+		{
+			name: "typed and repeated query parameters",
+			builder: NewRequestBuilder("https://api.example.com").Path("/search").
+				Query("q", "go & rust").
+				Query("id", 1, 2).
+				Query("tag", []string{"x", "y"}).
+				Query("exact", true).
+				Query("since", since).
+				Query("ratio", 0.5),
+			want: "https://api.example.com/search?q=go%20%26%20rust&id=1&id=2&tag=x&tag=y&exact=true&since=2024-03-01T12%3A00%3A00Z&ratio=0.5",
+		},
+		{
+			name: "template query comes first",
+			builder: NewRequestBuilder("https://api.example.com").Path("/repos/{owner}/issues{?state,labels*}").
+				Param("owner", "go lang").
+				Param("state", "open").
+				Param("labels", []string{"bug", "help wanted"}).
+				Query("page", 2),
+			want: "https://api.example.com/repos/go%20lang/issues?state=open&labels=bug&labels=help%20wanted&page=2",
+		},
+		{
+			name:    "undefined template parameters are dropped",
+			builder: NewRequestBuilder("https://api.example.com").Path("/items{?cursor}").Query("limit", 10),
+			want:    "https://api.example.com/items?limit=10",
+		},
+		{
+			name:    "SetQuery replaces values",
+			builder: NewRequestBuilder("https://api.example.com?fixed=1").Query("page", 1).Query("sort", "name").SetQuery("page", 3),
+			want:    "https://api.example.com?fixed=1&sort=name&page=3",
+		},
+		{
+			name: "path joins before the base query",
+			builder: NewRequestBuilder("https://api.example.com/v1?key=1").Path("/items{?cursor}").
+				Param("cursor", "c 2").
+				Query("limit", 10),
+			want: "https://api.example.com/v1/items?key=1&cursor=c%202&limit=10",
+		},
+		{
+			name:    "path segments from a list",
+			builder: NewRequestBuilder("https://cdn.example.com").Path("{/path*}").Param("path", []string{"assets", "img 1.png"}),
+			want:    "https://cdn.example.com/assets/img%201.png",
+		},
+		{
+			name:    "absolute template replaces the base",
+			builder: NewRequestBuilder("https://api.example.com").Path("{+next}").Param("next", "https://other.example.com/page?n=2"),
+			want:    "https://other.example.com/page?n=2",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := tt.builder.Build()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if req.URL != tt.want {
+				t.Errorf("URL = %q\nwant  %q", req.URL, tt.want)
+			}
+			if req.Method != http.MethodGet {
+				t.Errorf("method = %q, want GET", req.Method)
+			}
+		})
+	}
+}
+
+func TestRequestBuilderBody(t *testing.T) {
+	req, err := NewRequestBuilder("https://api.example.com").
+		Method(http.MethodPost).
+		Path("/items").
+		Header("X-Trace", "1").
+		JSON(map[string]int{"n": 1}).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Method != http.MethodPost || string(req.Body) != `{"n":1}` {
+		t.Errorf("request = %s %s", req.Method, req.Body)
+	}
+	if req.Headers["Content-Type"] != "application/json" || req.Headers["X-Trace"] != "1" {
+		t.Errorf("headers = %v", req.Headers)
+	}
+
+	// An explicit Content-Type wins in any case, and a raw body sets none
+	req, err = NewRequestBuilder("https://api.example.com").Header("content-type", "application/merge-patch+json").JSON(map[string]int{}).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := req.Headers["Content-Type"]; got != "application/merge-patch+json" || len(req.Headers) != 1 {
+		t.Errorf("headers = %v, want only Content-Type application/merge-patch+json", req.Headers)
+	}
+	req, err = NewRequestBuilder("https://api.example.com").JSON(1).Body([]byte("raw")).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := req.Headers["Content-Type"]; ok || string(req.Body) != "raw" {
+		t.Errorf("raw body: headers = %v, body = %q", req.Headers, req.Body)
+	}
+}
+
+func TestRequestBuilderErrors(t *testing.T) {
+	tests := map[string]*RequestBuilder{
+		"bad template":        NewRequestBuilder("https://api.example.com").Path("/users/{id"),
+		"unsupported param":   NewRequestBuilder("https://api.example.com").Path("/{id}").Param("id", struct{}{}),
+		"unsupported query":   NewRequestBuilder("https://api.example.com").Query("filter", map[string]string{"a": "b"}),
+		"relative base":       NewRequestBuilder("/v1").Path("/users"),
+		"unsupported scheme":  NewRequestBuilder("ftp://example.com"),
+		"unencodable body":    NewRequestBuilder("https://api.example.com").JSON(make(chan int)),
+		"first error is kept": NewRequestBuilder("https://api.example.com").Query("a", struct{}{}).Query("b", 1),
+	}
+	for name, builder := range tests {
+		if req, err := builder.Build(); err == nil {
+			t.Errorf("%s: built %s, want an error", name, req.URL)
+		}
+	}
+}
+
+func TestRequestBuilderDo(t *testing.T) {
+	server := httpclienttest.NewServer()
+	defer server.Close()
+	server.Handle(http.MethodPut, "/users/").
+		ExpectHeader("Content-Type", "application/json").
+		ExpectBody(`{"name":"Zoë"}`).
+		Respond(http.StatusOK, "ok")
+
+	for _, backend := range []Backend{BackendStandard, BackendFastHTTP} {
+		t.Run(string(backend), func(t *testing.T) {
+			client, err := NewClient(backend)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			resp := NewRequestBuilder(server.URL).
+				Method(http.MethodPut).
+				Path("/users/{id}").
+				Param("id", "a/b").
+				Query("tag", "x y", "z").
+				JSON(map[string]string{"name": "Zoë"}).
+				Do(context.Background(), client)
+			if resp.Error != nil {
+				t.Fatal(resp.Error)
+			}
+			if resp.StatusCode != http.StatusOK {
+				t.Errorf("status = %d, want 200", resp.StatusCode)
+			}
+
+			requests := server.Requests()
+			got := requests[len(requests)-1].URL
+			if want := "/users/a%2Fb?tag=x%20y&tag=z"; got != want {
+				t.Errorf("server saw %q, want %q", got, want)
+			}
+
+			resp = NewRequestBuilder(server.URL).Path("/{bad").Do(context.Background(), client)
+			if resp.Error == nil {
+				t.Error("bad template: got no error")
+			}
+		})
+	}
+	server.Verify(t)
+}
@@ -0,0 +1,597 @@
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"strings"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// Backend identifies the HTTP implementation a Client sends requests through
+type Backend string
+
+const (
+	// BackendStandard sends requests with the net/http package
+	BackendStandard Backend = "net/http"
+	// BackendFastHTTP sends requests with the fasthttp package
+	BackendFastHTTP Backend = "fasthttp"
+)
+
+// Request is a backend-neutral description of an outgoing HTTP request
+type Request struct {
+	Method  string
+	URL     string
+	Headers map[string]string
+	Body    []byte
+}
+
+// Clone returns a copy of the request that can be modified without
+// affecting the original
+func (r *Request) Clone() *Request {
+	clone := &Request{
+		Method:  r.Method,
+		URL:     r.URL,
+		Headers: make(map[string]string, len(r.Headers)),
+		Body:    r.Body,
+	}
+	for key, value := range r.Headers {
+		clone.Headers[key] = value
+	}
+	return clone
+}
+
+// Signer adds authentication material to a Request before it is sent
+type Signer interface {
+	Sign(req *Request) error
+}
+
+// clientConfig collects the settings applied by Options before the
+// underlying backend client is built
+type clientConfig struct {
+	timeout             time.Duration
+	signer              Signer
+	tlsConfig           *tls.Config
+	tlsVerify           tlsHostVerifier
+	proxy               ProxyFunc
+	http2               http2Mode
+	maxConnsPerHost     int
+	maxIdleConnsPerHost int
+	hedger              *hedger
+	coalescer           *coalescer
+	codec               Codec
+	redirects           RedirectPolicy
+	resolver            Resolver
+	dialer              dialFunc
+	vcr                 *vcr
+	faults              *FaultInjector
+	statsHook           StatsHook
+}
+
+// dialFunc is the context-aware dial signature shared by both backends
+type dialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// dialContext layers the configured dialing behaviour over the backend's
+// base dialer for connections that carry requests with the given scheme
+func (cfg *clientConfig) dialContext(base dialFunc, scheme string) dialFunc {
+	dial := base
+	if cfg.resolver != nil {
+		dial = (&resolvingDialer{resolver: cfg.resolver, dial: dial}).DialContext
+	}
+	if cfg.proxy != nil {
+		dial = (&proxyDialer{proxy: cfg.proxy, scheme: scheme, dial: dial}).DialContext
+	}
+	return dial
+}
+
+// Option configures a Client created by NewClient
+type Option func(*clientConfig) error
+
+// WithTimeout sets the per-request timeout used when the context has no deadline
+func WithTimeout(timeout time.Duration) Option {
+	return func(cfg *clientConfig) error {
+		if timeout <= 0 {
+			return fmt.Errorf("timeout must be positive, got %s", timeout)
+		}
+		cfg.timeout = timeout
+		return nil
+	}
+}
+
+// WithSigner signs every request with the given Signer just before it is sent
+func WithSigner(signer Signer) Option {
+	return func(cfg *clientConfig) error {
+		cfg.signer = signer
+		return nil
+	}
+}
+
+// WithMaxConnsPerHost limits the connections open to a single host. Defaults
+// to unlimited for net/http and 1000 for fasthttp.
+func WithMaxConnsPerHost(n int) Option {
+	return func(cfg *clientConfig) error {
+		if n <= 0 {
+			return fmt.Errorf("max conns per host must be positive, got %d", n)
+		}
+		cfg.maxConnsPerHost = n
+		return nil
+	}
+}
+
+// WithMaxIdleConnsPerHost limits the idle connections kept per host by the
+// standard backend; fasthttp keeps every connection up to MaxConnsPerHost.
+// Defaults to 100.
+func WithMaxIdleConnsPerHost(n int) Option {
+	return func(cfg *clientConfig) error {
+		if n <= 0 {
+			return fmt.Errorf("max idle conns per host must be positive, got %d", n)
+		}
+		cfg.maxIdleConnsPerHost = n
+		return nil
+	}
+}
+
+// Client sends Requests through either the net/http or the fasthttp backend
+// using the same settings as the package-level clients
+type Client struct {
+	backend   Backend
+	timeout   time.Duration
+	codec     Codec
+	signer    Signer
+	hedger    *hedger
+	coalescer *coalescer
+	redirects RedirectPolicy
+	proxy     ProxyFunc
+	vcr       *vcr
+	faults    *FaultInjector
+	stats     *poolStats
+	statsHook StatsHook
+
+	standard       *http.Client
+	standardStream *http.Client
+	fast           *fasthttp.Client
+	fastStream     *fasthttp.Client
+}
+
+// NewClient creates a Client for the given backend
+func NewClient(backend Backend, opts ...Option) (*Client, error) {
+	cfg := &clientConfig{
+		timeout:             10 * time.Second,
+		maxIdleConnsPerHost: 100,
+		codec:               StandardJSONCodec,
+		redirects:           DefaultRedirectPolicy,
+	}
+	for _, opt := range opts {
+		if err := opt(cfg); err != nil {
+			return nil, fmt.Errorf("error applying option: %w", err)
+		}
+	}
+
+	c := &Client{
+		backend:   backend,
+		timeout:   cfg.timeout,
+		codec:     cfg.codec,
+		signer:    cfg.signer,
+		hedger:    cfg.hedger,
+		coalescer: cfg.coalescer,
+		redirects: cfg.redirects,
+		proxy:     cfg.proxy,
+		vcr:       cfg.vcr,
+		faults:    cfg.faults,
+		stats:     &poolStats{},
+		statsHook: cfg.statsHook,
+	}
+
+	switch backend {
+	case BackendStandard:
+		var base dialFunc = (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}).DialContext
+		if cfg.dialer != nil {
+			base = cfg.dialer
+		}
+		transport := &http.Transport{
+			MaxIdleConns:        1000,
+			MaxIdleConnsPerHost: cfg.maxIdleConnsPerHost,
+			MaxConnsPerHost:     cfg.maxConnsPerHost,
+			IdleConnTimeout:     30 * time.Second,
+			DisableCompression:  false,
+			ForceAttemptHTTP2:   false,
+			TLSClientConfig:     cfg.tlsConfig,
+			DialContext:         c.stats.dialer(cfg.dialContext(base, "http"), backend),
+		}
+		if cfg.tlsVerify != nil || cfg.proxy != nil {
+			// The handshake is done here so the chain is verified against
+			// the dialed host, which the transport cannot pass on, and so
+			// that https connections select their proxy by scheme
+			dial := c.stats.dialer(cfg.dialContext(base, "https"), backend)
+			transport.DialTLSContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+				return dialTLS(ctx, dial, transport.TLSClientConfig, cfg.tlsVerify, network, addr)
+			}
+		}
+		roundTripper := configureHTTP2(transport, cfg.http2)
+		// Redirects are followed by the Client itself so that both backends
+		// apply the same RedirectPolicy
+		c.standard = &http.Client{
+			Timeout:       cfg.timeout,
+			Transport:     roundTripper,
+			CheckRedirect: stopRedirects,
+		}
+		// Streams outlive any fixed timeout, so they share the transport
+		// without the client-wide deadline
+		c.standardStream = &http.Client{Transport: roundTripper, CheckRedirect: stopRedirects}
+	case BackendFastHTTP:
+		if cfg.http2 != http2Disabled {
+			return nil, fmt.Errorf("backend %s does not support HTTP/2", backend)
+		}
+		maxConns := cfg.maxConnsPerHost
+		if maxConns == 0 {
+			maxConns = 1000
+		}
+		base := fasthttpDial
+		if cfg.dialer != nil {
+			base = cfg.dialer
+		}
+		dial := c.stats.dialer(cfg.dialContext(base, "http"), backend)
+		tlsDial := c.stats.dialer(cfg.dialContext(base, "https"), backend)
+		c.fast = newFastHTTPClient(cfg, maxConns, dial, tlsDial, cfg.timeout)
+		// fasthttp sets fixed read and write deadlines per request, so
+		// streams use a client without them
+		c.fastStream = newFastHTTPClient(cfg, maxConns, dial, tlsDial, 0)
+		// Bodies with a Content-Length are only streamed when they exceed
+		// the size limit; smaller ones are still read up front
+		c.fastStream.MaxResponseBodySize = 64 << 10
+	default:
+		return nil, fmt.Errorf("unknown backend %q", backend)
+	}
+
+	return c, nil
+}
+
+// newFastHTTPClient builds a fasthttp client that opens connections for
+// http requests with dial and for https requests with tlsDial
+func newFastHTTPClient(cfg *clientConfig, maxConns int, dial, tlsDial dialFunc, timeout time.Duration) *fasthttp.Client {
+	return &fasthttp.Client{
+		MaxConnsPerHost:          maxConns,
+		ReadTimeout:              timeout,
+		WriteTimeout:             timeout,
+		NoDefaultUserAgentHeader: true,
+		DisablePathNormalizing:   true,
+		TLSConfig:                cfg.tlsConfig,
+		DialTimeout:              fastDialTimeout(dial),
+		ConfigureClient: func(hc *fasthttp.HostClient) error {
+			if !hc.IsTLS {
+				return nil
+			}
+			hc.DialTimeout = fastDialTimeout(tlsDial)
+			if cfg.tlsVerify != nil {
+				host, _, err := net.SplitHostPort(hc.Addr)
+				if err != nil {
+					host = hc.Addr
+				}
+				hc.TLSConfig = configForHost(hc.TLSConfig, host, cfg.tlsVerify)
+			}
+			return nil
+		},
+	}
+}
+
+// fastDialTimeout adapts dial to fasthttp's dial signature
+func fastDialTimeout(dial dialFunc) fasthttp.DialFuncWithTimeout {
+	return func(addr string, timeout time.Duration) (net.Conn, error) {
+		// fasthttp passes a zero timeout when the request has no deadline
+		if timeout <= 0 {
+			return dial(context.Background(), "tcp", addr)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		return dial(ctx, "tcp", addr)
+	}
+}
+
+// dialTLS dials addr and completes a TLS handshake that verifies the server
+// against the dialed host
+func dialTLS(ctx context.Context, dial dialFunc, config *tls.Config, verify tlsHostVerifier, network, addr string) (net.Conn, error) {
+	conn, err := dial(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	tlsConn := tls.Client(conn, configForHost(config, host, verify))
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return tlsConn, nil
+}
+
+// fasthttpDial adapts fasthttp's default dialer, which caches DNS lookups,
+// to dialFunc
+func fasthttpDial(ctx context.Context, network, addr string) (net.Conn, error) {
+	if deadline, ok := ctx.Deadline(); ok {
+		return fasthttp.DialTimeout(addr, time.Until(deadline))
+	}
+	return fasthttp.Dial(addr)
+}
+
+// Backend reports which HTTP implementation the client uses
+func (c *Client) Backend() Backend {
+	return c.backend
+}
+
+// Get makes a GET request
+func (c *Client) Get(ctx context.Context, url string, headers map[string]string) HTTPResponse {
+	return c.Do(ctx, &Request{Method: http.MethodGet, URL: url, Headers: headers})
+}
+
+// Post makes a POST request with body marshaled by the client's codec
+func (c *Client) Post(ctx context.Context, url string, headers map[string]string, body interface{}) HTTPResponse {
+	req := &Request{Method: http.MethodPost, URL: url, Headers: headers}
+
+	if body != nil {
+		bodyBytes, err := c.codec.Marshal(body)
+		if err != nil {
+			return HTTPResponse{Error: fmt.Errorf("error marshaling request body: %w", err)}
+		}
+		req = req.Clone()
+		req.Body = bodyBytes
+		if _, exists := req.Headers["Content-Type"]; !exists {
+			req.Headers["Content-Type"] = "application/json"
+		}
+	}
+
+	return c.Do(ctx, req)
+}
+
+// Do sends the request through the client's backend, sharing it with
+// identical in-flight requests when coalescing is enabled and hedging it when
+// a HedgePolicy is configured
+func (c *Client) Do(ctx context.Context, req *Request) HTTPResponse {
+	if err := ctx.Err(); err != nil {
+		return HTTPResponse{Error: fmt.Errorf("error making request: %w", err)}
+	}
+
+	if c.coalescer != nil && c.coalescer.applies(req) {
+		return c.coalescer.do(ctx, req, c.hedge)
+	}
+	return c.hedge(ctx, req)
+}
+
+// hedge sends the request, hedging it when a HedgePolicy is configured
+func (c *Client) hedge(ctx context.Context, req *Request) HTTPResponse {
+	if c.hedger != nil && c.hedger.applies(req) {
+		return c.hedger.do(ctx, req, c.send)
+	}
+	return c.send(ctx, req)
+}
+
+// send performs one attempt on the backend, following redirects as the
+// RedirectPolicy allows
+func (c *Client) send(ctx context.Context, req *Request) HTTPResponse {
+	var chain []Redirect
+	var wireRead, wireWritten int64
+	for {
+		resp := c.sendOnce(ctx, req)
+		wireRead += resp.WireBytesRead
+		wireWritten += resp.WireBytesWritten
+		resp.WireBytesRead, resp.WireBytesWritten = wireRead, wireWritten
+		if resp.Error != nil {
+			resp.Redirects = chain
+			return resp
+		}
+
+		next, err := c.redirects.next(req, resp.StatusCode, resp.Headers, len(chain))
+		if err != nil {
+			resp.Redirects = chain
+			resp.Error = err
+			return resp
+		}
+		if next == nil {
+			resp.Redirects = chain
+			return resp
+		}
+		chain = append(chain, Redirect{URL: req.URL, StatusCode: resp.StatusCode, Location: next.URL})
+		req = next
+	}
+}
+
+// sendOnce signs the request if a Signer is configured and sends it to the
+// backend without following redirects
+func (c *Client) sendOnce(ctx context.Context, req *Request) HTTPResponse {
+	if c.signer != nil {
+		req = req.Clone()
+		if err := c.signer.Sign(req); err != nil {
+			return HTTPResponse{Error: fmt.Errorf("error signing request: %w", err)}
+		}
+	}
+
+	if c.faults != nil {
+		return c.faults.do(ctx, c, req, c.deliver)
+	}
+	return c.deliver(ctx, req)
+}
+
+// deliver sends the request to the backend, or answers it from the
+// cassette when one is configured
+func (c *Client) deliver(ctx context.Context, req *Request) HTTPResponse {
+	if c.vcr != nil {
+		return c.vcr.do(ctx, req, c.roundTrip)
+	}
+	return c.roundTrip(ctx, req)
+}
+
+// roundTrip sends the request to the backend as it is
+func (c *Client) roundTrip(ctx context.Context, req *Request) HTTPResponse {
+	defer c.reportStats(req.URL)
+	if c.backend == BackendFastHTTP {
+		return c.doFastHTTP(ctx, req)
+	}
+	return c.doStandard(ctx, req)
+}
+
+func (c *Client) doStandard(ctx context.Context, r *Request) HTTPResponse {
+	resp, finish, err := c.standardRoundTrip(ctx, r)
+	if err != nil {
+		return HTTPResponse{Error: err}
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	wireRead, wireWritten := finish()
+	if err != nil {
+		return HTTPResponse{Error: fmt.Errorf("error reading body: %w", err)}
+	}
+
+	respHeaders := make(map[string]string)
+	for key, values := range resp.Header {
+		if key == "Link" {
+			respHeaders[key] = strings.Join(values, ", ")
+		} else if len(values) > 0 {
+			respHeaders[key] = values[0]
+		}
+	}
+
+	return HTTPResponse{
+		StatusCode:       resp.StatusCode,
+		Body:             body,
+		Headers:          respHeaders,
+		WireBytesRead:    wireRead,
+		WireBytesWritten: wireWritten,
+	}
+}
+
+// standardRoundTrip sends r on the net/http backend, keeping the pool
+// statistics. Once the body has been read the caller must call finish,
+// which ends the request's accounting and returns the bytes it moved.
+func (c *Client) standardRoundTrip(ctx context.Context, r *Request) (resp *http.Response, finish func() (wireRead, wireWritten int64), err error) {
+	var bodyReader io.Reader
+	if r.Body != nil {
+		bodyReader = bytes.NewReader(r.Body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, r.Method, r.URL, bodyReader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error creating request: %w", err)
+	}
+
+	for key, value := range r.Headers {
+		req.Header.Set(key, value)
+	}
+	proxyURL := c.forwardProxy(r.URL)
+	if proxyURL != nil {
+		forwardStandard(req, proxyURL)
+	}
+
+	counters := c.stats.host(requestHostPort(r.URL))
+	var getConn time.Time
+	var gotConn bool
+	var localAddr net.Addr
+	trace := &httptrace.ClientTrace{
+		GetConn: func(string) { getConn = time.Now() },
+		GotConn: func(info httptrace.GotConnInfo) {
+			counters.waitTime.Add(int64(time.Since(getConn)))
+			counters.active.Add(1)
+			gotConn = true
+			// Starts the connection's byte count for this request
+			localAddr = beginExchange(info.Conn)
+		},
+	}
+	req = req.WithContext(httptrace.WithClientTrace(ctx, trace))
+	finish = func() (int64, int64) {
+		if gotConn {
+			counters.active.Add(-1)
+		}
+		return wireBytes(localAddr)
+	}
+
+	resp, err = c.standard.Do(req)
+	if err != nil {
+		finish()
+		return nil, nil, fmt.Errorf("error making request: %w", err)
+	}
+	if proxyURL != nil && resp.StatusCode == http.StatusProxyAuthRequired {
+		resp.Body.Close()
+		finish()
+		return nil, nil, fmt.Errorf("error making request: %w", ErrProxyAuth)
+	}
+	return resp, finish, nil
+}
+
+func (c *Client) doFastHTTP(ctx context.Context, r *Request) HTTPResponse {
+	req := fasthttp.AcquireRequest()
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseRequest(req)
+	defer fasthttp.ReleaseResponse(resp)
+
+	if err := c.fastRoundTrip(ctx, r, req, resp); err != nil {
+		return HTTPResponse{Error: err}
+	}
+
+	respHeaders := make(map[string]string)
+	resp.Header.VisitAll(func(key, value []byte) {
+		if prev, ok := respHeaders["Link"]; ok && string(key) == "Link" {
+			respHeaders["Link"] = prev + ", " + string(value)
+			return
+		}
+		respHeaders[string(key)] = string(value)
+	})
+	wireRead, wireWritten := wireBytes(resp.LocalAddr())
+
+	// The response is released on return, so the body must be copied out
+	return HTTPResponse{
+		StatusCode:       resp.StatusCode(),
+		Body:             append([]byte(nil), resp.Body()...),
+		Headers:          respHeaders,
+		WireBytesRead:    wireRead,
+		WireBytesWritten: wireWritten,
+	}
+}
+
+// fastRoundTrip sends r on the fasthttp backend using req and resp, keeping
+// the pool statistics
+func (c *Client) fastRoundTrip(ctx context.Context, r *Request, req *fasthttp.Request, resp *fasthttp.Response) error {
+	req.SetRequestURI(r.URL)
+	req.Header.SetMethod(r.Method)
+
+	for key, value := range r.Headers {
+		req.Header.Set(key, value)
+	}
+
+	if r.Body != nil {
+		req.SetBody(r.Body)
+	}
+	proxyURL := c.forwardProxy(r.URL)
+	if proxyURL != nil {
+		forwardFastHTTP(req, proxyURL)
+	}
+
+	counters := c.stats.host(requestHostPort(r.URL))
+	counters.active.Add(1)
+
+	var err error
+	if deadline, ok := ctx.Deadline(); ok {
+		err = c.fast.DoDeadline(req, resp, deadline)
+	} else {
+		err = c.fast.DoTimeout(req, resp, c.timeout)
+	}
+	counters.active.Add(-1)
+	if err != nil {
+		if errors.Is(err, fasthttp.ErrNoFreeConns) {
+			counters.poolExhausted.Add(1)
+		}
+		return fmt.Errorf("error making request: %w", err)
+	}
+	if proxyURL != nil && resp.StatusCode() == http.StatusProxyAuthRequired {
+		return fmt.Errorf("error making request: %w", ErrProxyAuth)
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package httpclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// coalesceCredentialHeaders are always part of the coalescing key, so that
+// requests made with different credentials never share a response
+var coalesceCredentialHeaders = []string{"Authorization", "Cookie"}
+
+// WithCoalescing collapses concurrent identical GET requests into a single
+// upstream call. Requests are identical when their URL, Authorization and
+// Cookie headers and the values of the listed headers match; every caller
+// receives its own copy of the response. Credentials carried anywhere else,
+// such as an API key header, must be listed, or callers with different keys
+// will share one response.
+func WithCoalescing(keyHeaders ...string) Option {
+	return func(cfg *clientConfig) error {
+		names := append([]string(nil), coalesceCredentialHeaders...)
+		for _, name := range keyHeaders {
+			names = append(names, http.CanonicalHeaderKey(name))
+		}
+		sort.Strings(names)
+		names = slices.Compact(names)
+		cfg.coalescer = &coalescer{keyHeaders: names, calls: make(map[string]*coalescedCall)}
+		return nil
+	}
+}
+
+// coalescer tracks the in-flight call for each request key
+type coalescer struct {
+	keyHeaders []string
+
+	mu    sync.Mutex
+	calls map[string]*coalescedCall
+}
+
+// coalescedCall is one upstream request shared by every waiter with its key
+type coalescedCall struct {
+	done    chan struct{}
+	resp    HTTPResponse
+	waiters int
+	cancel  context.CancelFunc
+}
+
+func (c *coalescer) applies(req *Request) bool {
+	return req.Method == http.MethodGet
+}
+
+func (c *coalescer) key(req *Request) string {
+	var key strings.Builder
+	key.WriteString(req.URL)
+	for _, name := range c.keyHeaders {
+		value, _ := lookupHeader(req.Headers, name)
+		key.WriteString("\n" + name + ":" + value)
+	}
+	return key.String()
+}
+
+// do joins the in-flight call for the request's key or starts one. The shared
+// call keeps running while any caller is still waiting and is cancelled once
+// every caller has given up.
+func (c *coalescer) do(ctx context.Context, req *Request, send func(context.Context, *Request) HTTPResponse) HTTPResponse {
+	key := c.key(req)
+
+	c.mu.Lock()
+	call, ok := c.calls[key]
+	if !ok {
+		callCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		call = &coalescedCall{done: make(chan struct{}), cancel: cancel}
+		c.calls[key] = call
+
+		go func() {
+			call.resp = send(callCtx, req)
+			cancel()
+
+			c.mu.Lock()
+			// An abandoned call is replaced by a new one under the same key
+			if c.calls[key] == call {
+				delete(c.calls, key)
+			}
+			c.mu.Unlock()
+			close(call.done)
+		}()
+	}
+	call.waiters++
+	c.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return copyResponse(call.resp)
+	case <-ctx.Done():
+		c.mu.Lock()
+		call.waiters--
+		if call.waiters == 0 {
+			// Callers arriving from now on must not join the cancelled call
+			call.cancel()
+			if c.calls[key] == call {
+				delete(c.calls, key)
+			}
+		}
+		c.mu.Unlock()
+		return HTTPResponse{Error: fmt.Errorf("error making request: %w", ctx.Err())}
+	}
+}
+
+// copyResponse returns a response whose body and headers share no memory
+// with resp
+func copyResponse(resp HTTPResponse) HTTPResponse {
+	clone := resp
+	if resp.Body != nil {
+		clone.Body = append([]byte(nil), resp.Body...)
+	}
+	if resp.Headers != nil {
+		clone.Headers = make(map[string]string, len(resp.Headers))
+		for key, value := range resp.Headers {
+			clone.Headers[key] = value
+		}
+	}
+	if resp.Redirects != nil {
+		clone.Redirects = append([]Redirect(nil), resp.Redirects...)
+	}
+	return clone
+}
@@ -0,0 +1,187 @@
+package httpclient
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// setupGatedTestServer creates a server that holds every request until
+// release is closed and counts the requests it receives
+func setupGatedTestServer(release <-chan struct{}, calls *atomic.Int64) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		<-release
+		w.Header().Set("X-Tenant", r.Header.Get("X-Tenant"))
+		w.Write([]byte(`{"flags": {"beta": true}}`))
+	}))
+}
+
+func TestClientCoalescesIdenticalGets(t *testing.T) {
+	for _, backend := range []Backend{BackendStandard, BackendFastHTTP} {
+		t.Run(string(backend), func(t *testing.T) {
+			var calls atomic.Int64
+			release := make(chan struct{})
+			server := setupGatedTestServer(release, &calls)
+			defer server.Close()
+
+			client, err := NewClient(backend, WithCoalescing("X-Tenant"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			const callers = 10
+			responses := make([]HTTPResponse, callers)
+			var wg sync.WaitGroup
+			for i := 0; i < callers; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					tenant := "a"
+					if i%2 == 1 {
+						tenant = "b"
+					}
+					responses[i] = client.Get(context.Background(), server.URL+"/flags", map[string]string{"X-Tenant": tenant})
+				}(i)
+			}
+
+			// Let every caller join an in-flight call before the server answers
+			for calls.Load() < 2 {
+				time.Sleep(time.Millisecond)
+			}
+			time.Sleep(20 * time.Millisecond)
+			close(release)
+			wg.Wait()
+
+			if got := calls.Load(); got != 2 {
+				t.Errorf("server saw %d requests, want one per tenant", got)
+			}
+			for i, resp := range responses {
+				if resp.Error != nil {
+					t.Fatal(resp.Error)
+				}
+				want := map[int]string{0: "a", 1: "b"}[i%2]
+				if resp.Headers["X-Tenant"] != want {
+					t.Errorf("caller %d got tenant %q, want %q", i, resp.Headers["X-Tenant"], want)
+				}
+			}
+
+			// Each caller owns its copy
+			responses[0].Body[0] = 'X'
+			responses[0].Headers["X-Tenant"] = "mutated"
+			if responses[2].Body[0] != '{' || responses[2].Headers["X-Tenant"] != "a" {
+				t.Error("responses share memory between callers")
+			}
+		})
+	}
+}
+
+func TestClientCoalescingCallerCancel(t *testing.T) {
+	var calls atomic.Int64
+	release := make(chan struct{})
+	server := setupGatedTestServer(release, &calls)
+	defer server.Close()
+
+	client, err := NewClient(BackendStandard, WithCoalescing())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	leaderCtx, cancelLeader := context.WithCancel(context.Background())
+	leader := make(chan HTTPResponse)
+	go func() { leader <- client.Get(leaderCtx, server.URL, nil) }()
+	for calls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	follower := make(chan HTTPResponse)
+	go func() { follower <- client.Get(context.Background(), server.URL, nil) }()
+	time.Sleep(20 * time.Millisecond)
+
+	// The caller that started the call gives up, but the follower still gets the response
+	cancelLeader()
+	if resp := <-leader; resp.Error == nil {
+		t.Error("cancelled caller should get an error")
+	}
+	close(release)
+	if resp := <-follower; resp.Error != nil || resp.StatusCode != http.StatusOK {
+		t.Errorf("follower got %d, %v", resp.StatusCode, resp.Error)
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("server saw %d requests, want 1", got)
+	}
+}
+
+func TestClientCoalescingAfterCancel(t *testing.T) {
+	var calls atomic.Int64
+	release := make(chan struct{})
+	server := setupGatedTestServer(release, &calls)
+	defer server.Close()
+
+	client, err := NewClient(BackendStandard, WithCoalescing())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan HTTPResponse)
+	go func() { first <- client.Get(ctx, server.URL, nil) }()
+	for calls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	if resp := <-first; resp.Error == nil {
+		t.Fatal("cancelled caller should get an error")
+	}
+
+	// The abandoned call may still be finishing, but a new caller starts its own
+	second := make(chan HTTPResponse)
+	go func() { second <- client.Get(context.Background(), server.URL, nil) }()
+	deadline := time.Now().Add(time.Second)
+	for calls.Load() < 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	if resp := <-second; resp.Error != nil || resp.StatusCode != http.StatusOK {
+		t.Errorf("second caller got %d, %v", resp.StatusCode, resp.Error)
+	}
+}
+
+func TestClientCoalescingKeysOnCredentials(t *testing.T) {
+	var calls atomic.Int64
+	release := make(chan struct{})
+	server := setupGatedTestServer(release, &calls)
+	defer server.Close()
+
+	client, err := NewClient(BackendStandard, WithCoalescing())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for _, headers := range []map[string]string{
+		{"Authorization": "Bearer alice"},
+		{"Authorization": "Bearer bob"},
+		{"Cookie": "session=alice"},
+	} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			client.Get(context.Background(), server.URL, headers)
+		}()
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for calls.Load() < 3 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+	if got := calls.Load(); got != 3 {
+		t.Errorf("server saw %d requests, want one per set of credentials", got)
+	}
+}
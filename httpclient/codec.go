@@ -0,0 +1,38 @@
+package httpclient
+
+import (
+	"encoding/json"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// Codec marshals request bodies and unmarshals response bodies
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+type standardJSONCodec struct{}
+
+func (standardJSONCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (standardJSONCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+var (
+	// StandardJSONCodec uses encoding/json and is the default
+	StandardJSONCodec Codec = standardJSONCodec{}
+	// JSONIterCodec uses json-iterator configured to match encoding/json
+	JSONIterCodec Codec = jsoniter.ConfigCompatibleWithStandardLibrary
+)
+
+// WithCodec sets the codec used for Post bodies and decoded responses
+func WithCodec(codec Codec) Option {
+	return func(cfg *clientConfig) error {
+		cfg.codec = codec
+		return nil
+	}
+}
@@ -0,0 +1,31 @@
+package httpclient
+
+import (
+	"context"
+	"fmt"
+	"net"
+)
+
+// WithDialer replaces the backend's TCP dialer with dial on either backend.
+// Requests keep their http:// or https:// URLs, whose host:port is passed to
+// dial as addr and still decides connection pooling, TLS server names and
+// proxy selection. A Resolver or proxy configured alongside is layered over
+// dial.
+func WithDialer(dial func(ctx context.Context, network, addr string) (net.Conn, error)) Option {
+	return func(cfg *clientConfig) error {
+		if dial == nil {
+			return fmt.Errorf("dialer must not be nil")
+		}
+		cfg.dialer = dial
+		return nil
+	}
+}
+
+// WithUnixSocket sends every request over the Unix domain socket at path,
+// whatever host the URL names
+func WithUnixSocket(path string) Option {
+	var dialer net.Dialer
+	return WithDialer(func(ctx context.Context, _, _ string) (net.Conn, error) {
+		return dialer.DialContext(ctx, "unix", path)
+	})
+}
@@ -0,0 +1,144 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// setupUnixTestServer serves handler on a Unix socket and returns the server
+// with the socket path
+func setupUnixTestServer(tb testing.TB, handler http.Handler) (*httptest.Server, string) {
+	// Socket paths are limited to about 100 bytes, which t.TempDir can exceed
+	dir, err := os.MkdirTemp("", "uds")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { os.RemoveAll(dir) })
+
+	path := filepath.Join(dir, "http.sock")
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	server := httptest.NewUnstartedServer(handler)
+	server.Listener.Close()
+	server.Listener = listener
+	server.Start()
+	return server, path
+}
+
+func TestUnixSocket(t *testing.T) {
+	server, path := setupUnixTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Host + r.URL.Path))
+	}))
+	defer server.Close()
+
+	for _, backend := range []Backend{BackendStandard, BackendFastHTTP} {
+		t.Run(string(backend), func(t *testing.T) {
+			client, err := NewClient(backend, WithUnixSocket(path))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for range 3 {
+				resp := client.Get(context.Background(), "http://sidecar.internal/status", nil)
+				if resp.Error != nil {
+					t.Fatal(resp.Error)
+				}
+				if string(resp.Body) != "sidecar.internal/status" {
+					t.Errorf("body = %q, want the URL host and path", resp.Body)
+				}
+			}
+
+			stats := client.Stats()["sidecar.internal:80"]
+			if stats.Created != 1 {
+				t.Errorf("connections created = %d, want 1 reused connection", stats.Created)
+			}
+		})
+	}
+}
+
+func TestUnixSocketStreamCancel(t *testing.T) {
+	server, path := setupUnixTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("data: hello\n\n"))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	for _, backend := range []Backend{BackendStandard, BackendFastHTTP} {
+		t.Run(string(backend), func(t *testing.T) {
+			client, err := NewClient(backend, WithUnixSocket(path))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			firstCtx, cancelFirst := context.WithCancel(context.Background())
+			defer cancelFirst()
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			// Two streams share the socket's local address, and cancelling
+			// the second must not disturb the first
+			first, firstErrs := client.StreamEvents(firstCtx, &Request{Method: http.MethodGet, URL: "http://sidecar.internal/a"}, SSEOptions{})
+			<-first
+			events, errs := client.StreamEvents(ctx, &Request{Method: http.MethodGet, URL: "http://sidecar.internal/b"}, SSEOptions{})
+			<-events
+			cancel()
+
+			select {
+			case err := <-errs:
+				if !errors.Is(err, context.Canceled) {
+					t.Errorf("err = %v, want context.Canceled", err)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("stream did not stop after cancel")
+			}
+			select {
+			case err := <-firstErrs:
+				t.Errorf("first stream ended: %v", err)
+			default:
+			}
+			if open := client.Stats()["sidecar.internal:80"].Open; open != 1 {
+				t.Errorf("open connections = %d, want 1", open)
+			}
+		})
+	}
+}
+
+func TestWithDialer(t *testing.T) {
+	server := setupTestServer()
+	defer server.Close()
+
+	for _, backend := range []Backend{BackendStandard, BackendFastHTTP} {
+		t.Run(string(backend), func(t *testing.T) {
+			var dialed atomic.Value
+			var dialer net.Dialer
+			client, err := NewClient(backend, WithDialer(func(ctx context.Context, network, addr string) (net.Conn, error) {
+				dialed.Store(addr)
+				return dialer.DialContext(ctx, "tcp", server.Listener.Addr().String())
+			}))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			resp := client.Get(context.Background(), "http://service.internal:8080/", nil)
+			if resp.Error != nil || resp.StatusCode != http.StatusOK {
+				t.Fatalf("got %d, %v", resp.StatusCode, resp.Error)
+			}
+			if addr, _ := dialed.Load().(string); addr != "service.internal:8080" {
+				t.Errorf("dialer got addr %q, want the URL's host:port", addr)
+			}
+		})
+	}
+}
@@ -0,0 +1,436 @@
+package httpclient
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DownloadOptions configures Download
+type DownloadOptions struct {
+	// Parallelism is the number of byte ranges fetched at once when the
+	// server supports range requests and reports the file size; defaults to 1
+	Parallelism int
+	// MinChunkSize keeps a parallel download from splitting the file into
+	// ranges smaller than this; defaults to 8 MiB
+	MinChunkSize int64
+	// Retries is how many times an interrupted transfer is resumed before
+	// giving up; defaults to 3
+	Retries int
+	// Checksum is the expected hex digest of the complete file
+	Checksum string
+	// Hash computes the digest compared with Checksum; defaults to SHA-256
+	Hash func() hash.Hash
+	// Progress is called after every write with the bytes on disk so far and
+	// the total size, or -1 while the size is unknown. Calls are serialized.
+	Progress func(written, total int64)
+}
+
+var (
+	// ErrSizeMismatch is returned when the downloaded file is not the size
+	// the server announced
+	ErrSizeMismatch = errors.New("download: size mismatch")
+	// ErrChecksumMismatch is returned when the downloaded file does not
+	// match DownloadOptions.Checksum
+	ErrChecksumMismatch = errors.New("download: checksum mismatch")
+)
+
+// Download streams the response to req into the file at path and returns its
+// size. Data is written to path+".part" and renamed into place once the size
+// and checksum are verified.
+//
+// An interrupted transfer is resumed with a Range request guarded by
+// If-Range, so a resource that changed in the meantime is fetched again from
+// the start. A sequential download left behind by a failed call is resumed
+// the same way by the next call for the same path; parallel downloads always
+// start over.
+func (c *Client) Download(ctx context.Context, req *Request, path string, opts DownloadOptions) (int64, error) {
+	if opts.Parallelism <= 0 {
+		opts.Parallelism = 1
+	}
+	if opts.MinChunkSize <= 0 {
+		opts.MinChunkSize = 8 << 20
+	}
+	if opts.Retries <= 0 {
+		opts.Retries = 3
+	}
+	if opts.Hash == nil {
+		opts.Hash = sha256.New
+	}
+
+	partPath := path + ".part"
+	validatorPath := partPath + ".validator"
+	d := &download{client: c, req: req, opts: opts, validatorPath: validatorPath, total: -1}
+
+	ranges := d.probe(ctx)
+	chunks := 1
+	if ranges && d.total > 0 {
+		chunks = int(min(int64(opts.Parallelism), (d.total+opts.MinChunkSize-1)/opts.MinChunkSize))
+	}
+
+	file, err := os.OpenFile(partPath, os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return 0, fmt.Errorf("error opening download file: %w", err)
+	}
+	defer file.Close()
+
+	var offset int64
+	if chunks == 1 {
+		offset = resumeOffset(file, validatorPath, d.validator)
+	}
+	if err := file.Truncate(offset); err != nil {
+		return 0, fmt.Errorf("error preparing download file: %w", err)
+	}
+	if err := os.WriteFile(validatorPath, []byte(d.validator), 0o644); err != nil {
+		return 0, fmt.Errorf("error saving download validator: %w", err)
+	}
+	d.written = offset
+
+	if chunks == 1 {
+		err = d.fetchRange(ctx, file, offset, -1)
+	} else {
+		err = d.fetchChunks(ctx, file, chunks)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if err := file.Close(); err != nil {
+		return 0, fmt.Errorf("error closing download file: %w", err)
+	}
+
+	size, err := d.verify(partPath)
+	if err != nil {
+		if errors.Is(err, ErrChecksumMismatch) || errors.Is(err, ErrSizeMismatch) {
+			os.Remove(partPath)
+			os.Remove(validatorPath)
+		}
+		return 0, err
+	}
+	if err := os.Rename(partPath, path); err != nil {
+		return 0, fmt.Errorf("error moving download into place: %w", err)
+	}
+	os.Remove(validatorPath)
+	return size, nil
+}
+
+// resumeOffset returns how much of a partial file can be kept, which is none
+// of it unless it was fetched under the same validator
+func resumeOffset(file *os.File, validatorPath, validator string) int64 {
+	if validator == "" {
+		return 0
+	}
+	saved, err := os.ReadFile(validatorPath)
+	if err != nil || string(saved) != validator {
+		return 0
+	}
+	info, err := file.Stat()
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
+
+// download is the state shared by the transfers of one Download call
+type download struct {
+	client        *Client
+	req           *Request
+	opts          DownloadOptions
+	validator     string
+	validatorPath string
+
+	mu      sync.Mutex
+	total   int64
+	written int64
+}
+
+// probe asks for the size and validator of the resource with a HEAD request
+// and reports whether the server accepts byte ranges. A failed probe only
+// rules out parallel ranges.
+func (d *download) probe(ctx context.Context) bool {
+	head := d.req.Clone()
+	head.Method = http.MethodHead
+	head.Body = nil
+
+	resp := d.client.Do(ctx, head)
+	if resp.Error != nil || resp.StatusCode != http.StatusOK {
+		return false
+	}
+	if length, ok := lookupHeader(resp.Headers, "Content-Length"); ok {
+		if size, err := strconv.ParseInt(length, 10, 64); err == nil {
+			d.total = size
+		}
+	}
+	d.validator = rangeValidator(resp.Headers)
+	acceptRanges, _ := lookupHeader(resp.Headers, "Accept-Ranges")
+	return strings.EqualFold(strings.TrimSpace(acceptRanges), "bytes")
+}
+
+// rangeValidator picks the If-Range value for a response: its ETag when that
+// is strong, otherwise its Last-Modified date
+func rangeValidator(headers map[string]string) string {
+	if etag, ok := lookupHeader(headers, "ETag"); ok && !strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+	lastModified, _ := lookupHeader(headers, "Last-Modified")
+	return lastModified
+}
+
+// fetchChunks splits the file into equal ranges fetched concurrently
+func (d *download) fetchChunks(ctx context.Context, file *os.File, chunks int) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	chunkSize := (d.total + int64(chunks) - 1) / int64(chunks)
+	errs := make([]error, chunks)
+	var wg sync.WaitGroup
+	for i := range chunks {
+		start := int64(i) * chunkSize
+		end := min(start+chunkSize, d.total)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := d.fetchRange(ctx, file, start, end); err != nil {
+				errs[i] = err
+				cancel()
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Report the error that caused the others to be cancelled
+	for _, err := range errs {
+		if err != nil && !errors.Is(err, context.Canceled) {
+			return err
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// fetchRange writes bytes [start, end) of the resource at the same offsets in
+// file, resuming after interruptions. An end of -1 means the end of the
+// resource.
+func (d *download) fetchRange(ctx context.Context, file *os.File, start, end int64) error {
+	pos := start
+	for attempt := 0; ; attempt++ {
+		var err error
+		pos, err = d.fetchOnce(ctx, file, pos, end)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return fmt.Errorf("error downloading: %w", ctx.Err())
+		}
+		var status statusError
+		if attempt >= d.opts.Retries || (errors.As(err, &status) && status < 500) {
+			return err
+		}
+	}
+}
+
+// statusError is an unexpected response status
+type statusError int
+
+func (e statusError) Error() string {
+	return fmt.Sprintf("download: unexpected status %d", int(e))
+}
+
+// fetchOnce makes one request for [pos, end) and returns the offset it
+// stopped writing at
+func (d *download) fetchOnce(ctx context.Context, file *os.File, pos, end int64) (int64, error) {
+	req := d.req.Clone()
+	if pos > 0 || end >= 0 {
+		if end >= 0 {
+			req.Headers["Range"] = fmt.Sprintf("bytes=%d-%d", pos, end-1)
+		} else {
+			req.Headers["Range"] = fmt.Sprintf("bytes=%d-", pos)
+		}
+		if d.validator != "" {
+			req.Headers["If-Range"] = d.validator
+		}
+	}
+
+	resp, err := d.client.stream(ctx, req, nil)
+	if err != nil {
+		return pos, fmt.Errorf("error downloading: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var expected int64 = -1
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		contentRange, _ := lookupHeader(resp.Headers, "Content-Range")
+		first, last, total, ok := parseContentRange(contentRange)
+		if !ok || first != pos {
+			return pos, fmt.Errorf("download: asked for bytes from %d, got range %q", pos, contentRange)
+		}
+		d.learnTotal(total)
+		expected = last - first + 1
+
+	case http.StatusOK:
+		// The server ignored the range, or If-Range found the resource changed
+		// and sent all of it. Only a sequential download can use that.
+		if end >= 0 {
+			return pos, fmt.Errorf("download: server ignored range request for bytes %d-%d", pos, end-1)
+		}
+		if pos > 0 {
+			if err := d.restart(file, resp.Headers); err != nil {
+				return pos, err
+			}
+			pos = 0
+		}
+		if length, ok := lookupHeader(resp.Headers, "Content-Length"); ok {
+			if size, err := strconv.ParseInt(length, 10, 64); err == nil {
+				expected = size
+				d.learnTotal(size)
+			}
+		}
+
+	case http.StatusRequestedRangeNotSatisfiable:
+		// A partial file that is already complete has nothing left to fetch
+		contentRange, _ := lookupHeader(resp.Headers, "Content-Range")
+		if _, _, total, ok := parseContentRange(contentRange); ok && total == pos && end < 0 {
+			d.learnTotal(total)
+			return pos, nil
+		}
+		return pos, statusError(resp.StatusCode)
+
+	default:
+		return pos, statusError(resp.StatusCode)
+	}
+
+	var body io.Reader = resp.Body
+	if expected >= 0 {
+		body = io.LimitReader(body, expected)
+	}
+	n, err := io.Copy(&progressWriter{w: io.NewOffsetWriter(file, pos), d: d}, body)
+	pos += n
+	if err == nil && expected >= 0 && n < expected {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return pos, fmt.Errorf("error downloading: %w", err)
+	}
+	return pos, nil
+}
+
+// restart discards a sequential download whose resource changed, adopting
+// the validator of the new version
+func (d *download) restart(file *os.File, headers map[string]string) error {
+	if err := file.Truncate(0); err != nil {
+		return fmt.Errorf("error restarting download: %w", err)
+	}
+	d.validator = rangeValidator(headers)
+	if err := os.WriteFile(d.validatorPath, []byte(d.validator), 0o644); err != nil {
+		return fmt.Errorf("error saving download validator: %w", err)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.written = 0
+	d.total = -1
+	return nil
+}
+
+// learnTotal records the resource size once a response reveals it
+func (d *download) learnTotal(total int64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.total < 0 && total >= 0 {
+		d.total = total
+	}
+}
+
+func (d *download) advance(n int64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.written += n
+	if d.opts.Progress != nil {
+		d.opts.Progress(d.written, d.total)
+	}
+}
+
+// verify checks the finished file against the announced size and the
+// expected checksum
+func (d *download) verify(path string) (int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("error verifying download: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return 0, fmt.Errorf("error verifying download: %w", err)
+	}
+	if d.total >= 0 && info.Size() != d.total {
+		return 0, fmt.Errorf("%w: got %d bytes, want %d", ErrSizeMismatch, info.Size(), d.total)
+	}
+
+	if d.opts.Checksum != "" {
+		h := d.opts.Hash()
+		if _, err := io.Copy(h, file); err != nil {
+			return 0, fmt.Errorf("error verifying download: %w", err)
+		}
+		if sum := hex.EncodeToString(h.Sum(nil)); !strings.EqualFold(sum, d.opts.Checksum) {
+			return 0, fmt.Errorf("%w: got %s, want %s", ErrChecksumMismatch, sum, d.opts.Checksum)
+		}
+	}
+	return info.Size(), nil
+}
+
+// progressWriter reports every write to the download's progress callback
+type progressWriter struct {
+	w io.Writer
+	d *download
+}
+
+func (p *progressWriter) Write(b []byte) (int, error) {
+	n, err := p.w.Write(b)
+	p.d.advance(int64(n))
+	return n, err
+}
+
+// parseContentRange parses a "bytes first-last/total" or "bytes */total"
+// Content-Range value. An unknown total is returned as -1 and an unsatisfied
+// range as -1, -1.
+func parseContentRange(value string) (first, last, total int64, ok bool) {
+	spec, found := strings.CutPrefix(strings.TrimSpace(value), "bytes ")
+	if !found {
+		return 0, 0, 0, false
+	}
+	span, size, found := strings.Cut(spec, "/")
+	if !found {
+		return 0, 0, 0, false
+	}
+
+	total = -1
+	if size != "*" {
+		var err error
+		if total, err = strconv.ParseInt(size, 10, 64); err != nil {
+			return 0, 0, 0, false
+		}
+	}
+	if span == "*" {
+		return -1, -1, total, true
+	}
+
+	start, end, found := strings.Cut(span, "-")
+	if !found {
+		return 0, 0, 0, false
+	}
+	first, err1 := strconv.ParseInt(start, 10, 64)
+	last, err2 := strconv.ParseInt(end, 10, 64)
+	if err1 != nil || err2 != nil || last < first {
+		return 0, 0, 0, false
+	}
+	return first, last, total, true
+}
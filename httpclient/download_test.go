@@ -0,0 +1,215 @@
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testFile is the content served by setupRangeTestServer
+var testFile = func() []byte {
+	content := make([]byte, 1<<20+123)
+	for i := range content {
+		content[i] = byte(i * 7 % 251)
+	}
+	return content
+}()
+
+// setupRangeTestServer serves testFile with range support. The first
+// abortAfter full GETs are cut off halfway through the body. Every Range
+// header received is recorded, with "" for requests without one.
+func setupRangeTestServer(abortAfter int, ranges *[]string, mu *sync.Mutex) *httptest.Server {
+	var aborted atomic.Int64
+	modified := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		if r.Method != http.MethodGet {
+			http.ServeContent(w, r, "file.bin", modified, bytes.NewReader(testFile))
+			return
+		}
+
+		mu.Lock()
+		*ranges = append(*ranges, r.Header.Get("Range"))
+		mu.Unlock()
+
+		if r.Header.Get("Range") == "" && aborted.Add(1) <= int64(abortAfter) {
+			w.Header().Set("Content-Length", strconv.Itoa(len(testFile)))
+			w.Write(testFile[:len(testFile)/2])
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		}
+		http.ServeContent(w, r, "file.bin", modified, bytes.NewReader(testFile))
+	}))
+}
+
+func testFileChecksum() string {
+	sum := sha256.Sum256(testFile)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestDownloadResumesInterruptedTransfer(t *testing.T) {
+	for _, backend := range []Backend{BackendStandard, BackendFastHTTP} {
+		t.Run(string(backend), func(t *testing.T) {
+			var mu sync.Mutex
+			var ranges []string
+			server := setupRangeTestServer(1, &ranges, &mu)
+			defer server.Close()
+
+			client, err := NewClient(backend)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			path := filepath.Join(t.TempDir(), "file.bin")
+			var lastWritten, lastTotal int64
+			size, err := client.Download(context.Background(), &Request{Method: http.MethodGet, URL: server.URL}, path, DownloadOptions{
+				Checksum: testFileChecksum(),
+				Progress: func(written, total int64) { lastWritten, lastTotal = written, total },
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if size != int64(len(testFile)) || !bytes.Equal(got, testFile) {
+				t.Errorf("downloaded %d bytes that do not match the served file", size)
+			}
+			if lastWritten != size || lastTotal != size {
+				t.Errorf("last progress = %d/%d, want %d/%d", lastWritten, lastTotal, size, size)
+			}
+			wantRanges := []string{"", "bytes=" + strconv.Itoa(len(testFile)/2) + "-"}
+			mu.Lock()
+			defer mu.Unlock()
+			if len(ranges) != 2 || ranges[0] != wantRanges[0] || ranges[1] != wantRanges[1] {
+				t.Errorf("Range headers = %q, want %q", ranges, wantRanges)
+			}
+			if _, err := os.Stat(path + ".part"); !os.IsNotExist(err) {
+				t.Errorf("partial file left behind: %v", err)
+			}
+		})
+	}
+}
+
+func TestDownloadParallelRanges(t *testing.T) {
+	for _, backend := range []Backend{BackendStandard, BackendFastHTTP} {
+		t.Run(string(backend), func(t *testing.T) {
+			var mu sync.Mutex
+			var ranges []string
+			server := setupRangeTestServer(0, &ranges, &mu)
+			defer server.Close()
+
+			client, err := NewClient(backend)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			path := filepath.Join(t.TempDir(), "file.bin")
+			_, err = client.Download(context.Background(), &Request{Method: http.MethodGet, URL: server.URL}, path, DownloadOptions{
+				Parallelism:  4,
+				MinChunkSize: 64 << 10,
+				Checksum:     testFileChecksum(),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if len(ranges) != 4 {
+				t.Errorf("Range headers = %q, want 4 ranges", ranges)
+			}
+			for _, r := range ranges {
+				if r == "" {
+					t.Errorf("full GET made during a parallel download")
+				}
+			}
+		})
+	}
+}
+
+func TestDownloadResumesPartialFile(t *testing.T) {
+	var mu sync.Mutex
+	var ranges []string
+	server := setupRangeTestServer(0, &ranges, &mu)
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "file.bin")
+	if err := os.WriteFile(path+".part", testFile[:1000], 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path+".part.validator", []byte(`"v1"`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := NewClient(BackendStandard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Download(context.Background(), &Request{Method: http.MethodGet, URL: server.URL}, path, DownloadOptions{Checksum: testFileChecksum()}); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(ranges) != 1 || ranges[0] != "bytes=1000-" {
+		t.Errorf("Range headers = %q, want [\"bytes=1000-\"]", ranges)
+	}
+}
+
+func TestDownloadChecksumMismatch(t *testing.T) {
+	var mu sync.Mutex
+	var ranges []string
+	server := setupRangeTestServer(0, &ranges, &mu)
+	defer server.Close()
+
+	client, err := NewClient(BackendStandard)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "file.bin")
+	_, err = client.Download(context.Background(), &Request{Method: http.MethodGet, URL: server.URL}, path, DownloadOptions{Checksum: "00"})
+	if !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("err = %v, want ErrChecksumMismatch", err)
+	}
+	for _, leftover := range []string{path, path + ".part", path + ".part.validator"} {
+		if _, err := os.Stat(leftover); !os.IsNotExist(err) {
+			t.Errorf("%s exists after a failed checksum", filepath.Base(leftover))
+		}
+	}
+}
+
+func TestParseContentRange(t *testing.T) {
+	tests := []struct {
+		value              string
+		first, last, total int64
+		ok                 bool
+	}{
+		{"bytes 0-499/1234", 0, 499, 1234, true},
+		{"bytes 500-999/*", 500, 999, -1, true},
+		{"bytes */1234", -1, -1, 1234, true},
+		{"bytes 9-1/10", 0, 0, 0, false},
+		{"items 0-1/2", 0, 0, 0, false},
+	}
+	for _, tt := range tests {
+		first, last, total, ok := parseContentRange(tt.value)
+		if first != tt.first || last != tt.last || total != tt.total || ok != tt.ok {
+			t.Errorf("parseContentRange(%q) = %d, %d, %d, %v", tt.value, first, last, total, ok)
+		}
+	}
+}
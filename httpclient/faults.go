@@ -0,0 +1,310 @@
+package httpclient
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// FaultKind is the kind of failure a Fault injects
+type FaultKind int
+
+const (
+	// FaultLatency delays the request by Delay before sending it. Latency
+	// faults add up and combine with the other kinds.
+	FaultLatency FaultKind = iota
+	// FaultConnReset fails the request as if the server had reset the
+	// connection, without sending it
+	FaultConnReset
+	// FaultTimeout holds the request for Delay, or the client timeout when
+	// Delay is zero, and then fails it as a timeout without sending it
+	FaultTimeout
+	// FaultStatus answers with StatusCode without sending the request
+	FaultStatus
+	// FaultCorruptBody sends the request and flips random bits of the
+	// response body
+	FaultCorruptBody
+)
+
+// Fault is one failure to inject into matching requests
+type Fault struct {
+	Kind FaultKind
+	// Probability is the chance, from 0 to 1, that a matching request gets
+	// the fault
+	Probability float64
+
+	// Host, Method and PathPrefix select the requests the fault applies to;
+	// empty fields match everything. Host matches the host name, or the
+	// host and port when it contains a port.
+	Host       string
+	Method     string
+	PathPrefix string
+
+	Delay time.Duration
+	// StatusCode is the status a FaultStatus answers with, from 100 to 599
+	StatusCode int
+}
+
+// validate reports a fault that could not behave as configured
+func (fault *Fault) validate() error {
+	if fault.Probability < 0 || fault.Probability > 1 {
+		return fmt.Errorf("fault probability must be between 0 and 1, got %g", fault.Probability)
+	}
+	if fault.Kind == FaultStatus && (fault.StatusCode < 100 || fault.StatusCode > 599) {
+		return fmt.Errorf("status fault needs a status code from 100 to 599, got %d", fault.StatusCode)
+	}
+	return nil
+}
+
+func validateFaults(faults []Fault) error {
+	for i := range faults {
+		if err := faults[i].validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FaultInjector injects Faults into a Client's requests for chaos testing.
+// Its faults can be replaced and it can be switched off while the client is
+// in use. Injected connection resets and timeouts fail with the same errors
+// the backend returns for real ones, so errors.Is and errors.As checks see
+// no difference.
+type FaultInjector struct {
+	enabled atomic.Bool
+
+	mu     sync.RWMutex
+	faults []Fault
+}
+
+// NewFaultInjector returns an enabled injector for faults. Invalid faults
+// are reported by WithFaultInjector.
+func NewFaultInjector(faults ...Fault) *FaultInjector {
+	f := &FaultInjector{faults: faults}
+	f.enabled.Store(true)
+	return f
+}
+
+// SetFaults replaces the injected faults. If any of them is invalid, the
+// current faults are kept and the error is returned.
+func (f *FaultInjector) SetFaults(faults ...Fault) error {
+	if err := validateFaults(faults); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.faults = faults
+	return nil
+}
+
+// Enable resumes injecting faults
+func (f *FaultInjector) Enable() { f.enabled.Store(true) }
+
+// Disable stops injecting faults until Enable is called
+func (f *FaultInjector) Disable() { f.enabled.Store(false) }
+
+// Enabled reports whether faults are being injected
+func (f *FaultInjector) Enabled() bool { return f.enabled.Load() }
+
+// WithFaultInjector injects the faults of injector into every request and
+// redirect hop. It fails for a fault with a Probability outside 0 to 1 or
+// a FaultStatus without a valid StatusCode.
+func WithFaultInjector(injector *FaultInjector) Option {
+	return func(cfg *clientConfig) error {
+		if injector == nil {
+			return fmt.Errorf("fault injector must not be nil")
+		}
+		injector.mu.RLock()
+		err := validateFaults(injector.faults)
+		injector.mu.RUnlock()
+		if err != nil {
+			return err
+		}
+		cfg.faults = injector
+		return nil
+	}
+}
+
+// faultPlan is what the injector does to one request
+type faultPlan struct {
+	delay   time.Duration
+	failure *Fault
+	corrupt bool
+}
+
+// plan rolls the dice for every fault matching req
+func (f *FaultInjector) plan(req *Request) faultPlan {
+	var plan faultPlan
+	if !f.Enabled() {
+		return plan
+	}
+	u, err := url.Parse(req.URL)
+	if err != nil {
+		return plan
+	}
+
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	for i := range f.faults {
+		fault := &f.faults[i]
+		if !fault.matches(req.Method, u) || rand.Float64() >= fault.Probability {
+			continue
+		}
+		switch fault.Kind {
+		case FaultLatency:
+			plan.delay += fault.Delay
+		case FaultCorruptBody:
+			plan.corrupt = true
+		default:
+			if plan.failure == nil {
+				failure := *fault
+				plan.failure = &failure
+			}
+		}
+	}
+	return plan
+}
+
+func (fault *Fault) matches(method string, u *url.URL) bool {
+	if fault.Method != "" && !strings.EqualFold(fault.Method, method) {
+		return false
+	}
+	if fault.Host != "" {
+		host := u.Hostname()
+		if strings.Contains(fault.Host, ":") {
+			host = u.Host
+		}
+		if !strings.EqualFold(fault.Host, host) {
+			return false
+		}
+	}
+	return strings.HasPrefix(u.Path, fault.PathPrefix)
+}
+
+// do applies the planned faults around send
+func (f *FaultInjector) do(ctx context.Context, c *Client, req *Request, send func(context.Context, *Request) HTTPResponse) HTTPResponse {
+	plan := f.plan(req)
+	if err := c.injectFailure(ctx, req, plan); err != nil {
+		return HTTPResponse{Error: err}
+	}
+	if plan.failure != nil {
+		return HTTPResponse{
+			StatusCode: plan.failure.StatusCode,
+			Body:       []byte(http.StatusText(plan.failure.StatusCode)),
+			Headers:    map[string]string{"Content-Type": "text/plain; charset=utf-8"},
+		}
+	}
+
+	resp := send(ctx, req)
+	if plan.corrupt && resp.Error == nil {
+		corrupt(resp.Body)
+	}
+	return resp
+}
+
+// stream is do for streamed responses
+func (f *FaultInjector) stream(ctx context.Context, c *Client, req *Request, body *bodyStream, send func(context.Context, *Request, *bodyStream) (*streamResponse, error)) (*streamResponse, error) {
+	plan := f.plan(req)
+	if err := c.injectFailure(ctx, req, plan); err != nil {
+		return nil, err
+	}
+	if plan.failure != nil {
+		return &streamResponse{
+			StatusCode: plan.failure.StatusCode,
+			Headers:    map[string]string{"Content-Type": "text/plain; charset=utf-8"},
+			Body:       io.NopCloser(strings.NewReader(http.StatusText(plan.failure.StatusCode))),
+		}, nil
+	}
+
+	resp, err := send(ctx, req, body)
+	if err == nil && plan.corrupt {
+		resp.Body = &corruptingBody{ReadCloser: resp.Body}
+	}
+	return resp, err
+}
+
+// injectFailure waits out the planned latency and returns the error of a
+// planned reset or timeout, shaped like the backend's own errors. A status
+// failure is left to the caller.
+func (c *Client) injectFailure(ctx context.Context, req *Request, plan faultPlan) error {
+	if plan.failure != nil && plan.failure.Kind == FaultTimeout {
+		wait := plan.failure.Delay
+		if wait <= 0 {
+			wait = c.timeout
+		}
+		plan.delay += wait
+	}
+	if plan.delay > 0 {
+		timer := time.NewTimer(plan.delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return c.faultError(req, ctx.Err())
+		}
+	}
+	if plan.failure == nil {
+		return nil
+	}
+
+	switch plan.failure.Kind {
+	case FaultConnReset:
+		return c.faultError(req, &net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)})
+	case FaultTimeout:
+		return c.faultError(req, context.DeadlineExceeded)
+	}
+	return nil
+}
+
+// faultError wraps cause the way the backend reports it: net/http wraps
+// transport errors in a *url.Error, while fasthttp has its own sentinels
+// for closed connections and timeouts
+func (c *Client) faultError(req *Request, cause error) error {
+	if c.backend == BackendFastHTTP {
+		switch {
+		case cause == context.DeadlineExceeded:
+			cause = fasthttp.ErrTimeout
+		case cause != context.Canceled:
+			cause = fasthttp.ErrConnectionClosed
+		}
+		return fmt.Errorf("error making request: %w", cause)
+	}
+	op := "Get"
+	if req.Method != "" {
+		op = strings.ToUpper(req.Method[:1]) + strings.ToLower(req.Method[1:])
+	}
+	return fmt.Errorf("error making request: %w", &url.Error{Op: op, URL: req.URL, Err: cause})
+}
+
+// corrupt flips a random bit in about one byte in 64, and in at least one
+func corrupt(body []byte) {
+	if len(body) == 0 {
+		return
+	}
+	for i := 0; i < max(1, len(body)/64); i++ {
+		body[rand.IntN(len(body))] ^= 1 << rand.IntN(8)
+	}
+}
+
+// corruptingBody corrupts a streamed body as it is read
+type corruptingBody struct {
+	io.ReadCloser
+}
+
+func (b *corruptingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	corrupt(p[:n])
+	return n, err
+}
@@ -0,0 +1,212 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+	"golang-content/httpclient/httpclienttest"
+)
+
+// TestClientFaults checks that both backends report broken responses as
+// errors rather than as short successful bodies
+func TestClientFaults(t *testing.T) {
+	server := httpclienttest.NewServer()
+	defer server.Close()
+	server.Handle("", "/reset").Reset(1)
+	server.Handle("", "/truncated").Truncate(10).Respond(http.StatusOK, `{"message": "success"}`)
+	server.Handle("", "/drip").Drip(4, 100*time.Millisecond).Respond(http.StatusOK, `{"message": "success"}`)
+	server.Handle("", "/flaky").Times(2).Respond(http.StatusServiceUnavailable, "")
+	server.Handle("", "/flaky").Respond(http.StatusOK, "ok")
+
+	for _, backend := range []Backend{BackendStandard, BackendFastHTTP} {
+		t.Run(string(backend), func(t *testing.T) {
+			client, err := NewClient(backend, WithTimeout(250*time.Millisecond))
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx := context.Background()
+
+			for _, path := range []string{"/reset", "/truncated", "/drip"} {
+				if resp := client.Get(ctx, server.URL+path, nil); resp.Error == nil {
+					t.Errorf("GET %s = %d %q, want an error", path, resp.StatusCode, resp.Body)
+				}
+			}
+		})
+	}
+
+	// Two failures, then success for every later request
+	client, err := NewClient(BackendFastHTTP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var statuses []int
+	for range 3 {
+		statuses = append(statuses, client.Get(context.Background(), server.URL+"/flaky", nil).StatusCode)
+	}
+	if statuses[0] != http.StatusServiceUnavailable || statuses[1] != http.StatusServiceUnavailable || statuses[2] != http.StatusOK {
+		t.Errorf("statuses = %v, want 503 503 200", statuses)
+	}
+}
+
+func TestFaultInjectorErrorsMatchRealFailures(t *testing.T) {
+	server := httpclienttest.NewServer()
+	defer server.Close()
+	server.Handle("", "/reset").Reset(1)
+	server.Handle("", "/slow").Latency(time.Second)
+	server.Handle("", "/ok")
+
+	// checks describe an error the way resilience code would inspect it
+	checks := func(err error) string {
+		var urlErr *url.Error
+		var netErr net.Error
+		return fmt.Sprintf("reset=%v deadline=%v url=%v timeout=%v fastclosed=%v fasttimeout=%v",
+			errors.Is(err, syscall.ECONNRESET), errors.Is(err, context.DeadlineExceeded), errors.As(err, &urlErr),
+			errors.As(err, &netErr) && netErr.Timeout(), errors.Is(err, fasthttp.ErrConnectionClosed), errors.Is(err, fasthttp.ErrTimeout))
+	}
+
+	for _, backend := range []Backend{BackendStandard, BackendFastHTTP} {
+		t.Run(string(backend), func(t *testing.T) {
+			real, err := NewClient(backend, WithTimeout(100*time.Millisecond))
+			if err != nil {
+				t.Fatal(err)
+			}
+			injector := NewFaultInjector()
+			faulty, err := NewClient(backend, WithTimeout(100*time.Millisecond), WithFaultInjector(injector))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			tests := []struct {
+				path  string
+				fault Fault
+			}{
+				{"/reset", Fault{Kind: FaultConnReset, Probability: 1}},
+				{"/slow", Fault{Kind: FaultTimeout, Probability: 1}},
+			}
+			for _, tt := range tests {
+				if err := injector.SetFaults(tt.fault); err != nil {
+					t.Fatal(err)
+				}
+				want := real.Get(context.Background(), server.URL+tt.path, nil).Error
+				got := faulty.Get(context.Background(), server.URL+"/ok", nil).Error
+				if want == nil || got == nil {
+					t.Fatalf("%s: real error %v, injected error %v", tt.path, want, got)
+				}
+				if checks(got) != checks(want) {
+					t.Errorf("%s: injected error %q has %s, real error %q has %s", tt.path, got, checks(got), want, checks(want))
+				}
+			}
+		})
+	}
+}
+
+func TestFaultInjectorRouting(t *testing.T) {
+	server := httpclienttest.NewServer()
+	defer server.Close()
+	server.Handle("", "*").Respond(http.StatusOK, `{"message": "success"}`)
+	otherHost := strings.Replace(server.URL, "127.0.0.1", "localhost", 1)
+
+	injector := NewFaultInjector(
+		Fault{Kind: FaultStatus, Probability: 1, Host: "127.0.0.1", PathPrefix: "/api/", Method: http.MethodGet, StatusCode: http.StatusServiceUnavailable},
+		Fault{Kind: FaultCorruptBody, Probability: 1, PathPrefix: "/corrupt"},
+		Fault{Kind: FaultLatency, Probability: 1, PathPrefix: "/slow", Delay: 50 * time.Millisecond},
+	)
+
+	for _, backend := range []Backend{BackendStandard, BackendFastHTTP} {
+		t.Run(string(backend), func(t *testing.T) {
+			client, err := NewClient(backend, WithFaultInjector(injector))
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx := context.Background()
+
+			tests := []struct {
+				method string
+				url    string
+				status int
+			}{
+				{http.MethodGet, server.URL + "/api/items", http.StatusServiceUnavailable},
+				{http.MethodPost, server.URL + "/api/items", http.StatusOK},
+				{http.MethodGet, otherHost + "/api/items", http.StatusOK},
+				{http.MethodGet, server.URL + "/other", http.StatusOK},
+			}
+			for _, tt := range tests {
+				resp := client.Do(ctx, &Request{Method: tt.method, URL: tt.url})
+				if resp.Error != nil || resp.StatusCode != tt.status {
+					t.Errorf("%s %s = %d, %v; want %d", tt.method, tt.url, resp.StatusCode, resp.Error, tt.status)
+				}
+			}
+
+			resp := client.Get(ctx, server.URL+"/corrupt", nil)
+			if resp.Error != nil || resp.StatusCode != http.StatusOK || string(resp.Body) == `{"message": "success"}` {
+				t.Errorf("corrupted response = %d %q, %v", resp.StatusCode, resp.Body, resp.Error)
+			}
+
+			start := time.Now()
+			if resp := client.Get(ctx, server.URL+"/slow", nil); resp.Error != nil {
+				t.Fatal(resp.Error)
+			}
+			if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+				t.Errorf("delayed request took %v", elapsed)
+			}
+		})
+	}
+}
+
+func TestFaultInjectorSwitch(t *testing.T) {
+	server := setupTestServer()
+	defer server.Close()
+
+	injector := NewFaultInjector(Fault{Kind: FaultConnReset, Probability: 1})
+	client, err := NewClient(BackendStandard, WithFaultInjector(injector))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	injector.Disable()
+	if resp := client.Get(context.Background(), server.URL, nil); resp.Error != nil {
+		t.Errorf("disabled injector: %v", resp.Error)
+	}
+	injector.Enable()
+	if resp := client.Get(context.Background(), server.URL, nil); resp.Error == nil {
+		t.Error("enabled injector: expected a reset")
+	}
+	if err := injector.SetFaults(Fault{Kind: FaultConnReset, Probability: 0}); err != nil {
+		t.Fatal(err)
+	}
+	if resp := client.Get(context.Background(), server.URL, nil); resp.Error != nil {
+		t.Errorf("zero probability: %v", resp.Error)
+	}
+}
+
+func TestFaultInjectorRejectsInvalidFaults(t *testing.T) {
+	invalid := []Fault{
+		{Kind: FaultConnReset, Probability: -0.1},
+		{Kind: FaultLatency, Probability: 1.5, Delay: time.Millisecond},
+		{Kind: FaultStatus, Probability: 1},
+		{Kind: FaultStatus, Probability: 1, StatusCode: 600},
+	}
+	for _, fault := range invalid {
+		if _, err := NewClient(BackendStandard, WithFaultInjector(NewFaultInjector(fault))); err == nil {
+			t.Errorf("NewClient accepted %+v", fault)
+		}
+
+		valid := Fault{Kind: FaultStatus, Probability: 1, StatusCode: http.StatusTeapot}
+		injector := NewFaultInjector(valid)
+		if err := injector.SetFaults(fault); err == nil {
+			t.Errorf("SetFaults accepted %+v", fault)
+		}
+		if len(injector.faults) != 1 || injector.faults[0] != valid {
+			t.Errorf("SetFaults(%+v) replaced the valid faults: %+v", fault, injector.faults)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package httpclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+)
+
+// HedgePolicy configures hedged requests: when an idempotent GET has not
+// answered within the hedge delay, further copies are sent and the first
+// successful response wins
+type HedgePolicy struct {
+	// Delay is how long to wait before sending a hedge. It is also the delay
+	// used while too few latencies have been observed for Percentile.
+	Delay time.Duration
+
+	// Percentile, when set (e.g. 0.95), derives the delay from the latency
+	// of recent requests instead of using Delay
+	Percentile float64
+
+	// MaxHedges is the number of extra requests that may be sent; defaults to 1
+	MaxHedges int
+
+	// Alternates are base URLs (scheme://host[:port]) that hedges are sent to
+	// in turn instead of the original host
+	Alternates []string
+}
+
+// hedgeMinSamples is how many latencies are needed before the percentile is trusted
+const hedgeMinSamples = 20
+
+// WithHedging enables hedged GET requests
+func WithHedging(policy HedgePolicy) Option {
+	return func(cfg *clientConfig) error {
+		if policy.Delay <= 0 {
+			return fmt.Errorf("hedge delay must be positive, got %s", policy.Delay)
+		}
+		if policy.Percentile < 0 || policy.Percentile >= 1 {
+			return fmt.Errorf("hedge percentile must be in [0, 1), got %g", policy.Percentile)
+		}
+		if policy.MaxHedges == 0 {
+			policy.MaxHedges = 1
+		}
+
+		h := &hedger{policy: policy}
+		for _, alternate := range policy.Alternates {
+			u, err := url.Parse(alternate)
+			if err != nil || u.Host == "" {
+				return fmt.Errorf("invalid hedge alternate %q", alternate)
+			}
+			h.alternates = append(h.alternates, u)
+		}
+		cfg.hedger = h
+		return nil
+	}
+}
+
+// hedger sends hedged requests and tracks the latencies that drive the
+// percentile-based delay
+type hedger struct {
+	policy     HedgePolicy
+	alternates []*url.URL
+
+	mu        sync.Mutex
+	latencies [256]time.Duration
+	next      int
+	count     int
+}
+
+type hedgeResult struct {
+	resp    HTTPResponse
+	attempt int
+}
+
+// do sends req and up to MaxHedges copies of it, returning the first
+// successful response and cancelling the others. fasthttp cannot abort a
+// request in flight, so on that backend losing requests run to completion in
+// the background.
+func (h *hedger) do(ctx context.Context, req *Request, send func(context.Context, *Request) HTTPResponse) HTTPResponse {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan hedgeResult, h.policy.MaxHedges+1)
+	starts := make([]time.Time, 0, h.policy.MaxHedges+1)
+	finished := make([]bool, 0, h.policy.MaxHedges+1)
+	launch := func(attempt int) {
+		target := h.target(req, attempt)
+		starts = append(starts, time.Now())
+		finished = append(finished, false)
+		go func() {
+			results <- hedgeResult{resp: send(ctx, target), attempt: attempt}
+		}()
+	}
+
+	launch(0)
+	inFlight := 1
+	sent := 1
+
+	timer := time.NewTimer(h.delay())
+	defer timer.Stop()
+
+	var last HTTPResponse
+	for inFlight > 0 {
+		select {
+		case result := <-results:
+			inFlight--
+			finished[result.attempt] = true
+			if hedgeSucceeded(result.resp) {
+				now := time.Now()
+				samples := []time.Duration{now.Sub(starts[0])}
+				for attempt, start := range starts {
+					if !finished[attempt] {
+						samples = append(samples, now.Sub(start))
+					}
+				}
+				h.observe(samples...)
+				return result.resp
+			}
+			last = result.resp
+			// A failed attempt is hedged straight away rather than after the delay
+			if sent <= h.policy.MaxHedges && ctx.Err() == nil {
+				launch(sent)
+				sent++
+				inFlight++
+			}
+		case <-timer.C:
+			if sent <= h.policy.MaxHedges {
+				launch(sent)
+				sent++
+				inFlight++
+				timer.Reset(h.delay())
+			}
+		case <-ctx.Done():
+			return HTTPResponse{Error: fmt.Errorf("error making request: %w", ctx.Err())}
+		}
+	}
+	return last
+}
+
+// applies reports whether the request is safe to hedge
+func (h *hedger) applies(req *Request) bool {
+	return req.Method == http.MethodGet || req.Method == http.MethodHead
+}
+
+// target returns the request for an attempt, rewritten to the next alternate
+// host for hedges
+func (h *hedger) target(req *Request, attempt int) *Request {
+	if attempt == 0 || len(h.alternates) == 0 {
+		return req
+	}
+
+	u, err := url.Parse(req.URL)
+	if err != nil {
+		return req
+	}
+	alternate := h.alternates[(attempt-1)%len(h.alternates)]
+	u.Scheme = alternate.Scheme
+	u.Host = alternate.Host
+
+	clone := req.Clone()
+	clone.URL = u.String()
+	return clone
+}
+
+func hedgeSucceeded(resp HTTPResponse) bool {
+	return resp.Error == nil && resp.StatusCode < http.StatusInternalServerError
+}
+
+func (h *hedger) observe(latencies ...time.Duration) {
+	if h.policy.Percentile == 0 {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, latency := range latencies {
+		h.latencies[h.next] = latency
+		h.next = (h.next + 1) % len(h.latencies)
+		h.count = min(h.count+1, len(h.latencies))
+	}
+}
+
+// delay returns the current hedge delay
+func (h *hedger) delay() time.Duration {
+	if h.policy.Percentile == 0 {
+		return h.policy.Delay
+	}
+
+	h.mu.Lock()
+	if h.count < hedgeMinSamples {
+		h.mu.Unlock()
+		return h.policy.Delay
+	}
+	samples := make([]time.Duration, h.count)
+	copy(samples, h.latencies[:h.count])
+	h.mu.Unlock()
+
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+	return samples[int(h.policy.Percentile*float64(len(samples)-1))]
+}
@@ -0,0 +1,189 @@
+package httpclient
+
+import (
+	"context"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// setupSlowTestServer creates a server that stalls for slowDelay on the
+// given fraction of requests
+func setupSlowTestServer(fraction float64, slowDelay time.Duration) *httptest.Server {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if rand.Float64() < fraction {
+			time.Sleep(slowDelay)
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"message": "success"}`))
+	})
+	server := httptest.NewServer(handler)
+	server.EnableHTTP2 = false
+	return server
+}
+
+func TestClientHedgesSlowRequest(t *testing.T) {
+	var calls atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			time.Sleep(300 * time.Millisecond)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	for _, backend := range []Backend{BackendStandard, BackendFastHTTP} {
+		t.Run(string(backend), func(t *testing.T) {
+			calls.Store(0)
+			client, err := NewClient(backend, WithHedging(HedgePolicy{Delay: 10 * time.Millisecond}))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			start := time.Now()
+			resp := client.Get(context.Background(), server.URL, nil)
+			if resp.Error != nil {
+				t.Fatal(resp.Error)
+			}
+			if elapsed := time.Since(start); elapsed > 200*time.Millisecond {
+				t.Errorf("hedged request took %s, want the fast hedge to win", elapsed)
+			}
+			if got := calls.Load(); got != 2 {
+				t.Errorf("server saw %d requests, want 2", got)
+			}
+		})
+	}
+}
+
+func TestClientHedgeSamplesLosers(t *testing.T) {
+	var calls atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			time.Sleep(300 * time.Millisecond)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	const delay = 50 * time.Millisecond
+	client, err := NewClient(BackendStandard, WithHedging(HedgePolicy{Delay: delay, Percentile: 0.5}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp := client.Get(context.Background(), server.URL, nil); resp.Error != nil {
+		t.Fatal(resp.Error)
+	}
+
+	// The fast hedge is sampled from the original start, and the slow primary
+	// it beat is sampled too
+	h := client.hedger
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.count != 2 {
+		t.Fatalf("recorded %d latencies, want 2", h.count)
+	}
+	for _, latency := range h.latencies[:h.count] {
+		if latency < delay {
+			t.Errorf("latency %s is below the hedge delay %s", latency, delay)
+		}
+	}
+}
+
+func TestClientHedgesToAlternate(t *testing.T) {
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer primary.Close()
+	alternate := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.Path))
+	}))
+	defer alternate.Close()
+
+	client, err := NewClient(BackendStandard, WithHedging(HedgePolicy{
+		Delay:      time.Second,
+		Alternates: []string{alternate.URL},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The primary fails fast, so the hedge goes out without waiting for the delay
+	resp := client.Get(context.Background(), primary.URL+"/config", nil)
+	if resp.Error != nil {
+		t.Fatal(resp.Error)
+	}
+	if resp.StatusCode != http.StatusOK || string(resp.Body) != "/config" {
+		t.Errorf("got %d %q, want the alternate's response", resp.StatusCode, resp.Body)
+	}
+}
+
+func TestClientDoesNotHedgePost(t *testing.T) {
+	var calls atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		time.Sleep(50 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	client, err := NewClient(BackendStandard, WithHedging(HedgePolicy{Delay: time.Millisecond}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp := client.Post(context.Background(), server.URL, nil, map[string]int{"id": 1}); resp.Error != nil {
+		t.Fatal(resp.Error)
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("server saw %d requests, want 1", got)
+	}
+}
+
+func BenchmarkHedgedTailLatency(b *testing.B) {
+	// One request in twenty stalls, which puts the stall squarely in the p99
+	server := setupSlowTestServer(0.05, 20*time.Millisecond)
+	defer server.Close()
+
+	ctx := context.Background()
+	headers := map[string]string{
+		"User-Agent": "Benchmark-Client",
+	}
+
+	variants := []struct {
+		name string
+		opts []Option
+	}{
+		{"NoHedge", nil},
+		{"Hedge-Delay-2ms", []Option{WithHedging(HedgePolicy{Delay: 2 * time.Millisecond})}},
+		{"Hedge-P90", []Option{WithHedging(HedgePolicy{Delay: 2 * time.Millisecond, Percentile: 0.9})}},
+	}
+
+	for _, backend := range []Backend{BackendStandard, BackendFastHTTP} {
+		for _, v := range variants {
+			b.Run(string(backend)+"/"+v.name, func(b *testing.B) {
+				client, err := NewClient(backend, v.opts...)
+				if err != nil {
+					b.Fatal(err)
+				}
+
+				latencies := make([]time.Duration, 0, b.N)
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					start := time.Now()
+					resp := client.Get(ctx, server.URL, headers)
+					if resp.Error != nil {
+						b.Fatal(resp.Error)
+					}
+					latencies = append(latencies, time.Since(start))
+				}
+				b.StopTimer()
+
+				sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+				b.ReportMetric(float64(latencies[len(latencies)/2].Microseconds()), "p50_us")
+				b.ReportMetric(float64(latencies[len(latencies)*99/100].Microseconds()), "p99_us")
+			})
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testHMACSigner() *HMACSigner {
+	return &HMACSigner{
+		KeyID:         "service-a",
+		Secret:        []byte("shared-secret"),
+		SignedHeaders: []string{"Content-Type"},
+		Now: func() time.Time {
+			return time.Unix(1700000000, 0)
+		},
+	}
+}
+
+func TestHMACSignerVerify(t *testing.T) {
+	signer := testHMACSigner()
+	req := &Request{
+		Method:  "POST",
+		URL:     "https://internal.example.com/v1/items?b=2&a=1",
+		Headers: map[string]string{"Content-Type": "application/json"},
+		Body:    []byte(`{"id":1}`),
+	}
+	if err := signer.Sign(req); err != nil {
+		t.Fatal(err)
+	}
+	if err := signer.Verify(req); err != nil {
+		t.Fatalf("Verify() = %v", err)
+	}
+
+	tampered := req.Clone()
+	tampered.Body = []byte(`{"id":2}`)
+	if err := signer.Verify(tampered); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Verify(tampered body) = %v, want ErrInvalidSignature", err)
+	}
+
+	tampered = req.Clone()
+	tampered.URL = "https://internal.example.com/v1/items?b=2&a=3"
+	if err := signer.Verify(tampered); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Verify(tampered query) = %v, want ErrInvalidSignature", err)
+	}
+
+	// A signature that leaves out a required header is rejected even though
+	// it matches the headers it claims
+	for _, omitted := range signer.signedHeaders() {
+		var kept []string
+		for _, name := range signer.signedHeaders() {
+			if name != omitted {
+				kept = append(kept, name)
+			}
+		}
+		stripped := req.Clone()
+		stringToSign, err := signer.stringToSign(stripped, kept)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stripped.Headers["Authorization"] = fmt.Sprintf("HMAC-SHA256 KeyId=%s,SignedHeaders=%s,Signature=%s",
+			signer.KeyID, strings.Join(kept, ";"), signer.sign(stringToSign))
+		if err := signer.Verify(stripped); !errors.Is(err, ErrInvalidSignature) {
+			t.Errorf("Verify(SignedHeaders without %s) = %v, want ErrInvalidSignature", omitted, err)
+		}
+	}
+
+	stale := *signer
+	stale.Now = func() time.Time { return time.Unix(1700000000, 0).Add(time.Hour) }
+	if err := stale.Verify(req); err == nil {
+		t.Error("Verify() should reject a stale timestamp")
+	}
+}
+
+func TestClientSignsRequests(t *testing.T) {
+	signer := testHMACSigner()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		headers := make(map[string]string)
+		for key := range r.Header {
+			headers[key] = r.Header.Get(key)
+		}
+		req := &Request{Method: r.Method, URL: r.URL.String(), Headers: headers, Body: body}
+		if err := signer.Verify(req); err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	for _, backend := range []Backend{BackendStandard, BackendFastHTTP} {
+		t.Run(string(backend), func(t *testing.T) {
+			client, err := NewClient(backend, WithSigner(signer))
+			if err != nil {
+				t.Fatal(err)
+			}
+			headers := map[string]string{"User-Agent": "Test-Client"}
+			resp := client.Post(context.Background(), server.URL+"/items?x=1", headers, map[string]int{"id": 1})
+			if resp.Error != nil {
+				t.Fatal(resp.Error)
+			}
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("status = %d: %s", resp.StatusCode, resp.Body)
+			}
+			if len(headers) != 1 {
+				t.Errorf("caller headers were modified: %v", headers)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package httpclient
+
+import "net/http"
+
+// http2Mode selects how the standard backend speaks HTTP/2
+type http2Mode int
+
+const (
+	http2Disabled http2Mode = iota
+	// http2TLS negotiates HTTP/2 through ALPN and falls back to HTTP/1.1
+	http2TLS
+	// http2Cleartext uses HTTP/2 with prior knowledge (h2c) for http:// URLs
+	http2Cleartext
+)
+
+// WithHTTP2 lets the standard backend negotiate HTTP/2 over TLS. fasthttp
+// only speaks HTTP/1.1, so NewClient rejects this option for that backend.
+func WithHTTP2() Option {
+	return func(cfg *clientConfig) error {
+		cfg.http2 = http2TLS
+		return nil
+	}
+}
+
+// WithH2C makes the standard backend send http:// requests as cleartext
+// HTTP/2 with prior knowledge; https:// requests still negotiate through
+// ALPN and fall back to HTTP/1.1
+func WithH2C() Option {
+	return func(cfg *clientConfig) error {
+		cfg.http2 = http2Cleartext
+		return nil
+	}
+}
+
+// configureHTTP2 applies the HTTP/2 mode to a standard transport and returns
+// the round tripper to send requests with. net/http only uses h2c when
+// HTTP/1 is disabled, so in cleartext mode http:// requests go to a copy of
+// the transport that speaks nothing else.
+func configureHTTP2(transport *http.Transport, mode http2Mode) http.RoundTripper {
+	if mode == http2Disabled {
+		return transport
+	}
+
+	protocols := new(http.Protocols)
+	protocols.SetHTTP1(true)
+	protocols.SetHTTP2(true)
+	transport.ForceAttemptHTTP2 = true
+	transport.Protocols = protocols
+	if mode != http2Cleartext {
+		return transport
+	}
+
+	cleartext := transport.Clone()
+	cleartext.Protocols = new(http.Protocols)
+	cleartext.Protocols.SetUnencryptedHTTP2(true)
+	return &h2cTransport{cleartext: cleartext, tls: transport}
+}
+
+// h2cTransport sends http:// requests over h2c and the rest over a transport
+// that negotiates the protocol
+type h2cTransport struct {
+	cleartext *http.Transport
+	tls       *http.Transport
+}
+
+func (t *h2cTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme == "http" {
+		return t.cleartext.RoundTrip(req)
+	}
+	return t.tls.RoundTrip(req)
+}
+
+func (t *h2cTransport) CloseIdleConnections() {
+	t.cleartext.CloseIdleConnections()
+	t.tls.CloseIdleConnections()
+}
@@ -0,0 +1,54 @@
+package httpclient
+
+import (
+	"context"
+	"testing"
+)
+
+func TestClientHTTP2(t *testing.T) {
+	tlsServer := setupTLSTestServer(true)
+	defer tlsServer.Close()
+	http1Server := setupTLSTestServer(false)
+	defer http1Server.Close()
+	h2cServer := setupH2CTestServer()
+	defer h2cServer.Close()
+
+	tests := []struct {
+		name  string
+		url   string
+		opts  []Option
+		proto string
+	}{
+		{"tls-default", tlsServer.URL, []Option{trustTestServer(tlsServer)}, "HTTP/1.1"},
+		{"tls-http2", tlsServer.URL, []Option{trustTestServer(tlsServer), WithHTTP2()}, "HTTP/2.0"},
+		{"cleartext-default", h2cServer.URL, nil, "HTTP/1.1"},
+		{"h2c", h2cServer.URL, []Option{WithH2C()}, "HTTP/2.0"},
+		// https requests negotiate as usual in h2c mode, falling back to
+		// HTTP/1.1 for servers without HTTP/2
+		{"h2c-tls", tlsServer.URL, []Option{trustTestServer(tlsServer), WithH2C()}, "HTTP/2.0"},
+		{"h2c-tls-http1-only", http1Server.URL, []Option{trustTestServer(http1Server), WithH2C()}, "HTTP/1.1"},
+		{"tls-http2-http1-only", http1Server.URL, []Option{trustTestServer(http1Server), WithHTTP2()}, "HTTP/1.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := NewClient(BackendStandard, tt.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp := client.Get(context.Background(), tt.url, nil)
+			if resp.Error != nil {
+				t.Fatal(resp.Error)
+			}
+			if got := resp.Headers["X-Proto"]; got != tt.proto {
+				t.Errorf("server saw %s, want %s", got, tt.proto)
+			}
+		})
+	}
+}
+
+func TestFastHTTPRejectsHTTP2(t *testing.T) {
+	if _, err := NewClient(BackendFastHTTP, WithHTTP2()); err == nil {
+		t.Error("expected an error enabling HTTP/2 on the fasthttp backend")
+	}
+}
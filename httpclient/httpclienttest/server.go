@@ -0,0 +1,447 @@
+// Package httpclienttest provides a scriptable HTTP server for testing code
+// built on httpclient against slow, flaky and broken upstreams.
+//
+// Routes are matched in the order they were added, so a route limited with
+// Times can script a sequence such as two failures followed by a success:
+//
+//	server := httpclienttest.NewServer()
+//	defer server.Close()
+//	server.Handle("GET", "/items").Times(2).Respond(http.StatusServiceUnavailable, "")
+//	server.Handle("GET", "/items").Respond(http.StatusOK, `{"items":[]}`)
+package httpclienttest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Server is an httptest.Server whose responses are scripted per route.
+// HTTP/2 is disabled so that both httpclient backends can talk to it.
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	routes   []*Route
+	requests []Request
+	failures []error
+	rng      *rand.Rand
+	noRecord atomic.Bool
+}
+
+// Request is a request the server received
+type Request struct {
+	Method string
+	// URL is the request URI: path and query
+	URL    string
+	Header http.Header
+	Body   []byte
+}
+
+// NewServer starts a server with no routes; every request gets 404 until
+// routes are added
+func NewServer() *Server {
+	s := &Server{rng: rand.New(rand.NewPCG(1, 2))}
+	s.Server = httptest.NewUnstartedServer(http.HandlerFunc(s.serveHTTP))
+	s.EnableHTTP2 = false
+	s.Start()
+	return s
+}
+
+// Seed reseeds the random source behind Fail, Reset and Jitter so that runs
+// are reproducible
+func (s *Server) Seed(seed uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rng = rand.New(rand.NewPCG(seed, seed))
+}
+
+// Handle adds a route for method and path. An empty method matches any
+// method; a path ending in "/" matches every path below it and "*" matches
+// every path. The route answers 200 with an empty body until configured.
+func (s *Server) Handle(method, path string) *Route {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	route := &Route{method: method, path: path, status: http.StatusOK, header: make(http.Header)}
+	s.routes = append(s.routes, route)
+	return route
+}
+
+// Record turns recording of received requests on or off; it is on by
+// default. Benchmarks turn it off so that the server neither copies each
+// request under its lock nor grows with b.N. Requests and Count only see
+// requests received while recording, and routes with expectations still
+// read the requests they check.
+func (s *Server) Record(on bool) {
+	s.noRecord.Store(!on)
+}
+
+// Requests returns the requests received so far, in order
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// Count returns how many requests were received for path
+func (s *Server) Count(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for _, req := range s.requests {
+		if requestPath(req.URL) == path {
+			n++
+		}
+	}
+	return n
+}
+
+// Err returns the failed request assertions, or nil
+func (s *Server) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return errors.Join(s.failures...)
+}
+
+// Verify reports every failed request assertion to tb
+func (s *Server) Verify(tb testing.TB) {
+	tb.Helper()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, err := range s.failures {
+		tb.Error(err)
+	}
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	record := !s.noRecord.Load()
+	var req Request
+	if record {
+		req = newRequest(r)
+	}
+
+	s.mu.Lock()
+	if record {
+		s.requests = append(s.requests, req)
+	}
+	route := s.match(r)
+	var plan fault
+	if route != nil {
+		plan = route.plan(s.rng)
+	}
+	s.mu.Unlock()
+
+	if route != nil && len(route.expects) > 0 {
+		if !record {
+			req = newRequest(r)
+		}
+		var failures []error
+		for _, expect := range route.expects {
+			if err := expect(req); err != nil {
+				failures = append(failures, fmt.Errorf("%s %s: %w", req.Method, req.URL, err))
+			}
+		}
+		s.mu.Lock()
+		s.failures = append(s.failures, failures...)
+		s.mu.Unlock()
+	}
+
+	if route == nil {
+		http.NotFound(w, r)
+		return
+	}
+	route.serve(w, r, plan)
+}
+
+func newRequest(r *http.Request) Request {
+	body, _ := io.ReadAll(r.Body)
+	return Request{Method: r.Method, URL: r.URL.RequestURI(), Header: r.Header.Clone(), Body: body}
+}
+
+// match returns the first route for r that has uses left and takes one of
+// them. s.mu must be held.
+func (s *Server) match(r *http.Request) *Route {
+	for _, route := range s.routes {
+		if route.method != "" && route.method != r.Method {
+			continue
+		}
+		if !pathMatches(route.path, r.URL.Path) {
+			continue
+		}
+		if route.times > 0 && route.served >= route.times {
+			continue
+		}
+		route.served++
+		return route
+	}
+	return nil
+}
+
+func pathMatches(pattern, path string) bool {
+	if pattern == "*" {
+		return true
+	}
+	if strings.HasSuffix(pattern, "/") {
+		return strings.HasPrefix(path, pattern)
+	}
+	return pattern == path
+}
+
+func requestPath(uri string) string {
+	path, _, _ := strings.Cut(uri, "?")
+	return path
+}
+
+// Route scripts the responses to the requests it matches. Its methods
+// configure it and return it for chaining; they must not be called once
+// requests are in flight.
+type Route struct {
+	method string
+	path   string
+
+	status  int
+	header  http.Header
+	body    []byte
+	gzip    bool
+	times   int
+	served  int
+	expects []func(Request) error
+
+	// gzipped is body compressed once, on the first response
+	gzipOnce sync.Once
+	gzipped  []byte
+
+	latency    time.Duration
+	jitter     time.Duration
+	failRate   float64
+	failStatus int
+	resetRate  float64
+	dripChunk  int
+	dripEvery  time.Duration
+	truncateAt int
+}
+
+// fault is what a route does to one response, decided up front so that the
+// random draws happen under the server's lock
+type fault struct {
+	delay time.Duration
+	fail  bool
+	reset bool
+}
+
+// Respond sets the status and body of the route's responses
+func (r *Route) Respond(status int, body string) *Route {
+	return r.RespondBytes(status, []byte(body))
+}
+
+// RespondBytes is Respond with a binary body
+func (r *Route) RespondBytes(status int, body []byte) *Route {
+	r.status = status
+	r.body = body
+	return r
+}
+
+// RespondJSON responds with v encoded as JSON
+func (r *Route) RespondJSON(status int, v any) *Route {
+	body, err := json.Marshal(v)
+	if err != nil {
+		panic(fmt.Sprintf("httpclienttest: encoding response: %v", err))
+	}
+	r.header.Set("Content-Type", "application/json")
+	return r.RespondBytes(status, body)
+}
+
+// Header sets a response header
+func (r *Route) Header(key, value string) *Route {
+	r.header.Set(key, value)
+	return r
+}
+
+// Gzip compresses response bodies with Content-Encoding: gzip whatever the
+// request's Accept-Encoding
+func (r *Route) Gzip() *Route {
+	r.gzip = true
+	return r
+}
+
+// Times limits the route to its first n requests, after which later routes
+// match instead
+func (r *Route) Times(n int) *Route {
+	r.times = n
+	return r
+}
+
+// Latency delays every response by d
+func (r *Route) Latency(d time.Duration) *Route {
+	r.latency = d
+	return r
+}
+
+// Jitter adds a random delay of up to d on top of Latency
+func (r *Route) Jitter(d time.Duration) *Route {
+	r.jitter = d
+	return r
+}
+
+// Fail answers the given fraction of requests with status, which should be
+// a 5xx, instead of the scripted response
+func (r *Route) Fail(fraction float64, status int) *Route {
+	r.failRate = fraction
+	r.failStatus = status
+	return r
+}
+
+// Reset resets the connection without a response for the given fraction of
+// requests
+func (r *Route) Reset(fraction float64) *Route {
+	r.resetRate = fraction
+	return r
+}
+
+// Drip writes the body chunk bytes at a time, flushing and pausing for
+// every between chunks
+func (r *Route) Drip(chunk int, every time.Duration) *Route {
+	r.dripChunk = chunk
+	r.dripEvery = every
+	return r
+}
+
+// Truncate announces the full Content-Length but closes the connection after
+// n bytes of the body
+func (r *Route) Truncate(n int) *Route {
+	r.truncateAt = n
+	return r
+}
+
+// Expect checks every request the route receives; failures are collected
+// for Err and Verify rather than changing the response
+func (r *Route) Expect(check func(Request) error) *Route {
+	r.expects = append(r.expects, check)
+	return r
+}
+
+// ExpectHeader expects requests to carry header key with value
+func (r *Route) ExpectHeader(key, value string) *Route {
+	return r.Expect(func(req Request) error {
+		if got := req.Header.Get(key); got != value {
+			return fmt.Errorf("header %s = %q, want %q", key, got, value)
+		}
+		return nil
+	})
+}
+
+// ExpectBody expects requests to have exactly body
+func (r *Route) ExpectBody(body string) *Route {
+	return r.Expect(func(req Request) error {
+		if string(req.Body) != body {
+			return fmt.Errorf("body = %q, want %q", req.Body, body)
+		}
+		return nil
+	})
+}
+
+func (r *Route) plan(rng *rand.Rand) fault {
+	plan := fault{delay: r.latency}
+	if r.jitter > 0 {
+		plan.delay += time.Duration(rng.Int64N(int64(r.jitter)))
+	}
+	if r.resetRate > 0 && rng.Float64() < r.resetRate {
+		plan.reset = true
+	} else if r.failRate > 0 && rng.Float64() < r.failRate {
+		plan.fail = true
+	}
+	return plan
+}
+
+func (r *Route) serve(w http.ResponseWriter, req *http.Request, plan fault) {
+	if plan.delay > 0 {
+		select {
+		case <-time.After(plan.delay):
+		case <-req.Context().Done():
+			return
+		}
+	}
+	if plan.reset {
+		resetConnection(w)
+		return
+	}
+	if plan.fail {
+		http.Error(w, http.StatusText(r.failStatus), r.failStatus)
+		return
+	}
+
+	body := r.body
+	for key, values := range r.header {
+		w.Header()[key] = values
+	}
+	if r.gzip {
+		r.gzipOnce.Do(func() {
+			var buf bytes.Buffer
+			gz := gzip.NewWriter(&buf)
+			gz.Write(r.body)
+			gz.Close()
+			r.gzipped = buf.Bytes()
+		})
+		body = r.gzipped
+		w.Header().Set("Content-Encoding", "gzip")
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(r.status)
+
+	truncated := r.truncateAt > 0 && r.truncateAt < len(body)
+	if truncated {
+		body = body[:r.truncateAt]
+	}
+	if r.dripChunk > 0 {
+		drip(w, req, body, r.dripChunk, r.dripEvery)
+	} else {
+		w.Write(body)
+	}
+	if truncated {
+		w.(http.Flusher).Flush()
+		panic(http.ErrAbortHandler)
+	}
+}
+
+func drip(w http.ResponseWriter, req *http.Request, body []byte, chunk int, every time.Duration) {
+	flusher := w.(http.Flusher)
+	for len(body) > 0 {
+		n := min(chunk, len(body))
+		w.Write(body[:n])
+		flusher.Flush()
+		body = body[n:]
+		if len(body) == 0 {
+			return
+		}
+		select {
+		case <-time.After(every):
+		case <-req.Context().Done():
+			return
+		}
+	}
+}
+
+// resetConnection closes the connection with an RST instead of a FIN
+func resetConnection(w http.ResponseWriter) {
+	conn, _, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		panic(http.ErrAbortHandler)
+	}
+	if tcp, ok := conn.(*net.TCPConn); ok {
+		tcp.SetLinger(0)
+	}
+	conn.Close()
+}
@@ -0,0 +1,214 @@
+package httpclienttest
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func get(t *testing.T, url string) (*http.Response, []byte, error) {
+	t.Helper()
+	client := &http.Client{Transport: &http.Transport{DisableCompression: true, DisableKeepAlives: true}}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	return resp, body, err
+}
+
+func TestRoutes(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	server.Handle(http.MethodGet, "/items").Times(2).Respond(http.StatusServiceUnavailable, "busy")
+	server.Handle(http.MethodGet, "/items").RespondJSON(http.StatusOK, map[string]int{"count": 1})
+	server.Handle("", "/static/").Header("Cache-Control", "max-age=60").Respond(http.StatusOK, "asset")
+
+	tests := []struct {
+		path   string
+		status int
+		body   string
+	}{
+		{"/items", http.StatusServiceUnavailable, "busy"},
+		{"/items", http.StatusServiceUnavailable, "busy"},
+		{"/items?page=2", http.StatusOK, `{"count":1}`},
+		{"/static/app.js", http.StatusOK, "asset"},
+		{"/other", http.StatusNotFound, "404 page not found\n"},
+	}
+	for _, tt := range tests {
+		resp, body, err := get(t, server.URL+tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != tt.status || string(body) != tt.body {
+			t.Errorf("GET %s = %d %q, want %d %q", tt.path, resp.StatusCode, body, tt.status, tt.body)
+		}
+	}
+	if n := server.Count("/items"); n != 3 {
+		t.Errorf("Count(/items) = %d, want 3", n)
+	}
+	if got := server.Requests()[2].URL; got != "/items?page=2" {
+		t.Errorf("third request URL = %q", got)
+	}
+}
+
+func TestGzip(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	server.Handle("", "*").Gzip().Respond(http.StatusOK, strings.Repeat("compressible ", 100))
+
+	resp, body, err := get(t, server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Header.Get("Content-Encoding") != "gzip" || len(body) >= 1300 {
+		t.Fatalf("got %d bytes with encoding %q", len(body), resp.Header.Get("Content-Encoding"))
+	}
+	gz, err := gzip.NewReader(strings.NewReader(string(body)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, _ := io.ReadAll(gz)
+	if string(decoded) != strings.Repeat("compressible ", 100) {
+		t.Errorf("decoded body = %q", decoded)
+	}
+}
+
+func TestLatency(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	server.Handle("", "*").Latency(50 * time.Millisecond).Jitter(10 * time.Millisecond)
+
+	start := time.Now()
+	if _, _, err := get(t, server.URL); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("response took %v, want at least 50ms", elapsed)
+	}
+}
+
+func TestFail(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	server.Seed(42)
+	server.Handle("", "*").Fail(0.5, http.StatusBadGateway)
+
+	failed := 0
+	for range 100 {
+		resp, _, err := get(t, server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode == http.StatusBadGateway {
+			failed++
+		}
+	}
+	if failed < 30 || failed > 70 {
+		t.Errorf("%d of 100 requests failed, want about half", failed)
+	}
+}
+
+func TestReset(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	server.Handle("", "*").Reset(1)
+
+	_, _, err := get(t, server.URL)
+	if !errors.Is(err, syscall.ECONNRESET) && !errors.Is(err, io.EOF) {
+		t.Errorf("err = %v, want a connection reset", err)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	server.Handle("", "*").Truncate(5).Respond(http.StatusOK, "0123456789")
+
+	_, body, err := get(t, server.URL)
+	if !errors.Is(err, io.ErrUnexpectedEOF) || string(body) != "01234" {
+		t.Errorf("got %q, %v; want 5 bytes and io.ErrUnexpectedEOF", body, err)
+	}
+}
+
+func TestDrip(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	server.Handle("", "*").Drip(2, 20*time.Millisecond).Respond(http.StatusOK, "abcdef")
+
+	start := time.Now()
+	_, body, err := get(t, server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "abcdef" {
+		t.Errorf("body = %q", body)
+	}
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("3 chunks took %v, want at least 40ms", elapsed)
+	}
+}
+
+func TestExpect(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	server.Handle(http.MethodPost, "/submit").ExpectHeader("X-Token", "abc").ExpectBody("payload")
+
+	req, _ := http.NewRequest(http.MethodPost, server.URL+"/submit", strings.NewReader("payload"))
+	req.Header.Set("X-Token", "abc")
+	if _, err := http.DefaultClient.Do(req); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.Err(); err != nil {
+		t.Errorf("unexpected failures: %v", err)
+	}
+
+	req, _ = http.NewRequest(http.MethodPost, server.URL+"/submit", strings.NewReader("other"))
+	if _, err := http.DefaultClient.Do(req); err != nil {
+		t.Fatal(err)
+	}
+	err := server.Err()
+	if err == nil || !strings.Contains(err.Error(), "X-Token") || !strings.Contains(err.Error(), `"other"`) {
+		t.Errorf("Err() = %v, want the header and body failures", err)
+	}
+}
+
+func TestRecordOff(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	server.Record(false)
+	server.Handle(http.MethodPost, "/submit").ExpectBody("payload")
+	server.Handle("", "*")
+
+	for _, body := range []string{"payload", "other"} {
+		resp, err := http.Post(server.URL+"/submit", "text/plain", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	if _, err := http.Get(server.URL + "/items"); err != nil {
+		t.Fatal(err)
+	}
+	if got := server.Requests(); len(got) != 0 {
+		t.Errorf("recorded %d requests with recording off", len(got))
+	}
+	// Expectations still see the requests they check
+	if err := server.Err(); err == nil || !strings.Contains(err.Error(), `"other"`) {
+		t.Errorf("Err() = %v, want the body failure", err)
+	}
+
+	server.Record(true)
+	if _, err := http.Get(server.URL + "/items"); err != nil {
+		t.Fatal(err)
+	}
+	if n := server.Count("/items"); n != 1 {
+		t.Errorf("Count(/items) = %d, want 1", n)
+	}
+}
@@ -18,11 +18,25 @@ type HTTPResponse struct {
 	Body       []byte
 	Headers    map[string]string
 	Error      error
+	// Redirects lists the redirects followed to get the response
+	Redirects []Redirect
+	// WireBytesRead and WireBytesWritten count what a Client's request
+	// actually moved over the network: headers, chunked framing, TLS records
+	// and, for the first request on a connection, the TLS handshake. They
+	// add up across redirects. HTTP/2 multiplexes requests on a connection,
+	// so in that mode they are approximate.
+	WireBytesRead    int64
+	WireBytesWritten int64
 }
 
-// Create a shared standard HTTP client for better connection reuse
+// Create a shared standard HTTP client for better connection reuse. It
+// follows redirects under DefaultRedirectPolicy, like a Client, reading the
+// policy on every redirect so that changes to it apply.
 var standardClient = &http.Client{
 	Timeout: 10 * time.Second,
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return DefaultRedirectPolicy.checkRedirect(req, via)
+	},
 	Transport: &http.Transport{
 		MaxIdleConns:        1000,
 		MaxIdleConnsPerHost: 100,
@@ -68,6 +82,7 @@ func StandardGet(ctx context.Context, url string, headers map[string]string, tim
 		StatusCode: resp.StatusCode,
 		Body:       body,
 		Headers:    respHeaders,
+		Redirects:  followedRedirects(resp),
 	}
 }
 
@@ -122,10 +137,12 @@ func StandardPost(ctx context.Context, url string, headers map[string]string, bo
 		StatusCode: resp.StatusCode,
 		Body:       respBody,
 		Headers:    respHeaders,
+		Redirects:  followedRedirects(resp),
 	}
 }
 
-// Create a shared fasthttp client
+// Create a shared fasthttp client. fasthttp does not follow redirects
+// itself, so fasthttpDo does.
 var fasthttpClient = &fasthttp.Client{
 	MaxConnsPerHost:          1000,
 	ReadTimeout:              10 * time.Second,
@@ -136,79 +153,79 @@ var fasthttpClient = &fasthttp.Client{
 
 // FastHTTPGet makes a GET request using the fasthttp package
 func FastHTTPGet(url string, headers map[string]string, timeout time.Duration) HTTPResponse {
-	req := fasthttp.AcquireRequest()
-	resp := fasthttp.AcquireResponse()
-	defer fasthttp.ReleaseRequest(req)
-	defer fasthttp.ReleaseResponse(resp)
+	return fasthttpDo(&Request{Method: http.MethodGet, URL: url, Headers: headers}, timeout)
+}
 
-	req.SetRequestURI(url)
-	req.Header.SetMethod("GET")
+// FastHTTPPost makes a POST request using the fasthttp package
+func FastHTTPPost(url string, headers map[string]string, body interface{}, timeout time.Duration) HTTPResponse {
+	req := &Request{Method: http.MethodPost, URL: url, Headers: headers}
 
-	for key, value := range headers {
-		req.Header.Set(key, value)
-	}
+	// Marshal body to JSON if it's not nil
+	if body != nil {
+		bodyBytes, err := json.Marshal(body)
+		if err != nil {
+			return HTTPResponse{Error: fmt.Errorf("error marshaling request body: %w", err)}
+		}
+		req.Body = bodyBytes
 
-	err := fasthttpClient.DoTimeout(req, resp, timeout)
-	if err != nil {
-		return HTTPResponse{Error: fmt.Errorf("error making request: %w", err)}
+		// Set content type if not specified
+		if _, exists := headers["Content-Type"]; !exists {
+			req = req.Clone()
+			req.Headers["Content-Type"] = "application/json"
+		}
 	}
 
-	// Extract headers
-	respHeaders := make(map[string]string)
-	resp.Header.VisitAll(func(key, value []byte) {
-		respHeaders[string(key)] = string(value)
-	})
-
-	return HTTPResponse{
-		StatusCode: resp.StatusCode(),
-		Body:       resp.Body(),
-		Headers:    respHeaders,
-	}
+	return fasthttpDo(req, timeout)
 }
 
-// FastHTTPPost makes a POST request using the fasthttp package
-func FastHTTPPost(url string, headers map[string]string, body interface{}, timeout time.Duration) HTTPResponse {
+// fasthttpDo sends r with the shared fasthttp client, following redirects
+// under DefaultRedirectPolicy within one timeout
+func fasthttpDo(r *Request, timeout time.Duration) HTTPResponse {
 	req := fasthttp.AcquireRequest()
 	resp := fasthttp.AcquireResponse()
 	defer fasthttp.ReleaseRequest(req)
 	defer fasthttp.ReleaseResponse(resp)
 
-	req.SetRequestURI(url)
-	req.Header.SetMethod("POST")
+	deadline := time.Now().Add(timeout)
+	var chain []Redirect
+	for {
+		req.Reset()
+		req.SetRequestURI(r.URL)
+		req.Header.SetMethod(r.Method)
 
-	// Add headers
-	for key, value := range headers {
-		req.Header.Set(key, value)
-	}
-
-	// Set content type if not specified
-	if _, exists := headers["Content-Type"]; !exists && body != nil {
-		req.Header.Set("Content-Type", "application/json")
-	}
+		// Add headers
+		for key, value := range r.Headers {
+			req.Header.Set(key, value)
+		}
+		if r.Body != nil {
+			req.SetBody(r.Body)
+		}
 
-	// Marshal body to JSON if it's not nil
-	if body != nil {
-		bodyBytes, err := json.Marshal(body)
+		err := fasthttpClient.DoDeadline(req, resp, deadline)
 		if err != nil {
-			return HTTPResponse{Error: fmt.Errorf("error marshaling request body: %w", err)}
+			return HTTPResponse{Error: fmt.Errorf("error making request: %w", err)}
 		}
-		req.SetBody(bodyBytes)
-	}
 
-	err := fasthttpClient.DoTimeout(req, resp, timeout)
-	if err != nil {
-		return HTTPResponse{Error: fmt.Errorf("error making request: %w", err)}
-	}
+		// Extract headers
+		respHeaders := make(map[string]string)
+		resp.Header.VisitAll(func(key, value []byte) {
+			respHeaders[string(key)] = string(value)
+		})
 
-	// Extract headers
-	respHeaders := make(map[string]string)
-	resp.Header.VisitAll(func(key, value []byte) {
-		respHeaders[string(key)] = string(value)
-	})
-
-	return HTTPResponse{
-		StatusCode: resp.StatusCode(),
-		Body:       resp.Body(),
-		Headers:    respHeaders,
+		next, err := DefaultRedirectPolicy.next(r, resp.StatusCode(), respHeaders, len(chain))
+		if err != nil {
+			return HTTPResponse{Error: err, Redirects: chain}
+		}
+		if next == nil {
+			return HTTPResponse{
+				StatusCode: resp.StatusCode(),
+				// The response goes back to the pool, so its body is copied
+				Body:      append([]byte(nil), resp.Body()...),
+				Headers:   respHeaders,
+				Redirects: chain,
+			}
+		}
+		chain = append(chain, Redirect{URL: r.URL, StatusCode: resp.StatusCode(), Location: next.URL})
+		r = next
 	}
 }
@@ -0,0 +1,129 @@
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+// setupMultipartTestServer parses multipart uploads and echoes back the
+// request's Content-Length and each part as "name=filename:type:content"
+func setupMultipartTestServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reader, err := r.MultipartReader()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		fmt.Fprintf(w, "length=%d", r.ContentLength)
+		for {
+			part, err := reader.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			content, err := io.ReadAll(part)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			fmt.Fprintf(w, " %s=%s:%s:%s", part.FormName(), part.FileName(), part.Header.Get("Content-Type"), content)
+		}
+	}))
+}
+
+func TestPostMultipart(t *testing.T) {
+	server := setupMultipartTestServer()
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "report.json")
+	if err := os.WriteFile(path, []byte("file contents"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, backend := range []Backend{BackendStandard, BackendFastHTTP} {
+		t.Run(string(backend), func(t *testing.T) {
+			client, err := NewClient(backend)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			file, err := os.Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer file.Close()
+
+			var sent, total int64
+			form := NewMultipart().
+				AddField("title", `quarterly "final"`).
+				AddFile("report", "report.json", file).
+				AddFileWithType("blob", "data.bin", "application/x-test", bytes.NewReader([]byte{1, 2, 3}))
+			form.Progress = func(s, t int64) { sent, total = s, t }
+
+			resp := client.PostMultipart(context.Background(), server.URL, nil, form)
+			if resp.Error != nil {
+				t.Fatal(resp.Error)
+			}
+
+			want := "length=" + strconv.FormatInt(form.Size(), 10) +
+				` title=::quarterly "final"` +
+				" report=report.json:application/json:file contents" +
+				" blob=data.bin:application/x-test:\x01\x02\x03"
+			if resp.StatusCode != http.StatusOK || string(resp.Body) != want {
+				t.Errorf("response = %d %q, want %q", resp.StatusCode, resp.Body, want)
+			}
+			if sent != form.Size() || total != form.Size() {
+				t.Errorf("last progress = %d/%d, want %d/%d", sent, total, form.Size(), form.Size())
+			}
+		})
+	}
+}
+
+func TestPostMultipartUnknownSize(t *testing.T) {
+	server := setupMultipartTestServer()
+	defer server.Close()
+
+	for _, backend := range []Backend{BackendStandard, BackendFastHTTP} {
+		t.Run(string(backend), func(t *testing.T) {
+			client, err := NewClient(backend)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// A pipe hides the size, so the body has to be sent chunked while
+			// the writer is still producing it
+			pr, pw := io.Pipe()
+			go func() {
+				for i := range 3 {
+					fmt.Fprintf(pw, "chunk%d;", i)
+				}
+				pw.Close()
+			}()
+
+			form := NewMultipart().AddFile("log", "app", pr)
+			if form.Size() != -1 {
+				t.Fatalf("Size() = %d, want -1", form.Size())
+			}
+
+			resp := client.PostMultipart(context.Background(), server.URL, nil, form)
+			if resp.Error != nil {
+				t.Fatal(resp.Error)
+			}
+			want := "length=-1 log=app:application/octet-stream:chunk0;chunk1;chunk2;"
+			if string(resp.Body) != want {
+				t.Errorf("response = %q, want %q", resp.Body, want)
+			}
+		})
+	}
+}
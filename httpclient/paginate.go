@@ -0,0 +1,284 @@
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"iter"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Pager decides which request fetches the page after resp
+type Pager interface {
+	// NextRequest returns the request for the following page, or nil when
+	// resp was the last page
+	NextRequest(req *Request, resp HTTPResponse, codec Codec) (*Request, error)
+}
+
+// Paginate fetches req and every following page selected by pager, decoding
+// each page into a T with the client's codec. Iteration stops after the last
+// page, on the first error (which is yielded with a zero T) or when ctx is
+// cancelled.
+func Paginate[T any](ctx context.Context, c *Client, req *Request, pager Pager) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		seen := make(map[string]bool)
+		for req != nil {
+			var page T
+			if seen[req.URL] {
+				yield(page, fmt.Errorf("pagination loop: %s was already fetched", req.URL))
+				return
+			}
+			seen[req.URL] = true
+
+			resp := c.Do(ctx, req)
+			if resp.Error != nil {
+				yield(page, resp.Error)
+				return
+			}
+			if resp.StatusCode < 200 || resp.StatusCode > 299 {
+				yield(page, fmt.Errorf("unexpected status %d fetching %s", resp.StatusCode, req.URL))
+				return
+			}
+			if err := c.codec.Unmarshal(resp.Body, &page); err != nil {
+				yield(page, fmt.Errorf("error decoding page: %w", err))
+				return
+			}
+
+			next, err := pager.NextRequest(req, resp, c.codec)
+			if !yield(page, nil) {
+				return
+			}
+			if err != nil {
+				var zero T
+				yield(zero, err)
+				return
+			}
+			req = next
+		}
+	}
+}
+
+// LinkPager follows the RFC 8288 Link header entry with rel="next". A
+// Client joins repeated Link fields into one value, so links split across
+// several fields are all seen.
+type LinkPager struct{}
+
+// NextRequest implements Pager
+func (LinkPager) NextRequest(req *Request, resp HTTPResponse, _ Codec) (*Request, error) {
+	header, _ := lookupHeader(resp.Headers, "Link")
+	target, ok := parseNextLink(header)
+	if !ok {
+		return nil, nil
+	}
+	return withResolvedURL(req, target)
+}
+
+// parseNextLink returns the target of the first link whose rel includes next
+func parseNextLink(header string) (string, bool) {
+	for header != "" {
+		start := strings.IndexByte(header, '<')
+		end := strings.IndexByte(header, '>')
+		if start < 0 || end < start {
+			return "", false
+		}
+		target := header[start+1 : end]
+		header = header[end+1:]
+
+		// Parameters run until the next link, which starts with '<'
+		params := header
+		if next := strings.IndexByte(header, '<'); next >= 0 {
+			params, header = header[:next], header[next:]
+		} else {
+			header = ""
+		}
+
+		for _, param := range strings.Split(params, ";") {
+			name, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if !ok || !strings.EqualFold(strings.TrimSpace(name), "rel") {
+				continue
+			}
+			for _, rel := range strings.Fields(strings.Trim(strings.TrimSpace(value), `",`)) {
+				if strings.EqualFold(rel, "next") {
+					return target, true
+				}
+			}
+		}
+	}
+	return "", false
+}
+
+// CursorPager reads the next cursor from a field of the decoded page and
+// sends it back as a query parameter
+type CursorPager struct {
+	// CursorPath is the dot-separated path of the cursor field, e.g.
+	// "meta.next_cursor". A missing, null or empty cursor ends pagination.
+	CursorPath string
+	// Param is the query parameter carrying the cursor; defaults to "cursor"
+	Param string
+}
+
+// NextRequest implements Pager. The page is decoded with encoding/json
+// rather than codec so that numeric cursors, often IDs beyond 2^53, are
+// sent back with every digit.
+func (p CursorPager) NextRequest(req *Request, resp HTTPResponse, _ Codec) (*Request, error) {
+	decoder := json.NewDecoder(bytes.NewReader(resp.Body))
+	decoder.UseNumber()
+	var doc interface{}
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("error decoding page: %w", err)
+	}
+
+	value, ok := lookupPath(doc, p.CursorPath)
+	if !ok || value == nil {
+		return nil, nil
+	}
+	var cursor string
+	switch v := value.(type) {
+	case string:
+		cursor = v
+	case json.Number:
+		cursor = v.String()
+	default:
+		return nil, fmt.Errorf("cursor at %q is a %T, not a string or number", p.CursorPath, value)
+	}
+	if cursor == "" {
+		return nil, nil
+	}
+
+	param := p.Param
+	if param == "" {
+		param = "cursor"
+	}
+	return withQuery(req, map[string]string{param: cursor})
+}
+
+// OffsetPager steps an offset query parameter by Limit until a page returns
+// fewer than Limit items
+type OffsetPager struct {
+	// Limit is the page size sent with every request
+	Limit int
+	// ItemsPath is the dot-separated path of the item array in the page;
+	// empty means the page itself is the array
+	ItemsPath string
+	// OffsetParam and LimitParam default to "offset" and "limit"
+	OffsetParam string
+	LimitParam  string
+}
+
+// NextRequest implements Pager
+func (p OffsetPager) NextRequest(req *Request, resp HTTPResponse, codec Codec) (*Request, error) {
+	if p.Limit <= 0 {
+		return nil, fmt.Errorf("offset pager limit must be positive, got %d", p.Limit)
+	}
+
+	var doc interface{}
+	if err := codec.Unmarshal(resp.Body, &doc); err != nil {
+		return nil, fmt.Errorf("error decoding page: %w", err)
+	}
+	value, _ := lookupPath(doc, p.ItemsPath)
+	items, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("no item array at %q", p.ItemsPath)
+	}
+	if len(items) < p.Limit {
+		return nil, nil
+	}
+
+	u, err := url.Parse(req.URL)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing url: %w", err)
+	}
+	offset, _ := strconv.Atoi(u.Query().Get(p.offsetParam()))
+
+	return withQuery(req, map[string]string{
+		p.offsetParam(): strconv.Itoa(offset + len(items)),
+		p.limitParam():  strconv.Itoa(p.Limit),
+	})
+}
+
+// FirstRequest returns req with the limit and a zero offset applied, for
+// starting a pagination
+func (p OffsetPager) FirstRequest(req *Request) (*Request, error) {
+	return withQuery(req, map[string]string{
+		p.offsetParam(): "0",
+		p.limitParam():  strconv.Itoa(p.Limit),
+	})
+}
+
+func (p OffsetPager) offsetParam() string {
+	if p.OffsetParam != "" {
+		return p.OffsetParam
+	}
+	return "offset"
+}
+
+func (p OffsetPager) limitParam() string {
+	if p.LimitParam != "" {
+		return p.LimitParam
+	}
+	return "limit"
+}
+
+// lookupPath walks a decoded document along a dot-separated path of object
+// keys and array indexes
+func lookupPath(doc interface{}, path string) (interface{}, bool) {
+	if path == "" {
+		return doc, true
+	}
+	for _, key := range strings.Split(path, ".") {
+		switch node := doc.(type) {
+		case map[string]interface{}:
+			value, ok := node[key]
+			if !ok {
+				return nil, false
+			}
+			doc = value
+		case []interface{}:
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 || index >= len(node) {
+				return nil, false
+			}
+			doc = node[index]
+		default:
+			return nil, false
+		}
+	}
+	return doc, true
+}
+
+// withQuery returns a copy of req with the query parameters set
+func withQuery(req *Request, params map[string]string) (*Request, error) {
+	u, err := url.Parse(req.URL)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing url: %w", err)
+	}
+	query := u.Query()
+	for key, value := range params {
+		query.Set(key, value)
+	}
+	u.RawQuery = query.Encode()
+
+	next := req.Clone()
+	next.URL = u.String()
+	return next, nil
+}
+
+// withResolvedURL returns a copy of req pointing at target resolved against
+// the request URL
+func withResolvedURL(req *Request, target string) (*Request, error) {
+	base, err := url.Parse(req.URL)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing url: %w", err)
+	}
+	ref, err := url.Parse(target)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing link %q: %w", target, err)
+	}
+
+	next := req.Clone()
+	next.URL = base.ResolveReference(ref).String()
+	return next, nil
+}
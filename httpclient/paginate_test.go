@@ -0,0 +1,176 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+type testItemsPage struct {
+	Items []int `json:"items"`
+	Meta  struct {
+		NextCursor *string `json:"next_cursor"`
+	} `json:"meta"`
+}
+
+// setupPaginatedTestServer serves the items 0..9 three at a time under
+// /link, /cursor and /offset, each using its own pagination style
+func setupPaginatedTestServer() *httptest.Server {
+	const total, pageSize = 10, 3
+
+	page := func(start, size int) []int {
+		items := []int{}
+		for i := start; i < min(start+size, total); i++ {
+			items = append(items, i)
+		}
+		return items
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/link", func(w http.ResponseWriter, r *http.Request) {
+		n, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if (n+1)*pageSize < total {
+			// rel="next" comes in a second Link field
+			w.Header().Add("Link", `</link?page=0>; rel="first"`)
+			w.Header().Add("Link", fmt.Sprintf(`</link?page=%d>; rel="next"`, n+1))
+		}
+		fmt.Fprintf(w, `{"items": %s}`, jsonInts(page(n*pageSize, pageSize)))
+	})
+	mux.HandleFunc("/cursor", func(w http.ResponseWriter, r *http.Request) {
+		start, _ := strconv.Atoi(r.URL.Query().Get("after"))
+		next := "null"
+		if start+pageSize < total {
+			next = strconv.Quote(strconv.Itoa(start + pageSize))
+		}
+		fmt.Fprintf(w, `{"items": %s, "meta": {"next_cursor": %s}}`, jsonInts(page(start, pageSize)), next)
+	})
+	mux.HandleFunc("/offset", func(w http.ResponseWriter, r *http.Request) {
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		fmt.Fprintf(w, `{"items": %s}`, jsonInts(page(offset, limit)))
+	})
+	return httptest.NewServer(mux)
+}
+
+func jsonInts(items []int) string {
+	out := "["
+	for i, item := range items {
+		if i > 0 {
+			out += ","
+		}
+		out += strconv.Itoa(item)
+	}
+	return out + "]"
+}
+
+func TestPaginate(t *testing.T) {
+	server := setupPaginatedTestServer()
+	defer server.Close()
+
+	offsetPager := OffsetPager{Limit: 3, ItemsPath: "items"}
+	offsetStart, err := offsetPager.FirstRequest(&Request{Method: http.MethodGet, URL: server.URL + "/offset"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	styles := []struct {
+		name  string
+		req   *Request
+		pager Pager
+	}{
+		{"link", &Request{Method: http.MethodGet, URL: server.URL + "/link"}, LinkPager{}},
+		{"cursor", &Request{Method: http.MethodGet, URL: server.URL + "/cursor"}, CursorPager{CursorPath: "meta.next_cursor", Param: "after"}},
+		{"offset", offsetStart, offsetPager},
+	}
+
+	for _, backend := range []Backend{BackendStandard, BackendFastHTTP} {
+		for _, style := range styles {
+			t.Run(string(backend)+"/"+style.name, func(t *testing.T) {
+				client, err := NewClient(backend, WithCodec(JSONIterCodec))
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				var items []int
+				pages := 0
+				for page, err := range Paginate[testItemsPage](context.Background(), client, style.req, style.pager) {
+					if err != nil {
+						t.Fatal(err)
+					}
+					pages++
+					items = append(items, page.Items...)
+				}
+
+				if pages != 4 {
+					t.Errorf("fetched %d pages, want 4", pages)
+				}
+				if jsonInts(items) != "[0,1,2,3,4,5,6,7,8,9]" {
+					t.Errorf("items = %v", items)
+				}
+			})
+		}
+	}
+}
+
+func TestPaginateStopsOnCancel(t *testing.T) {
+	server := setupPaginatedTestServer()
+	defer server.Close()
+
+	client, err := NewClient(BackendStandard)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var gotErr error
+	pages := 0
+	for _, err := range Paginate[testItemsPage](ctx, client, &Request{Method: http.MethodGet, URL: server.URL + "/link"}, LinkPager{}) {
+		if err != nil {
+			gotErr = err
+			break
+		}
+		pages++
+		cancel()
+	}
+	if pages != 1 || !errors.Is(gotErr, context.Canceled) {
+		t.Errorf("pages = %d, err = %v; want 1 page then context.Canceled", pages, gotErr)
+	}
+}
+
+func TestCursorPagerNumericCursor(t *testing.T) {
+	// Snowflake IDs do not fit in a float64
+	resp := HTTPResponse{Body: []byte(`{"meta": {"next": 1234567890123456789}}`)}
+	req := &Request{Method: http.MethodGet, URL: "https://api.example.com/items?limit=10"}
+
+	next, err := CursorPager{CursorPath: "meta.next"}.NextRequest(req, resp, StandardJSONCodec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "https://api.example.com/items?cursor=1234567890123456789&limit=10"; next.URL != want {
+		t.Errorf("URL = %q, want %q", next.URL, want)
+	}
+}
+
+func TestParseNextLink(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{`<https://api.example.com/items?page=2>; rel="next"`, "https://api.example.com/items?page=2"},
+		{`</items?page=1>; rel="prev", </items?page=3>; rel="next last"`, "/items?page=3"},
+		{`</items?page=9>; rel=last`, ""},
+		{``, ""},
+	}
+	for _, tt := range tests {
+		got, _ := parseNextLink(tt.header)
+		if got != tt.want {
+			t.Errorf("parseNextLink(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,513 @@
+package httpclient
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// ProxyFunc returns the proxy to use for requests with the given URL scheme
+// (http or https) to addr (host:port), or nil to connect directly
+type ProxyFunc func(scheme, addr string) (*url.URL, error)
+
+// WithProxy sends every request through the proxy at proxyURL. Supported
+// schemes are http, https, socks5 and socks5h; credentials in the URL are
+// used for proxy authentication. An http or https proxy receives plain http
+// requests in absolute-form and tunnels https requests with CONNECT.
+func WithProxy(proxyURL string) Option {
+	return func(cfg *clientConfig) error {
+		u, err := parseProxyURL(proxyURL)
+		if err != nil {
+			return err
+		}
+		cfg.proxy = func(string, string) (*url.URL, error) { return u, nil }
+		return nil
+	}
+}
+
+// WithProxyFromEnvironment selects the proxy from HTTP_PROXY, HTTPS_PROXY and
+// NO_PROXY (or their lowercase forms) the same way for both backends
+func WithProxyFromEnvironment() Option {
+	return func(cfg *clientConfig) error {
+		proxy, err := ProxyFromEnvironment()
+		if err != nil {
+			return err
+		}
+		cfg.proxy = proxy
+		return nil
+	}
+}
+
+// WithProxyFunc selects the proxy for each connection with fn
+func WithProxyFunc(fn ProxyFunc) Option {
+	return func(cfg *clientConfig) error {
+		cfg.proxy = fn
+		return nil
+	}
+}
+
+// ProxyFromEnvironment reads the proxy environment variables once and returns
+// a ProxyFunc applying them: https requests use HTTPS_PROXY and http requests
+// HTTP_PROXY. Loopback addresses are never proxied, matching net/http.
+func ProxyFromEnvironment() (ProxyFunc, error) {
+	httpProxy, err := parseEnvProxy("HTTP_PROXY", "http_proxy")
+	if err != nil {
+		return nil, err
+	}
+	httpsProxy, err := parseEnvProxy("HTTPS_PROXY", "https_proxy")
+	if err != nil {
+		return nil, err
+	}
+	noProxy := parseNoProxy(getEnvAny("NO_PROXY", "no_proxy"))
+
+	return func(scheme, addr string) (*url.URL, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, fmt.Errorf("proxy: invalid address %q: %w", addr, err)
+		}
+		if isLoopback(host) || noProxy.matches(host, port) {
+			return nil, nil
+		}
+		if scheme == "https" {
+			return httpsProxy, nil
+		}
+		return httpProxy, nil
+	}, nil
+}
+
+func getEnvAny(names ...string) string {
+	for _, name := range names {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+func parseEnvProxy(names ...string) (*url.URL, error) {
+	value := getEnvAny(names...)
+	if value == "" {
+		return nil, nil
+	}
+	// Like net/http, a bare host:port means an HTTP proxy
+	if !strings.Contains(value, "://") {
+		value = "http://" + value
+	}
+	return parseProxyURL(value)
+}
+
+func parseProxyURL(proxyURL string) (*url.URL, error) {
+	u, err := url.Parse(proxyURL)
+	if err != nil {
+		return nil, fmt.Errorf("proxy: invalid url: %w", err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("proxy: unsupported scheme %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("proxy: url %q has no host", proxyURL)
+	}
+	return u, nil
+}
+
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// noProxyList holds the parsed NO_PROXY entries
+type noProxyList struct {
+	all      bool
+	networks []*net.IPNet
+	hosts    []noProxyHost
+}
+
+type noProxyHost struct {
+	domain string
+	port   string
+}
+
+func parseNoProxy(value string) noProxyList {
+	var list noProxyList
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		switch {
+		case entry == "":
+			continue
+		case entry == "*":
+			list.all = true
+			continue
+		}
+
+		if _, network, err := net.ParseCIDR(entry); err == nil {
+			list.networks = append(list.networks, network)
+			continue
+		}
+
+		host, port := entry, ""
+		if h, p, err := net.SplitHostPort(entry); err == nil {
+			host, port = h, p
+		}
+		list.hosts = append(list.hosts, noProxyHost{domain: strings.TrimPrefix(host, "*"), port: port})
+	}
+	return list
+}
+
+// matches reports whether the host bypasses the proxy. As in net/http, a
+// domain entry covers the domain and its subdomains, while a leading dot
+// restricts it to subdomains.
+func (l noProxyList) matches(host, port string) bool {
+	if l.all {
+		return true
+	}
+	host = strings.ToLower(host)
+
+	if ip := net.ParseIP(host); ip != nil {
+		for _, network := range l.networks {
+			if network.Contains(ip) {
+				return true
+			}
+		}
+	}
+
+	for _, entry := range l.hosts {
+		if entry.port != "" && entry.port != port {
+			continue
+		}
+		if strings.HasPrefix(entry.domain, ".") {
+			if strings.HasSuffix(host, entry.domain) {
+				return true
+			}
+			continue
+		}
+		if host == entry.domain || strings.HasSuffix(host, "."+entry.domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// proxyDialer opens connections for requests with the given scheme through
+// the selected proxy. https requests are tunnelled with HTTP CONNECT or
+// SOCKS5 so that both backends see a plain connection to the target; plain
+// http requests to an HTTP proxy get a connection to the proxy itself and
+// are rewritten to absolute-form by forwardStandard and forwardFastHTTP.
+type proxyDialer struct {
+	proxy  ProxyFunc
+	scheme string
+	dial   func(ctx context.Context, network, addr string) (net.Conn, error)
+}
+
+func (d *proxyDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	proxyURL, err := d.proxy(d.scheme, addr)
+	if err != nil {
+		return nil, err
+	}
+	if proxyURL == nil {
+		return d.dial(ctx, network, addr)
+	}
+
+	proxyAddr := proxyURL.Host
+	if proxyURL.Port() == "" {
+		proxyAddr = net.JoinHostPort(proxyURL.Hostname(), defaultProxyPort(proxyURL.Scheme))
+	}
+
+	conn, err := d.dial(ctx, "tcp", proxyAddr)
+	if err != nil {
+		return nil, fmt.Errorf("proxy: error connecting to %s: %w", proxyAddr, err)
+	}
+
+	// Bound the handshake by the context so a stalled proxy cannot hang the dial
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+		defer conn.SetDeadline(time.Time{})
+	}
+
+	if proxyURL.Scheme == "https" {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: proxyURL.Hostname()})
+		if err = tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn = tlsConn
+	}
+
+	switch {
+	case isSOCKSProxy(proxyURL):
+		err = socks5Connect(conn, proxyURL, addr)
+	case d.scheme == "http":
+		// The request itself is sent to the proxy in absolute-form
+	default:
+		err = httpConnect(conn, proxyURL, addr)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+func defaultProxyPort(scheme string) string {
+	switch scheme {
+	case "https":
+		return "443"
+	case "http":
+		return "80"
+	default:
+		return "1080"
+	}
+}
+
+func isSOCKSProxy(proxyURL *url.URL) bool {
+	return proxyURL.Scheme == "socks5" || proxyURL.Scheme == "socks5h"
+}
+
+// ErrProxyAuth is returned when the proxy rejects the supplied credentials
+var ErrProxyAuth = errors.New("proxy: authentication failed")
+
+// forwardProxy returns the HTTP proxy that a plain http request to rawURL is
+// sent to in absolute-form, or nil when the request goes direct or through a
+// tunnel. Errors from the ProxyFunc are left for the dialer to report.
+func (c *Client) forwardProxy(rawURL string) *url.URL {
+	if c.proxy == nil {
+		return nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "http" {
+		return nil
+	}
+	proxyURL, err := c.proxy("http", requestHostPort(rawURL))
+	if err != nil || proxyURL == nil || isSOCKSProxy(proxyURL) {
+		return nil
+	}
+	return proxyURL
+}
+
+// forwardStandard rewrites a net/http request for an HTTP proxy: the request
+// line carries the absolute URL and the proxy's credentials go in a header
+func forwardStandard(req *http.Request, proxyURL *url.URL) {
+	req.URL.Opaque = "//" + req.URL.Host + req.URL.EscapedPath()
+	if auth := proxyAuthorization(proxyURL); auth != "" {
+		req.Header.Set("Proxy-Authorization", auth)
+	}
+}
+
+// forwardFastHTTP is forwardStandard for fasthttp, which only writes the
+// origin-form path. The client disables path normalization, so prefixing
+// the original path with the scheme and host puts the absolute URL on the
+// request line.
+func forwardFastHTTP(req *fasthttp.Request, proxyURL *url.URL) {
+	uri := req.URI()
+	path := uri.PathOriginal()
+	if len(path) == 0 {
+		path = []byte("/")
+	}
+	uri.SetPathBytes(append([]byte("http://"+string(uri.Host())), path...))
+	if auth := proxyAuthorization(proxyURL); auth != "" {
+		req.Header.Set("Proxy-Authorization", auth)
+	}
+}
+
+// proxyAuthorization returns the Proxy-Authorization value for the proxy's
+// credentials, or "" when it has none
+func proxyAuthorization(proxyURL *url.URL) string {
+	user := proxyURL.User
+	if user == nil {
+		return ""
+	}
+	password, _ := user.Password()
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(user.Username()+":"+password))
+}
+
+func httpConnect(conn net.Conn, proxyURL *url.URL, addr string) error {
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if auth := proxyAuthorization(proxyURL); auth != "" {
+		req.Header.Set("Proxy-Authorization", auth)
+	}
+	if err := req.Write(conn); err != nil {
+		return fmt.Errorf("proxy: error sending CONNECT: %w", err)
+	}
+
+	// The target speaks only after we do, so nothing past the response
+	// headers can be left in the buffered reader
+	resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+	if err != nil {
+		return fmt.Errorf("proxy: error reading CONNECT response: %w", err)
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusProxyAuthRequired:
+		return ErrProxyAuth
+	case resp.StatusCode != http.StatusOK:
+		return fmt.Errorf("proxy: CONNECT %s returned %s", addr, resp.Status)
+	}
+	return nil
+}
+
+const (
+	socks5Version      = 0x05
+	socks5NoAuth       = 0x00
+	socks5UserPassword = 0x02
+	socks5NoAcceptable = 0xff
+	socks5CmdConnect   = 0x01
+	socks5AddrIPv4     = 0x01
+	socks5AddrDomain   = 0x03
+	socks5AddrIPv6     = 0x04
+)
+
+var socks5Replies = map[byte]string{
+	0x01: "general failure",
+	0x02: "connection not allowed by ruleset",
+	0x03: "network unreachable",
+	0x04: "host unreachable",
+	0x05: "connection refused",
+	0x06: "TTL expired",
+	0x07: "command not supported",
+	0x08: "address type not supported",
+}
+
+// socks5Connect performs the RFC 1928 handshake. Host names are always sent
+// to the proxy for resolution, so socks5 and socks5h behave alike.
+func socks5Connect(conn net.Conn, proxyURL *url.URL, addr string) error {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("proxy: invalid address %q: %w", addr, err)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return fmt.Errorf("proxy: invalid port %q", portStr)
+	}
+
+	methods := []byte{socks5NoAuth}
+	if proxyURL.User != nil {
+		methods = []byte{socks5UserPassword}
+	}
+	greeting := append([]byte{socks5Version, byte(len(methods))}, methods...)
+	if _, err := conn.Write(greeting); err != nil {
+		return fmt.Errorf("proxy: error writing socks5 greeting: %w", err)
+	}
+
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return fmt.Errorf("proxy: error reading socks5 greeting: %w", err)
+	}
+	if reply[0] != socks5Version {
+		return fmt.Errorf("proxy: unexpected socks version %d", reply[0])
+	}
+
+	switch reply[1] {
+	case socks5NoAuth:
+	case socks5UserPassword:
+		if err := socks5Authenticate(conn, proxyURL.User); err != nil {
+			return err
+		}
+	case socks5NoAcceptable:
+		return ErrProxyAuth
+	default:
+		return fmt.Errorf("proxy: unsupported socks5 auth method %d", reply[1])
+	}
+
+	request := []byte{socks5Version, socks5CmdConnect, 0x00}
+	if ip := net.ParseIP(host); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			request = append(append(request, socks5AddrIPv4), ip4...)
+		} else {
+			request = append(append(request, socks5AddrIPv6), ip.To16()...)
+		}
+	} else {
+		if len(host) > 255 {
+			return fmt.Errorf("proxy: host name too long: %s", host)
+		}
+		request = append(append(request, socks5AddrDomain, byte(len(host))), host...)
+	}
+	request = binary.BigEndian.AppendUint16(request, uint16(port))
+	if _, err := conn.Write(request); err != nil {
+		return fmt.Errorf("proxy: error writing socks5 request: %w", err)
+	}
+
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return fmt.Errorf("proxy: error reading socks5 reply: %w", err)
+	}
+	if header[1] != 0x00 {
+		reason, ok := socks5Replies[header[1]]
+		if !ok {
+			reason = "unknown error " + strconv.Itoa(int(header[1]))
+		}
+		return fmt.Errorf("proxy: socks5 connect to %s failed: %s", addr, reason)
+	}
+
+	// Discard the bound address, which clients have no use for
+	var skip int
+	switch header[3] {
+	case socks5AddrIPv4:
+		skip = net.IPv4len
+	case socks5AddrIPv6:
+		skip = net.IPv6len
+	case socks5AddrDomain:
+		length := make([]byte, 1)
+		if _, err := io.ReadFull(conn, length); err != nil {
+			return fmt.Errorf("proxy: error reading socks5 reply: %w", err)
+		}
+		skip = int(length[0])
+	default:
+		return fmt.Errorf("proxy: unexpected socks5 address type %d", header[3])
+	}
+	if _, err := io.ReadFull(conn, make([]byte, skip+2)); err != nil {
+		return fmt.Errorf("proxy: error reading socks5 reply: %w", err)
+	}
+	return nil
+}
+
+func socks5Authenticate(conn net.Conn, user *url.Userinfo) error {
+	if user == nil {
+		return ErrProxyAuth
+	}
+	username := user.Username()
+	password, _ := user.Password()
+	if len(username) > 255 || len(password) > 255 {
+		return errors.New("proxy: socks5 credentials too long")
+	}
+
+	request := []byte{0x01, byte(len(username))}
+	request = append(request, username...)
+	request = append(request, byte(len(password)))
+	request = append(request, password...)
+	if _, err := conn.Write(request); err != nil {
+		return fmt.Errorf("proxy: error writing socks5 credentials: %w", err)
+	}
+
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return fmt.Errorf("proxy: error reading socks5 auth reply: %w", err)
+	}
+	if reply[1] != 0x00 {
+		return ErrProxyAuth
+	}
+	return nil
+}
@@ -0,0 +1,290 @@
+package httpclient
+
+import (
+	"bufio"
+	"context"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+)
+
+// testProxy is a minimal proxy stand-in that counts the tunnels it opens and
+// the requests it forwards
+type testProxy struct {
+	listener net.Listener
+	tunnels  atomic.Int64
+	forwards atomic.Int64
+}
+
+func (p *testProxy) Close() { p.listener.Close() }
+
+// serve accepts connections and pipes each one to the target its handshake
+// returns. A handshake that read a request to forward rather than a tunnel
+// request returns it to be sent upstream first.
+func (p *testProxy) serve(t *testing.T, handshake func(conn net.Conn) (target string, forward *http.Request, ok bool)) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.listener = listener
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				target, forward, ok := handshake(conn)
+				if !ok {
+					return
+				}
+				upstream, err := net.Dial("tcp", target)
+				if err != nil {
+					return
+				}
+				defer upstream.Close()
+				if forward != nil {
+					p.forwards.Add(1)
+					if forward.Write(upstream) != nil {
+						return
+					}
+				} else {
+					p.tunnels.Add(1)
+				}
+				go io.Copy(upstream, conn)
+				io.Copy(conn, upstream)
+			}()
+		}
+	}()
+}
+
+// setupConnectProxy starts an HTTP proxy requiring basic auth. It tunnels
+// CONNECT requests and forwards absolute-form ones; later requests on a
+// forwarded connection are passed through as they are.
+func setupConnectProxy(t *testing.T, user, password string) *testProxy {
+	want := "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+password))
+
+	p := &testProxy{}
+	p.serve(t, func(conn net.Conn) (string, *http.Request, bool) {
+		req, err := http.ReadRequest(bufio.NewReader(conn))
+		if err != nil {
+			return "", nil, false
+		}
+		if req.Header.Get("Proxy-Authorization") != want {
+			conn.Write([]byte("HTTP/1.1 407 Proxy Authentication Required\r\nContent-Length: 0\r\n\r\n"))
+			return "", nil, false
+		}
+		if req.Method != http.MethodConnect {
+			if !req.URL.IsAbs() {
+				return "", nil, false
+			}
+			return req.URL.Host, req, true
+		}
+		conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+		return req.Host, nil, true
+	})
+	return p
+}
+
+// setupSOCKS5Proxy starts a SOCKS5 proxy requiring username/password auth
+func setupSOCKS5Proxy(t *testing.T, user, password string) *testProxy {
+	p := &testProxy{}
+	p.serve(t, func(conn net.Conn) (string, *http.Request, bool) {
+		header := make([]byte, 2)
+		if _, err := io.ReadFull(conn, header); err != nil {
+			return "", nil, false
+		}
+		io.ReadFull(conn, make([]byte, header[1]))
+		conn.Write([]byte{0x05, 0x02})
+
+		// RFC 1929 username/password negotiation
+		io.ReadFull(conn, header)
+		gotUser := make([]byte, header[1])
+		io.ReadFull(conn, gotUser)
+		io.ReadFull(conn, header[:1])
+		gotPassword := make([]byte, header[0])
+		io.ReadFull(conn, gotPassword)
+		if string(gotUser) != user || string(gotPassword) != password {
+			conn.Write([]byte{0x01, 0x01})
+			return "", nil, false
+		}
+		conn.Write([]byte{0x01, 0x00})
+
+		request := make([]byte, 4)
+		if _, err := io.ReadFull(conn, request); err != nil {
+			return "", nil, false
+		}
+		var host string
+		switch request[3] {
+		case 0x01:
+			ip := make([]byte, 4)
+			io.ReadFull(conn, ip)
+			host = net.IP(ip).String()
+		case 0x03:
+			io.ReadFull(conn, header[:1])
+			name := make([]byte, header[0])
+			io.ReadFull(conn, name)
+			host = string(name)
+		default:
+			return "", nil, false
+		}
+		port := make([]byte, 2)
+		io.ReadFull(conn, port)
+
+		conn.Write([]byte{0x05, 0x00, 0x00, 0x01, 0, 0, 0, 0, 0, 0})
+		return net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port)))), nil, true
+	})
+	return p
+}
+
+// setupProxyTargets starts a plain and a TLS test server and returns the
+// roots that trust the TLS one
+func setupProxyTargets() (server, tlsServer *httptest.Server, roots *x509.CertPool) {
+	server = setupTestServer()
+	tlsServer = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	roots = x509.NewCertPool()
+	roots.AddCert(tlsServer.Certificate())
+	return server, tlsServer, roots
+}
+
+func TestClientThroughProxy(t *testing.T) {
+	server, tlsServer, roots := setupProxyTargets()
+	defer server.Close()
+	defer tlsServer.Close()
+
+	connectProxy := setupConnectProxy(t, "alice", "s3cret")
+	defer connectProxy.Close()
+	socksProxy := setupSOCKS5Proxy(t, "bob", "hunter2")
+	defer socksProxy.Close()
+
+	tests := []struct {
+		name    string
+		url     string
+		target  string
+		proxy   *testProxy
+		forward bool
+	}{
+		// An HTTP proxy gets plain http requests in absolute-form and is
+		// only asked to tunnel https
+		{"connect/http", "http://alice:s3cret@" + connectProxy.listener.Addr().String(), server.URL, connectProxy, true},
+		{"connect/https", "http://alice:s3cret@" + connectProxy.listener.Addr().String(), tlsServer.URL, connectProxy, false},
+		{"socks5/http", "socks5://bob:hunter2@" + socksProxy.listener.Addr().String(), server.URL, socksProxy, false},
+		{"socks5/https", "socks5://bob:hunter2@" + socksProxy.listener.Addr().String(), tlsServer.URL, socksProxy, false},
+	}
+
+	for _, tt := range tests {
+		for _, backend := range []Backend{BackendStandard, BackendFastHTTP} {
+			t.Run(tt.name+"/"+string(backend), func(t *testing.T) {
+				client, err := NewClient(backend, WithProxy(tt.url), WithTLS(TLSOptions{RootCAs: roots}))
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				tunnels, forwards := tt.proxy.tunnels.Load(), tt.proxy.forwards.Load()
+				resp := client.Get(context.Background(), tt.target+"/path?q=1", nil)
+				if resp.Error != nil {
+					t.Fatal(resp.Error)
+				}
+				if resp.StatusCode != http.StatusOK {
+					t.Errorf("status = %d, want 200", resp.StatusCode)
+				}
+
+				wantTunnels, wantForwards := tunnels+1, forwards
+				if tt.forward {
+					wantTunnels, wantForwards = tunnels, forwards+1
+				}
+				if got := tt.proxy.tunnels.Load(); got != wantTunnels {
+					t.Errorf("proxy opened %d tunnels, want %d", got-tunnels, wantTunnels-tunnels)
+				}
+				if got := tt.proxy.forwards.Load(); got != wantForwards {
+					t.Errorf("proxy forwarded %d requests, want %d", got-forwards, wantForwards-forwards)
+				}
+			})
+		}
+	}
+}
+
+func TestClientProxyAuthFailure(t *testing.T) {
+	server, tlsServer, roots := setupProxyTargets()
+	defer server.Close()
+	defer tlsServer.Close()
+
+	connectProxy := setupConnectProxy(t, "alice", "s3cret")
+	defer connectProxy.Close()
+	socksProxy := setupSOCKS5Proxy(t, "bob", "hunter2")
+	defer socksProxy.Close()
+
+	for _, proxyURL := range []string{
+		"http://alice:wrong@" + connectProxy.listener.Addr().String(),
+		"socks5://bob:wrong@" + socksProxy.listener.Addr().String(),
+	} {
+		for _, backend := range []Backend{BackendStandard, BackendFastHTTP} {
+			client, err := NewClient(backend, WithProxy(proxyURL), WithTLS(TLSOptions{RootCAs: roots}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, target := range []string{server.URL, tlsServer.URL} {
+				resp := client.Get(context.Background(), target, nil)
+				if !errors.Is(resp.Error, ErrProxyAuth) {
+					t.Errorf("%s to %s via %s: error = %v, want ErrProxyAuth", backend, target, proxyURL, resp.Error)
+				}
+			}
+		}
+	}
+}
+
+func TestProxyFromEnvironment(t *testing.T) {
+	t.Setenv("HTTP_PROXY", "proxy.internal:3128")
+	t.Setenv("HTTPS_PROXY", "socks5://socks.internal:1080")
+	t.Setenv("NO_PROXY", ".corp.example, 10.0.0.0/8, api.example.com:8443")
+
+	proxy, err := ProxyFromEnvironment()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		scheme string
+		addr   string
+		want   string
+	}{
+		{"http", "example.com:80", "http://proxy.internal:3128"},
+		{"https", "example.com:443", "socks5://socks.internal:1080"},
+		// The scheme picks the proxy, whatever the port
+		{"http", "example.com:443", "http://proxy.internal:3128"},
+		{"https", "example.com:8443", "socks5://socks.internal:1080"},
+		{"http", "build.corp.example:80", ""},
+		{"https", "corp.example:443", "socks5://socks.internal:1080"},
+		{"http", "10.1.2.3:80", ""},
+		{"https", "api.example.com:8443", ""},
+		{"https", "api.example.com:443", "socks5://socks.internal:1080"},
+		{"http", "127.0.0.1:8080", ""},
+		{"http", "localhost:80", ""},
+	}
+	for _, tt := range tests {
+		got, err := proxy(tt.scheme, tt.addr)
+		if err != nil {
+			t.Fatalf("proxy(%q, %q): %v", tt.scheme, tt.addr, err)
+		}
+		gotURL := ""
+		if got != nil {
+			gotURL = got.String()
+		}
+		if gotURL != tt.want {
+			t.Errorf("proxy(%q, %q) = %q, want %q", tt.scheme, tt.addr, gotURL, tt.want)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package httpclient
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// RedirectPolicy controls how a Client follows redirects. Both backends
+// follow them the same way; the zero value follows none.
+type RedirectPolicy struct {
+	// MaxRedirects is the number of redirects followed before giving up with
+	// ErrTooManyRedirects. Zero returns redirect responses as they are.
+	MaxRedirects int
+	// SameHost refuses redirects to a different host with
+	// ErrRedirectNotAllowed
+	SameHost bool
+	// ForwardAuth keeps Authorization and Cookie headers on redirects to a
+	// different host; by default they are removed
+	ForwardAuth bool
+	// PreserveMethod resends the original method and body on 301 and 302
+	// instead of switching to GET as net/http and browsers do. 303 always
+	// switches to GET, and 307 and 308 always preserve the method.
+	PreserveMethod bool
+}
+
+// DefaultRedirectPolicy matches the net/http default of following up to 10
+// redirects
+var DefaultRedirectPolicy = RedirectPolicy{MaxRedirects: 10}
+
+// Redirect is one hop of a followed redirect chain
+type Redirect struct {
+	// URL is the request URL that answered with a redirect
+	URL        string
+	StatusCode int
+	// Location is the absolute URL it redirected to
+	Location string
+}
+
+var (
+	// ErrTooManyRedirects is returned when a response is still a redirect
+	// after RedirectPolicy.MaxRedirects hops
+	ErrTooManyRedirects = errors.New("too many redirects")
+	// ErrRedirectNotAllowed is returned for a redirect the policy refuses
+	// to follow
+	ErrRedirectNotAllowed = errors.New("redirect not allowed")
+)
+
+// WithRedirectPolicy replaces DefaultRedirectPolicy
+func WithRedirectPolicy(policy RedirectPolicy) Option {
+	return func(cfg *clientConfig) error {
+		if policy.MaxRedirects < 0 {
+			return fmt.Errorf("max redirects must not be negative, got %d", policy.MaxRedirects)
+		}
+		cfg.redirects = policy
+		return nil
+	}
+}
+
+// redirectHeaders are removed when a redirect leaves the original host
+var redirectHeaders = []string{"Authorization", "Www-Authenticate", "Cookie", "Cookie2"}
+
+// next returns the request that follows a redirect response to req, or nil
+// when the response is final. hops is the number of redirects already
+// followed.
+func (p RedirectPolicy) next(req *Request, statusCode int, headers map[string]string, hops int) (*Request, error) {
+	switch statusCode {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+	default:
+		return nil, nil
+	}
+	location, ok := lookupHeader(headers, "Location")
+	if !ok || p.MaxRedirects == 0 {
+		return nil, nil
+	}
+	if hops >= p.MaxRedirects {
+		return nil, fmt.Errorf("%w: stopped after %d", ErrTooManyRedirects, hops)
+	}
+
+	next, err := withResolvedURL(req, location)
+	if err != nil {
+		return nil, err
+	}
+	from, _ := url.Parse(req.URL)
+	to, err := url.Parse(next.URL)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing redirect location: %w", err)
+	}
+	if to.Scheme != "http" && to.Scheme != "https" {
+		return nil, fmt.Errorf("%w: unsupported scheme in %s", ErrRedirectNotAllowed, next.URL)
+	}
+
+	crossHost := !strings.EqualFold(from.Hostname(), to.Hostname())
+	if crossHost && p.SameHost {
+		return nil, fmt.Errorf("%w: %s is on another host", ErrRedirectNotAllowed, next.URL)
+	}
+	if crossHost && !p.ForwardAuth {
+		for _, name := range redirectHeaders {
+			deleteHeader(next.Headers, name)
+		}
+	}
+
+	rewrite := statusCode == http.StatusSeeOther ||
+		!p.PreserveMethod && (statusCode == http.StatusMovedPermanently || statusCode == http.StatusFound)
+	if rewrite && req.Method != http.MethodGet && req.Method != http.MethodHead {
+		next.Method = http.MethodGet
+		next.Body = nil
+		deleteHeader(next.Headers, "Content-Type")
+		deleteHeader(next.Headers, "Content-Length")
+	}
+	return next, nil
+}
+
+// followedRedirects rebuilds the redirects net/http followed to get resp
+func followedRedirects(resp *http.Response) []Redirect {
+	var chain []Redirect
+	for req := resp.Request; req != nil && req.Response != nil; req = req.Response.Request {
+		chain = append(chain, Redirect{
+			URL:        req.Response.Request.URL.String(),
+			StatusCode: req.Response.StatusCode,
+			Location:   req.URL.String(),
+		})
+	}
+	slices.Reverse(chain)
+	return chain
+}
+
+// checkRedirect applies the policy to net/http's own redirect handling, for
+// the package-level functions that do not go through a Client. net/http
+// already rewrites 301, 302 and 303 to GET, so PreserveMethod has no effect
+// here.
+func (p RedirectPolicy) checkRedirect(req *http.Request, via []*http.Request) error {
+	if p.MaxRedirects == 0 {
+		return http.ErrUseLastResponse
+	}
+	if hops := len(via) - 1; hops >= p.MaxRedirects {
+		return fmt.Errorf("%w: stopped after %d", ErrTooManyRedirects, hops)
+	}
+	if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+		return fmt.Errorf("%w: unsupported scheme in %s", ErrRedirectNotAllowed, req.URL)
+	}
+
+	// net/http copies headers from the first request on every hop, so any
+	// host change along the chain counts, as it does for a Client
+	crossHost := false
+	for _, prev := range via {
+		if !strings.EqualFold(prev.URL.Hostname(), req.URL.Hostname()) {
+			crossHost = true
+		}
+	}
+	if crossHost && p.SameHost {
+		return fmt.Errorf("%w: %s is on another host", ErrRedirectNotAllowed, req.URL)
+	}
+	if crossHost && !p.ForwardAuth {
+		for _, name := range redirectHeaders {
+			req.Header.Del(name)
+		}
+	}
+	return nil
+}
+
+// stopRedirects makes net/http return redirect responses instead of
+// following them
+func stopRedirects(*http.Request, []*http.Request) error {
+	return http.ErrUseLastResponse
+}
@@ -0,0 +1,249 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// setupRedirectTestServer redirects /301, /302, /303, /307 and /308 to /echo
+// with the matching status, chains /chain through two hops, loops /loop
+// forever and sends /away to target. /echo answers with the method, body and
+// Authorization header it received.
+func setupRedirectTestServer(target string) *httptest.Server {
+	mux := http.NewServeMux()
+	for _, code := range []int{301, 302, 303, 307, 308} {
+		mux.HandleFunc(fmt.Sprintf("/%d", code), func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, "/echo", code)
+		})
+	}
+	mux.HandleFunc("/chain", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/301", http.StatusFound)
+	})
+	mux.HandleFunc("/loop", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/loop", http.StatusFound)
+	})
+	mux.HandleFunc("/away", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, target+"/echo", http.StatusFound)
+	})
+	mux.HandleFunc("/echo", echoRequest)
+	return httptest.NewServer(mux)
+}
+
+func echoRequest(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	fmt.Fprintf(w, "%s %q auth=%q", r.Method, body, r.Header.Get("Authorization"))
+}
+
+func TestRedirectChain(t *testing.T) {
+	server := setupRedirectTestServer("")
+	defer server.Close()
+
+	for _, backend := range []Backend{BackendStandard, BackendFastHTTP} {
+		t.Run(string(backend), func(t *testing.T) {
+			client, err := NewClient(backend)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			resp := client.Get(context.Background(), server.URL+"/chain", nil)
+			if resp.Error != nil {
+				t.Fatal(resp.Error)
+			}
+			if resp.StatusCode != http.StatusOK || string(resp.Body) != `GET "" auth=""` {
+				t.Errorf("response = %d %s", resp.StatusCode, resp.Body)
+			}
+
+			want := []Redirect{
+				{URL: server.URL + "/chain", StatusCode: http.StatusFound, Location: server.URL + "/301"},
+				{URL: server.URL + "/301", StatusCode: http.StatusMovedPermanently, Location: server.URL + "/echo"},
+			}
+			if fmt.Sprint(resp.Redirects) != fmt.Sprint(want) {
+				t.Errorf("Redirects = %+v, want %+v", resp.Redirects, want)
+			}
+		})
+	}
+}
+
+func TestRedirectMethodRewriting(t *testing.T) {
+	server := setupRedirectTestServer("")
+	defer server.Close()
+
+	tests := []struct {
+		path           string
+		preserveMethod bool
+		want           string
+	}{
+		{"/301", false, `GET "" auth=""`},
+		{"/302", false, `GET "" auth=""`},
+		{"/303", false, `GET "" auth=""`},
+		{"/307", false, `POST "payload" auth=""`},
+		{"/308", false, `POST "payload" auth=""`},
+		{"/302", true, `POST "payload" auth=""`},
+		{"/303", true, `GET "" auth=""`},
+	}
+
+	for _, backend := range []Backend{BackendStandard, BackendFastHTTP} {
+		for _, tt := range tests {
+			t.Run(fmt.Sprintf("%s%s/preserve=%v", backend, tt.path, tt.preserveMethod), func(t *testing.T) {
+				client, err := NewClient(backend, WithRedirectPolicy(RedirectPolicy{MaxRedirects: 10, PreserveMethod: tt.preserveMethod}))
+				if err != nil {
+					t.Fatal(err)
+				}
+				resp := client.Do(context.Background(), &Request{Method: http.MethodPost, URL: server.URL + tt.path, Body: []byte("payload")})
+				if resp.Error != nil {
+					t.Fatal(resp.Error)
+				}
+				if string(resp.Body) != tt.want {
+					t.Errorf("got %s, want %s", resp.Body, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func TestRedirectLimits(t *testing.T) {
+	server := setupRedirectTestServer("")
+	defer server.Close()
+
+	for _, backend := range []Backend{BackendStandard, BackendFastHTTP} {
+		t.Run(string(backend), func(t *testing.T) {
+			client, err := NewClient(backend, WithRedirectPolicy(RedirectPolicy{MaxRedirects: 3}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp := client.Get(context.Background(), server.URL+"/loop", nil)
+			if !errors.Is(resp.Error, ErrTooManyRedirects) || len(resp.Redirects) != 3 {
+				t.Errorf("err = %v after %d redirects, want ErrTooManyRedirects after 3", resp.Error, len(resp.Redirects))
+			}
+
+			client, err = NewClient(backend, WithRedirectPolicy(RedirectPolicy{}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp = client.Get(context.Background(), server.URL+"/302", nil)
+			if resp.Error != nil || resp.StatusCode != http.StatusFound || len(resp.Redirects) != 0 {
+				t.Errorf("with redirects disabled got %d, %v, %d redirects; want the 302 itself", resp.StatusCode, resp.Error, len(resp.Redirects))
+			}
+		})
+	}
+}
+
+func TestRedirectAcrossHosts(t *testing.T) {
+	other := httptest.NewServer(http.HandlerFunc(echoRequest))
+	defer other.Close()
+	// The same listener under another host name
+	otherHost := strings.Replace(other.URL, "127.0.0.1", "localhost", 1)
+
+	server := setupRedirectTestServer(otherHost)
+	defer server.Close()
+
+	headers := map[string]string{"Authorization": "Bearer secret"}
+	tests := []struct {
+		name    string
+		policy  RedirectPolicy
+		want    string
+		wantErr error
+	}{
+		{"strip", RedirectPolicy{MaxRedirects: 10}, `GET "" auth=""`, nil},
+		{"forward", RedirectPolicy{MaxRedirects: 10, ForwardAuth: true}, `GET "" auth="Bearer secret"`, nil},
+		{"same host", RedirectPolicy{MaxRedirects: 10, SameHost: true}, "", ErrRedirectNotAllowed},
+	}
+
+	for _, backend := range []Backend{BackendStandard, BackendFastHTTP} {
+		for _, tt := range tests {
+			t.Run(string(backend)+"/"+tt.name, func(t *testing.T) {
+				client, err := NewClient(backend, WithRedirectPolicy(tt.policy))
+				if err != nil {
+					t.Fatal(err)
+				}
+				resp := client.Get(context.Background(), server.URL+"/away", headers)
+				if tt.wantErr != nil {
+					if !errors.Is(resp.Error, tt.wantErr) {
+						t.Errorf("err = %v, want %v", resp.Error, tt.wantErr)
+					}
+					return
+				}
+				if resp.Error != nil {
+					t.Fatal(resp.Error)
+				}
+				if string(resp.Body) != tt.want {
+					t.Errorf("got %s, want %s", resp.Body, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func TestPackageFunctionsFollowRedirects(t *testing.T) {
+	other := httptest.NewServer(http.HandlerFunc(echoRequest))
+	defer other.Close()
+	server := setupRedirectTestServer(strings.Replace(other.URL, "127.0.0.1", "localhost", 1))
+	defer server.Close()
+
+	ctx := context.Background()
+	headers := map[string]string{"Authorization": "Bearer secret"}
+	senders := map[string]struct {
+		get  func(url string) HTTPResponse
+		post func(url string) HTTPResponse
+	}{
+		"nethttp": {
+			get:  func(url string) HTTPResponse { return StandardGet(ctx, url, headers, benchTimeout) },
+			post: func(url string) HTTPResponse { return StandardPost(ctx, url, headers, "payload", benchTimeout) },
+		},
+		"fasthttp": {
+			get:  func(url string) HTTPResponse { return FastHTTPGet(url, headers, benchTimeout) },
+			post: func(url string) HTTPResponse { return FastHTTPPost(url, headers, "payload", benchTimeout) },
+		},
+	}
+
+	// Both follow DefaultRedirectPolicy the way a Client does
+	for name, send := range senders {
+		t.Run(name, func(t *testing.T) {
+			resp := send.get(server.URL + "/chain")
+			if resp.Error != nil || string(resp.Body) != `GET "" auth="Bearer secret"` {
+				t.Errorf("chain: got %q, %v", resp.Body, resp.Error)
+			}
+			want := []Redirect{
+				{URL: server.URL + "/chain", StatusCode: http.StatusFound, Location: server.URL + "/301"},
+				{URL: server.URL + "/301", StatusCode: http.StatusMovedPermanently, Location: server.URL + "/echo"},
+			}
+			if fmt.Sprint(resp.Redirects) != fmt.Sprint(want) {
+				t.Errorf("Redirects = %+v, want %+v", resp.Redirects, want)
+			}
+			// The body belongs to the caller and survives the next request
+			send.post(server.URL + "/307")
+			if string(resp.Body) != `GET "" auth="Bearer secret"` {
+				t.Errorf("body changed after another request: %q", resp.Body)
+			}
+
+			if resp := send.post(server.URL + "/302"); resp.Error != nil || string(resp.Body) != `GET "" auth="Bearer secret"` {
+				t.Errorf("302 after POST: got %q, %v", resp.Body, resp.Error)
+			}
+			if resp := send.post(server.URL + "/307"); resp.Error != nil || string(resp.Body) != `POST "\"payload\"" auth="Bearer secret"` {
+				t.Errorf("307 after POST: got %q, %v", resp.Body, resp.Error)
+			}
+			if resp := send.get(server.URL + "/away"); resp.Error != nil || string(resp.Body) != `GET "" auth=""` {
+				t.Errorf("cross-host: got %q, %v", resp.Body, resp.Error)
+			}
+			if resp := send.get(server.URL + "/loop"); !errors.Is(resp.Error, ErrTooManyRedirects) {
+				t.Errorf("loop: err = %v, want ErrTooManyRedirects", resp.Error)
+			}
+		})
+	}
+
+	// Changes to DefaultRedirectPolicy apply to both
+	saved := DefaultRedirectPolicy
+	defer func() { DefaultRedirectPolicy = saved }()
+	DefaultRedirectPolicy = RedirectPolicy{}
+	for name, send := range senders {
+		if resp := send.get(server.URL + "/chain"); resp.Error != nil || resp.StatusCode != http.StatusFound || len(resp.Redirects) != 0 {
+			t.Errorf("%s with redirects disabled: got %d, %v, %d redirects; want the 302 itself", name, resp.StatusCode, resp.Error, len(resp.Redirects))
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package httpclient
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Resolver looks up the addresses of a host. *net.Resolver implements it.
+type Resolver interface {
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// WithResolver resolves host names with resolver on either backend. A
+// connection is attempted to each resolved address in turn, starting from
+// the next one in round-robin order for every new connection. Hosts reached
+// through a proxy are resolved by the proxy; only the proxy's own host goes
+// through resolver.
+func WithResolver(resolver Resolver) Option {
+	return func(cfg *clientConfig) error {
+		if resolver == nil {
+			return fmt.Errorf("resolver must not be nil")
+		}
+		cfg.resolver = resolver
+		return nil
+	}
+}
+
+// resolvingDialer dials the addresses a Resolver returns for a host,
+// rotating the first address tried per host
+type resolvingDialer struct {
+	resolver Resolver
+	dial     dialFunc
+	next     sync.Map // map[string]*atomic.Uint32
+}
+
+func (d *resolvingDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if net.ParseIP(host) != nil {
+		return d.dial(ctx, network, addr)
+	}
+
+	addrs, err := d.resolver.LookupHost(ctx, host)
+	if err != nil {
+		return nil, &net.OpError{Op: "dial", Net: network, Err: err}
+	}
+	if len(addrs) == 0 {
+		return nil, &net.OpError{Op: "dial", Net: network, Err: &net.DNSError{Err: "no addresses", Name: host, IsNotFound: true}}
+	}
+
+	counter, _ := d.next.LoadOrStore(host, new(atomic.Uint32))
+	start := int(counter.(*atomic.Uint32).Add(1)-1) % len(addrs)
+
+	var firstErr error
+	for i := range addrs {
+		ip := addrs[(start+i)%len(addrs)]
+		conn, err := d.dial(ctx, network, net.JoinHostPort(ip, port))
+		if err == nil {
+			return conn, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, firstErr
+}
+
+// CachingResolver remembers the addresses another Resolver returns for a
+// fixed TTL, since the system resolver does not report record TTLs.
+// Concurrent lookups of the same host share one upstream query, and failed
+// lookups are not cached.
+type CachingResolver struct {
+	upstream Resolver
+	ttl      time.Duration
+	now      func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*dnsEntry
+}
+
+// dnsEntry is a cached or in-flight lookup; ready is closed once addrs and
+// err are set
+type dnsEntry struct {
+	ready   chan struct{}
+	addrs   []string
+	err     error
+	expires time.Time
+}
+
+// NewCachingResolver caches the answers of upstream, or of the system
+// resolver when upstream is nil, for ttl
+func NewCachingResolver(upstream Resolver, ttl time.Duration) *CachingResolver {
+	if upstream == nil {
+		upstream = net.DefaultResolver
+	}
+	return &CachingResolver{
+		upstream: upstream,
+		ttl:      ttl,
+		now:      time.Now,
+		entries:  make(map[string]*dnsEntry),
+	}
+}
+
+// LookupHost implements Resolver
+func (r *CachingResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	key := strings.ToLower(host)
+
+	r.mu.Lock()
+	entry, ok := r.entries[key]
+	if ok {
+		select {
+		case <-entry.ready:
+			if r.now().After(entry.expires) {
+				ok = false
+			}
+		default:
+		}
+	}
+	if !ok {
+		entry = &dnsEntry{ready: make(chan struct{})}
+		r.entries[key] = entry
+		// The lookup is shared, so it must not fail because the caller
+		// that started it went away
+		go r.resolve(context.WithoutCancel(ctx), key, host, entry)
+	}
+	r.mu.Unlock()
+
+	select {
+	case <-entry.ready:
+		return slices.Clone(entry.addrs), entry.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (r *CachingResolver) resolve(ctx context.Context, key, host string, entry *dnsEntry) {
+	addrs, err := r.upstream.LookupHost(ctx, host)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entry.addrs, entry.err = addrs, err
+	entry.expires = r.now().Add(r.ttl)
+	if err != nil && r.entries[key] == entry {
+		delete(r.entries, key)
+	}
+	close(entry.ready)
+}
+
+// Flush drops every cached answer
+func (r *CachingResolver) Flush() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	clear(r.entries)
+}
+
+// StaticResolver answers from a fixed host table, like /etc/hosts, and
+// passes other hosts to Fallback
+type StaticResolver struct {
+	// Hosts maps host names to their addresses
+	Hosts map[string][]string
+	// Fallback resolves hosts missing from Hosts; nil fails them
+	Fallback Resolver
+}
+
+// LookupHost implements Resolver
+func (r StaticResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	for name, addrs := range r.Hosts {
+		if strings.EqualFold(name, host) {
+			return slices.Clone(addrs), nil
+		}
+	}
+	if r.Fallback != nil {
+		return r.Fallback.LookupHost(ctx, host)
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+}
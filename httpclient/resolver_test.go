@@ -0,0 +1,152 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingResolver answers every host with the same addresses, failing
+// while err is set, and counts the lookups it serves
+type countingResolver struct {
+	addrs   []string
+	err     error
+	release chan struct{}
+	calls   atomic.Int64
+}
+
+func (r *countingResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	r.calls.Add(1)
+	if r.release != nil {
+		<-r.release
+	}
+	return r.addrs, r.err
+}
+
+func TestStaticResolver(t *testing.T) {
+	server := setupTestServer()
+	defer server.Close()
+
+	_, port, _ := net.SplitHostPort(strings.TrimPrefix(server.URL, "http://"))
+	resolver := StaticResolver{Hosts: map[string][]string{"api.internal.test": {"127.0.0.1"}}}
+
+	for _, backend := range []Backend{BackendStandard, BackendFastHTTP} {
+		t.Run(string(backend), func(t *testing.T) {
+			client, err := NewClient(backend, WithResolver(resolver))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			resp := client.Get(context.Background(), "http://api.internal.test:"+port+"/", nil)
+			if resp.Error != nil || resp.StatusCode != http.StatusOK {
+				t.Fatalf("got %d, %v", resp.StatusCode, resp.Error)
+			}
+
+			resp = client.Get(context.Background(), "http://unknown.internal.test:"+port+"/", nil)
+			var dnsErr *net.DNSError
+			if !errors.As(resp.Error, &dnsErr) || !dnsErr.IsNotFound {
+				t.Errorf("unknown host err = %v, want a not found DNSError", resp.Error)
+			}
+		})
+	}
+}
+
+func TestResolvingDialerRoundRobin(t *testing.T) {
+	var mu sync.Mutex
+	var dialed []string
+	down := "10.0.0.2:443"
+	d := &resolvingDialer{
+		resolver: StaticResolver{Hosts: map[string][]string{"svc": {"10.0.0.1", "10.0.0.2", "10.0.0.3"}}},
+		dial: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			mu.Lock()
+			dialed = append(dialed, addr)
+			mu.Unlock()
+			if addr == down {
+				return nil, errors.New("connection refused")
+			}
+			client, server := net.Pipe()
+			server.Close()
+			return client, nil
+		},
+	}
+
+	for range 3 {
+		conn, err := d.DialContext(context.Background(), "tcp", "svc:443")
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.Close()
+	}
+
+	// The second dial starts at the address that is down and moves on
+	want := []string{"10.0.0.1:443", "10.0.0.2:443", "10.0.0.3:443", "10.0.0.3:443"}
+	if strings.Join(dialed, " ") != strings.Join(want, " ") {
+		t.Errorf("dialed %v, want %v", dialed, want)
+	}
+}
+
+func TestCachingResolver(t *testing.T) {
+	upstream := &countingResolver{addrs: []string{"192.0.2.1"}}
+	resolver := NewCachingResolver(upstream, time.Minute)
+	now := time.Now()
+	resolver.now = func() time.Time { return now }
+
+	for range 3 {
+		addrs, err := resolver.LookupHost(context.Background(), "example.test")
+		if err != nil || len(addrs) != 1 || addrs[0] != "192.0.2.1" {
+			t.Fatalf("LookupHost = %v, %v", addrs, err)
+		}
+	}
+	if calls := upstream.calls.Load(); calls != 1 {
+		t.Errorf("upstream lookups within TTL = %d, want 1", calls)
+	}
+
+	now = now.Add(2 * time.Minute)
+	resolver.LookupHost(context.Background(), "EXAMPLE.test")
+	if calls := upstream.calls.Load(); calls != 2 {
+		t.Errorf("upstream lookups after TTL = %d, want 2", calls)
+	}
+}
+
+func TestCachingResolverSharesLookups(t *testing.T) {
+	upstream := &countingResolver{addrs: []string{"192.0.2.1"}, release: make(chan struct{})}
+	resolver := NewCachingResolver(upstream, time.Minute)
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := resolver.LookupHost(context.Background(), "example.test"); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(upstream.release)
+	wg.Wait()
+
+	if calls := upstream.calls.Load(); calls != 1 {
+		t.Errorf("upstream lookups = %d, want 1", calls)
+	}
+}
+
+func TestCachingResolverSkipsFailures(t *testing.T) {
+	upstream := &countingResolver{err: errors.New("servfail")}
+	resolver := NewCachingResolver(upstream, time.Minute)
+
+	for range 2 {
+		if _, err := resolver.LookupHost(context.Background(), "example.test"); err == nil {
+			t.Error("expected the upstream error")
+		}
+	}
+	if calls := upstream.calls.Load(); calls != 2 {
+		t.Errorf("upstream lookups = %d, want 2", calls)
+	}
+}
@@ -0,0 +1,284 @@
+package httpclient
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Event is one Server-Sent Event
+type Event struct {
+	// ID is the last event ID in effect when the event was dispatched
+	ID    string
+	Event string
+	Data  string
+	// Retry is the reconnection delay the server asked for, if it sent one
+	// with this event
+	Retry time.Duration
+}
+
+// SSEOptions configures EventSeq and StreamEvents
+type SSEOptions struct {
+	// LastEventID resumes a stream from a known event
+	LastEventID string
+	// RetryDelay is the reconnection delay until the server sets one;
+	// defaults to 3 seconds
+	RetryDelay time.Duration
+	// MaxRetryDelay caps the exponential backoff applied to consecutive
+	// failed reconnects; defaults to 30 seconds
+	MaxRetryDelay time.Duration
+	// MaxReconnects stops after this many consecutive failed reconnects;
+	// zero retries forever
+	MaxReconnects int
+}
+
+// ErrNotEventStream is returned when the server answers with something other
+// than a 200 text/event-stream response
+var ErrNotEventStream = errors.New("sse: response is not an event stream")
+
+// EventSeq subscribes to a text/event-stream and yields events as they
+// arrive. Dropped connections are re-established with Last-Event-ID after the
+// retry delay, backing off while reconnects keep failing. Iteration ends when
+// ctx is cancelled, the server answers 204 No Content, or an unrecoverable
+// error is yielded.
+func (c *Client) EventSeq(ctx context.Context, req *Request, opts SSEOptions) iter.Seq2[Event, error] {
+	return func(yield func(Event, error) bool) {
+		retryDelay := opts.RetryDelay
+		if retryDelay <= 0 {
+			retryDelay = 3 * time.Second
+		}
+		maxRetryDelay := opts.MaxRetryDelay
+		if maxRetryDelay <= 0 {
+			maxRetryDelay = 30 * time.Second
+		}
+		lastEventID := opts.LastEventID
+		failures := 0
+
+		for {
+			attempt := req.Clone()
+			attempt.Headers["Accept"] = "text/event-stream"
+			attempt.Headers["Cache-Control"] = "no-cache"
+			if lastEventID != "" {
+				attempt.Headers["Last-Event-ID"] = lastEventID
+			}
+
+			delivered, done, err := c.readEventStream(ctx, attempt, &lastEventID, &retryDelay, yield)
+			if done {
+				return
+			}
+			if ctx.Err() != nil {
+				yield(Event{}, ctx.Err())
+				return
+			}
+			if err != nil && !isRetryableStreamError(err) {
+				yield(Event{}, err)
+				return
+			}
+
+			if delivered {
+				failures = 0
+			} else {
+				failures++
+			}
+			if opts.MaxReconnects > 0 && failures > opts.MaxReconnects {
+				yield(Event{}, fmt.Errorf("sse: giving up after %d failed reconnects: %w", opts.MaxReconnects, err))
+				return
+			}
+
+			delay := retryDelay
+			for i := 1; i < failures && delay < maxRetryDelay; i++ {
+				delay *= 2
+			}
+			timer := time.NewTimer(min(delay, maxRetryDelay))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				yield(Event{}, ctx.Err())
+				return
+			case <-timer.C:
+			}
+		}
+	}
+}
+
+// StreamEvents delivers the events of EventSeq on a channel. The error
+// channel receives the error that ended the stream, if any; both channels are
+// closed when the stream ends.
+func (c *Client) StreamEvents(ctx context.Context, req *Request, opts SSEOptions) (<-chan Event, <-chan error) {
+	events := make(chan Event)
+	errs := make(chan error, 1)
+	go func() {
+		defer close(errs)
+		defer close(events)
+		for event, err := range c.EventSeq(ctx, req, opts) {
+			if err != nil {
+				errs <- err
+				return
+			}
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				errs <- ctx.Err()
+				return
+			}
+		}
+	}()
+	return events, errs
+}
+
+// readEventStream runs one connection. It reports whether any event was
+// delivered and whether iteration is finished, either because the consumer
+// stopped or because the server asked the client not to reconnect.
+func (c *Client) readEventStream(ctx context.Context, req *Request, lastEventID *string, retryDelay *time.Duration, yield func(Event, error) bool) (delivered, done bool, err error) {
+	resp, err := c.stream(ctx, req, nil)
+	if err != nil {
+		return false, false, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNoContent:
+		return false, true, nil
+	case resp.StatusCode >= 500:
+		return false, false, fmt.Errorf("sse: server returned %d", resp.StatusCode)
+	case resp.StatusCode != http.StatusOK:
+		return false, false, fmt.Errorf("%w: status %d", ErrNotEventStream, resp.StatusCode)
+	}
+	contentType, _ := lookupHeader(resp.Headers, "Content-Type")
+	if mediaType, _, _ := mime.ParseMediaType(contentType); mediaType != "text/event-stream" {
+		return false, false, fmt.Errorf("%w: content type %q", ErrNotEventStream, contentType)
+	}
+
+	parser := eventParser{lastEventID: *lastEventID}
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 4096), 1<<20)
+	scanner.Split((&eventLineSplitter{}).split)
+
+	for scanner.Scan() {
+		event, ok := parser.line(scanner.Text())
+		*lastEventID = parser.lastEventID
+		if parser.retry > 0 {
+			*retryDelay = parser.retry
+		}
+		if !ok {
+			continue
+		}
+		delivered = true
+		if !yield(event, nil) {
+			return delivered, true, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return delivered, false, fmt.Errorf("sse: error reading stream: %w", err)
+	}
+	return delivered, false, io.ErrUnexpectedEOF
+}
+
+// isRetryableStreamError reports whether a failed connection should be
+// re-established rather than ending the stream
+func isRetryableStreamError(err error) bool {
+	return !errors.Is(err, ErrNotEventStream)
+}
+
+// eventParser implements the field processing rules of the event stream
+// format in the HTML standard
+type eventParser struct {
+	lastEventID string
+	retry       time.Duration
+
+	eventType  string
+	data       strings.Builder
+	hasData    bool
+	eventRetry time.Duration
+}
+
+// line processes one line and returns the event it completes, if any
+func (p *eventParser) line(line string) (Event, bool) {
+	if line == "" {
+		return p.dispatch()
+	}
+	if strings.HasPrefix(line, ":") {
+		return Event{}, false
+	}
+
+	field, value, _ := strings.Cut(line, ":")
+	value = strings.TrimPrefix(value, " ")
+
+	switch field {
+	case "event":
+		p.eventType = value
+	case "data":
+		p.data.WriteString(value)
+		p.data.WriteByte('\n')
+		p.hasData = true
+	case "id":
+		if !strings.ContainsRune(value, 0) {
+			p.lastEventID = value
+		}
+	case "retry":
+		if ms, err := strconv.ParseUint(value, 10, 63); err == nil {
+			p.retry = time.Duration(ms) * time.Millisecond
+			p.eventRetry = p.retry
+		}
+	}
+	return Event{}, false
+}
+
+func (p *eventParser) dispatch() (Event, bool) {
+	defer func() {
+		p.eventType = ""
+		p.data.Reset()
+		p.hasData = false
+		p.eventRetry = 0
+	}()
+
+	if !p.hasData {
+		return Event{}, false
+	}
+
+	eventType := p.eventType
+	if eventType == "" {
+		eventType = "message"
+	}
+	return Event{
+		ID:    p.lastEventID,
+		Event: eventType,
+		Data:  strings.TrimSuffix(p.data.String(), "\n"),
+		Retry: p.eventRetry,
+	}, true
+}
+
+// eventLineSplitter splits on CRLF, LF or a lone CR as the event stream
+// format requires. A CR ends its line immediately so that a stream paused
+// after one is not held back; an LF arriving next is then skipped.
+type eventLineSplitter struct {
+	afterCR bool
+}
+
+func (s *eventLineSplitter) split(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	// The skipped LF is folded into the next token because the scanner
+	// stops at EOF on a nil token
+	skip := 0
+	if s.afterCR && len(data) > 0 && data[0] == '\n' {
+		skip = 1
+	}
+	rest := data[skip:]
+	if i := bytes.IndexAny(rest, "\r\n"); i >= 0 {
+		s.afterCR = rest[i] == '\r'
+		return skip + i + 1, rest[:i], nil
+	}
+	if atEOF && len(rest) > 0 {
+		s.afterCR = false
+		return len(data), rest, nil
+	}
+	return 0, nil, nil
+}
@@ -0,0 +1,165 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// setupEventStreamTestServer sends events 1 and 2 and drops the connection,
+// then resumes after whatever Last-Event-ID the client reconnects with and
+// holds the stream open. Every Last-Event-ID received is recorded.
+func setupEventStreamTestServer(lastEventIDs *[]string, mu *sync.Mutex) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		*lastEventIDs = append(*lastEventIDs, r.Header.Get("Last-Event-ID"))
+		mu.Unlock()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		flusher := w.(http.Flusher)
+
+		if r.Header.Get("Last-Event-ID") == "" {
+			fmt.Fprint(w, ": welcome\nretry: 10\n\n")
+			fmt.Fprint(w, "id: 1\nevent: update\ndata: first\n\n")
+			fmt.Fprint(w, "id: 2\r\ndata: second\r\ndata:line\r\n\r\n")
+			flusher.Flush()
+			return
+		}
+
+		fmt.Fprint(w, "id: 3\rdata: third\r\r")
+		flusher.Flush()
+		<-r.Context().Done()
+	}))
+}
+
+func TestEventSeqReconnects(t *testing.T) {
+	for _, backend := range []Backend{BackendStandard, BackendFastHTTP} {
+		t.Run(string(backend), func(t *testing.T) {
+			var mu sync.Mutex
+			var lastEventIDs []string
+			server := setupEventStreamTestServer(&lastEventIDs, &mu)
+			defer server.Close()
+
+			client, err := NewClient(backend)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var events []Event
+			req := &Request{Method: http.MethodGet, URL: server.URL}
+			for event, err := range client.EventSeq(context.Background(), req, SSEOptions{}) {
+				if err != nil {
+					t.Fatal(err)
+				}
+				events = append(events, event)
+				if len(events) == 3 {
+					break
+				}
+			}
+
+			want := []Event{
+				{ID: "1", Event: "update", Data: "first"},
+				{ID: "2", Event: "message", Data: "second\nline"},
+				{ID: "3", Event: "message", Data: "third"},
+			}
+			if fmt.Sprint(events) != fmt.Sprint(want) {
+				t.Errorf("events = %+v, want %+v", events, want)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if fmt.Sprint(lastEventIDs) != fmt.Sprint([]string{"", "2"}) {
+				t.Errorf("Last-Event-ID headers = %q, want [\"\" \"2\"]", lastEventIDs)
+			}
+		})
+	}
+}
+
+func TestStreamEventsCancel(t *testing.T) {
+	for _, backend := range []Backend{BackendStandard, BackendFastHTTP} {
+		t.Run(string(backend), func(t *testing.T) {
+			var mu sync.Mutex
+			var lastEventIDs []string
+			server := setupEventStreamTestServer(&lastEventIDs, &mu)
+			defer server.Close()
+
+			client, err := NewClient(backend)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			req := &Request{Method: http.MethodGet, URL: server.URL}
+			events, errs := client.StreamEvents(ctx, req, SSEOptions{LastEventID: "2"})
+
+			if event := <-events; event.ID != "3" {
+				t.Fatalf("first event = %+v, want id 3", event)
+			}
+			cancel()
+
+			select {
+			case err := <-errs:
+				if !errors.Is(err, context.Canceled) {
+					t.Errorf("err = %v, want context.Canceled", err)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("stream did not stop after cancel")
+			}
+			if _, ok := <-events; ok {
+				t.Error("events channel not closed")
+			}
+		})
+	}
+}
+
+func TestEventSeqRejectsNonEventStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{}`)
+	}))
+	defer server.Close()
+
+	client, err := NewClient(BackendStandard)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, err := range client.EventSeq(context.Background(), &Request{Method: http.MethodGet, URL: server.URL}, SSEOptions{}) {
+		if !errors.Is(err, ErrNotEventStream) {
+			t.Errorf("err = %v, want ErrNotEventStream", err)
+		}
+	}
+}
+
+func TestEventParser(t *testing.T) {
+	input := "data: a\n\n: comment\nevent: ping\ndata\n\nid: 7\nretry: 250\ndata:  b\n\nretry: soon\nid: bad\x00id\ndata: c\n\nevent: lonely\n\n"
+
+	var p eventParser
+	var events []Event
+	for _, line := range strings.Split(input, "\n") {
+		if event, ok := p.line(line); ok {
+			events = append(events, event)
+		}
+	}
+
+	want := []Event{
+		{Event: "message", Data: "a"},
+		{Event: "ping", Data: ""},
+		{ID: "7", Event: "message", Data: " b", Retry: 250 * time.Millisecond},
+		{ID: "7", Event: "message", Data: "c"},
+	}
+	if fmt.Sprintf("%q", events) != fmt.Sprintf("%q", want) {
+		t.Errorf("events = %q, want %q", events, want)
+	}
+	if p.retry != 250*time.Millisecond {
+		t.Errorf("retry = %v, want 250ms", p.retry)
+	}
+}
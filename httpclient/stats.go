@@ -0,0 +1,301 @@
+package httpclient
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// HostStats is a snapshot of connection pool activity for one host
+type HostStats struct {
+	// Open is the number of connections currently open
+	Open int64
+	// Idle is the number of open connections not serving a request. HTTP/2
+	// multiplexes requests on a connection, so it is approximate in that mode.
+	Idle int64
+	// Created and Closed count connections over the client's lifetime
+	Created int64
+	Closed  int64
+	// WaitTime is the total time requests spent waiting for a connection.
+	// fasthttp has no hook for taking a pooled connection, so on that backend
+	// it only covers dialing new connections.
+	WaitTime time.Duration
+	// PoolExhausted counts fasthttp requests rejected because MaxConnsPerHost
+	// connections were already busy
+	PoolExhausted int64
+}
+
+// StatsHook receives the pool stats of a host after each exchange with it,
+// for feeding a metrics system. It runs on the goroutine that sent the
+// request, so it should not block.
+type StatsHook func(host string, stats HostStats)
+
+// WithStatsHook calls hook with the stats of the host:port a request dialed
+// each time the backend finishes an exchange: when a buffered response has
+// been read, or when a streamed response's headers arrive. Responses
+// answered by a cassette or by fault injection do not call it.
+func WithStatsHook(hook StatsHook) Option {
+	return func(cfg *clientConfig) error {
+		cfg.statsHook = hook
+		return nil
+	}
+}
+
+// hostCounters holds the live counters behind a HostStats snapshot
+type hostCounters struct {
+	open          atomic.Int64
+	active        atomic.Int64
+	created       atomic.Int64
+	closed        atomic.Int64
+	waitTime      atomic.Int64
+	poolExhausted atomic.Int64
+}
+
+// poolStats tracks connections per host:port for one Client
+type poolStats struct {
+	hosts sync.Map // map[string]*hostCounters
+}
+
+func (s *poolStats) host(addr string) *hostCounters {
+	if counters, ok := s.hosts.Load(addr); ok {
+		return counters.(*hostCounters)
+	}
+	counters, _ := s.hosts.LoadOrStore(addr, &hostCounters{})
+	return counters.(*hostCounters)
+}
+
+func (s *poolStats) snapshot() map[string]HostStats {
+	stats := make(map[string]HostStats)
+	s.hosts.Range(func(key, value any) bool {
+		stats[key.(string)] = value.(*hostCounters).snapshot()
+		return true
+	})
+	return stats
+}
+
+// hostSnapshot returns the stats of one host, zero if it was never dialed
+func (s *poolStats) hostSnapshot(addr string) HostStats {
+	counters, ok := s.hosts.Load(addr)
+	if !ok {
+		return HostStats{}
+	}
+	return counters.(*hostCounters).snapshot()
+}
+
+func (c *hostCounters) snapshot() HostStats {
+	open := c.open.Load()
+	return HostStats{
+		Open:          open,
+		Idle:          max(open-c.active.Load(), 0),
+		Created:       c.created.Load(),
+		Closed:        c.closed.Load(),
+		WaitTime:      time.Duration(c.waitTime.Load()),
+		PoolExhausted: c.poolExhausted.Load(),
+	}
+}
+
+// dialer wraps dial so that every connection it opens is counted against the
+// host it was dialed for. fasthttp has no hook for taking a pooled
+// connection, so for that backend the dial duration is added to the host's
+// wait time and exchanges are marked by LocalAddr.
+func (s *poolStats) dialer(dial dialFunc, backend Backend) dialFunc {
+	fast := backend == BackendFastHTTP
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		start := time.Now()
+		conn, err := dial(ctx, network, addr)
+		counters := s.host(addr)
+		if fast {
+			counters.waitTime.Add(int64(time.Since(start)))
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		counters.created.Add(1)
+		counters.open.Add(1)
+		return &countedConn{Conn: conn, counters: counters, exchangeOnLocalAddr: fast}, nil
+	}
+}
+
+// closeConn closes the open connection that reported localAddr. A stream
+// uses it to interrupt a read fasthttp has no way to cancel.
+func (s *poolStats) closeConn(localAddr net.Addr) {
+	if addr, ok := localAddr.(*connAddr); ok {
+		addr.conn.Close()
+	}
+}
+
+// wireBytes returns the bytes read and written on the connection during the
+// exchange that reported localAddr, or zeros when it did not come from a
+// counted connection
+func wireBytes(localAddr net.Addr) (read, written int64) {
+	addr, ok := localAddr.(*connAddr)
+	if !ok {
+		return 0, 0
+	}
+	return addr.conn.exchangeBytes(addr.exchange)
+}
+
+// connAddr identifies an exchange on a counted connection. fasthttp hands it
+// out as the connection's local address. It points back at the connection,
+// since Unix sockets and custom dialers can report the same address for
+// every connection.
+type connAddr struct {
+	addr     net.Addr
+	conn     *countedConn
+	exchange *wireExchange
+}
+
+func (a *connAddr) Network() string {
+	if a.addr == nil {
+		return ""
+	}
+	return a.addr.Network()
+}
+
+func (a *connAddr) String() string {
+	if a.addr == nil {
+		return ""
+	}
+	return a.addr.String()
+}
+
+// wireExchange is the span of a connection's traffic that belongs to one
+// request, as byte offsets into the connection's totals. end is set once
+// the next exchange starts.
+type wireExchange struct {
+	startRead, startWritten int64
+	endRead, endWritten     int64
+	ended                   bool
+}
+
+// countedConn decrements the open count of its host when closed and counts
+// the bytes that cross it, TLS records included
+type countedConn struct {
+	net.Conn
+	counters *hostCounters
+	once     sync.Once
+	// exchangeOnLocalAddr makes LocalAddr mark exchanges, for fasthttp
+	exchangeOnLocalAddr bool
+
+	read    atomic.Int64
+	written atomic.Int64
+
+	mu      sync.Mutex
+	current *wireExchange
+}
+
+func (c *countedConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	c.read.Add(int64(n))
+	return n, err
+}
+
+func (c *countedConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	c.written.Add(int64(n))
+	return n, err
+}
+
+// beginExchange starts an exchange on conn, or on the counted connection it
+// wraps, and returns the address identifying it. It returns nil for
+// connections that are not counted. The net/http backend calls it from its
+// GotConn hook.
+func beginExchange(conn net.Conn) net.Addr {
+	for {
+		switch c := conn.(type) {
+		case *countedConn:
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			return c.beginExchangeLocked()
+		case interface{ NetConn() net.Conn }:
+			conn = c.NetConn()
+		default:
+			return nil
+		}
+	}
+}
+
+// LocalAddr marks exchanges on fasthttp connections. fasthttp calls it once
+// per request, right after taking the connection from its pool, so the
+// address a response carries identifies the bytes of that response. Calls
+// made before the current exchange has moved any bytes return it again
+// rather than starting another. Other connections report their plain
+// address.
+func (c *countedConn) LocalAddr() net.Addr {
+	if !c.exchangeOnLocalAddr {
+		return c.Conn.LocalAddr()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if current := c.current; current != nil && c.read.Load() == current.startRead && c.written.Load() == current.startWritten {
+		return &connAddr{addr: c.Conn.LocalAddr(), conn: c, exchange: current}
+	}
+	return c.beginExchangeLocked()
+}
+
+// beginExchangeLocked ends the current exchange and starts the next one. The
+// first exchange starts at zero so that it includes the TLS handshake.
+func (c *countedConn) beginExchangeLocked() *connAddr {
+	exchange := &wireExchange{}
+	if c.current != nil {
+		c.current.endRead, c.current.endWritten = c.read.Load(), c.written.Load()
+		c.current.ended = true
+		exchange.startRead, exchange.startWritten = c.current.endRead, c.current.endWritten
+	}
+	c.current = exchange
+	return &connAddr{addr: c.Conn.LocalAddr(), conn: c, exchange: exchange}
+}
+
+func (c *countedConn) exchangeBytes(exchange *wireExchange) (read, written int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if exchange.ended {
+		return exchange.endRead - exchange.startRead, exchange.endWritten - exchange.startWritten
+	}
+	return c.read.Load() - exchange.startRead, c.written.Load() - exchange.startWritten
+}
+
+func (c *countedConn) Close() error {
+	c.once.Do(func() {
+		c.counters.open.Add(-1)
+		c.counters.closed.Add(1)
+	})
+	return c.Conn.Close()
+}
+
+// Stats returns a snapshot of the client's connection pools keyed by the
+// host:port that was dialed
+func (c *Client) Stats() map[string]HostStats {
+	return c.stats.snapshot()
+}
+
+// reportStats passes the stats of the host rawURL dials to the stats hook,
+// if one is configured
+func (c *Client) reportStats(rawURL string) {
+	if c.statsHook == nil {
+		return
+	}
+	host := requestHostPort(rawURL)
+	c.statsHook(host, c.stats.hostSnapshot(host))
+}
+
+// requestHostPort returns the host:port a request URL dials, matching the
+// address both backends pass to their dialer
+func requestHostPort(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	if u.Port() != "" {
+		return u.Host
+	}
+	if u.Scheme == "https" {
+		return net.JoinHostPort(u.Hostname(), "443")
+	}
+	return net.JoinHostPort(u.Hostname(), "80")
+}
@@ -0,0 +1,405 @@
+package httpclient
+
+import (
+	"bufio"
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// reportPoolStats adds the client's connection pool counters for serverURL to the
+// benchmark output
+func reportPoolStats(b *testing.B, client *Client, serverURL string) {
+	stats := client.Stats()[requestHostPort(serverURL)]
+	b.ReportMetric(float64(stats.Created), "conns_created")
+	b.ReportMetric(float64(stats.WaitTime.Nanoseconds())/float64(b.N), "conn_wait_ns/op")
+	if client.Backend() == BackendFastHTTP {
+		b.ReportMetric(float64(stats.PoolExhausted)/float64(b.N), "pool_exhausted/op")
+	}
+}
+
+func TestClientStatsHook(t *testing.T) {
+	server := setupTestServer()
+	defer server.Close()
+	host := requestHostPort(server.URL)
+
+	for _, backend := range []Backend{BackendStandard, BackendFastHTTP} {
+		t.Run(string(backend), func(t *testing.T) {
+			var mu sync.Mutex
+			var reports []HostStats
+			client, err := NewClient(backend, WithStatsHook(func(gotHost string, stats HostStats) {
+				if gotHost != host {
+					t.Errorf("hook host = %s, want %s", gotHost, host)
+				}
+				mu.Lock()
+				reports = append(reports, stats)
+				mu.Unlock()
+			}))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for i := 0; i < 3; i++ {
+				if resp := client.Get(context.Background(), server.URL, nil); resp.Error != nil {
+					t.Fatal(resp.Error)
+				}
+			}
+			stream, err := client.stream(context.Background(), &Request{Method: http.MethodGet, URL: server.URL}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			stream.Body.Close()
+
+			mu.Lock()
+			defer mu.Unlock()
+			if len(reports) != 4 {
+				t.Fatalf("hook called %d times, want once per exchange (4)", len(reports))
+			}
+			if first := reports[0]; first.Created != 1 || first.Open != 1 {
+				t.Errorf("first report = %+v, want the connection just dialed", first)
+			}
+			if last := reports[2]; last.Created != 1 || last.Idle != 1 {
+				t.Errorf("third report = %+v, want the reused connection back in the pool", last)
+			}
+		})
+	}
+}
+
+func TestClientStatsReuse(t *testing.T) {
+	server := setupTestServer()
+	defer server.Close()
+	host := requestHostPort(server.URL)
+
+	for _, backend := range []Backend{BackendStandard, BackendFastHTTP} {
+		t.Run(string(backend), func(t *testing.T) {
+			client, err := NewClient(backend)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for i := 0; i < 5; i++ {
+				if resp := client.Get(context.Background(), server.URL, nil); resp.Error != nil {
+					t.Fatal(resp.Error)
+				}
+			}
+
+			stats, ok := client.Stats()[host]
+			if !ok {
+				t.Fatalf("no stats for %s: %v", host, client.Stats())
+			}
+			if stats.Created != 1 || stats.Open != 1 || stats.Idle != 1 || stats.Closed != 0 {
+				t.Errorf("stats = %+v, want one reused idle connection", stats)
+			}
+			if stats.WaitTime <= 0 {
+				t.Errorf("WaitTime = %s, want > 0", stats.WaitTime)
+			}
+		})
+	}
+}
+
+func TestClientStatsPoolExhausted(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	host := requestHostPort(server.URL)
+
+	client, err := NewClient(BackendFastHTTP, WithMaxConnsPerHost(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		client.Get(context.Background(), server.URL, nil)
+	}()
+
+	// Wait for the first request to hold the only connection
+	for client.Stats()[host].Open == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	resp := client.Get(context.Background(), server.URL, nil)
+	close(release)
+	wg.Wait()
+
+	if !errors.Is(resp.Error, fasthttp.ErrNoFreeConns) {
+		t.Fatalf("error = %v, want ErrNoFreeConns", resp.Error)
+	}
+	if got := client.Stats()[host].PoolExhausted; got != 1 {
+		t.Errorf("PoolExhausted = %d, want 1", got)
+	}
+}
+
+func BenchmarkPoolSizing(b *testing.B) {
+	server := setupTestServer()
+	defer server.Close()
+
+	ctx := context.Background()
+	headers := map[string]string{
+		"User-Agent": "Benchmark-Client",
+	}
+
+	for _, backend := range []Backend{BackendStandard, BackendFastHTTP} {
+		for _, maxConns := range []int{4, 64} {
+			b.Run(fmt.Sprintf("%s/MaxConnsPerHost-%d", backend, maxConns), func(b *testing.B) {
+				client, err := NewClient(backend, WithMaxConnsPerHost(maxConns), WithMaxIdleConnsPerHost(maxConns))
+				if err != nil {
+					b.Fatal(err)
+				}
+
+				b.SetParallelism(16)
+				b.ResetTimer()
+				b.RunParallel(func(pb *testing.PB) {
+					for pb.Next() {
+						resp := client.Get(ctx, server.URL, headers)
+						// Exhaustion is what this benchmark measures, so it is reported rather than fatal
+						if resp.Error != nil && !errors.Is(resp.Error, fasthttp.ErrNoFreeConns) {
+							b.Error(resp.Error)
+							return
+						}
+					}
+				})
+				b.StopTimer()
+
+				reportPoolStats(b, client, server.URL)
+			})
+		}
+	}
+}
+
+// setupRawTestServer answers every request on a connection with the raw
+// response, keeping the connection open, and sends the number of request
+// bytes it read for each request on seen
+func setupRawTestServer(t *testing.T, response string, seen chan<- int) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				for {
+					n := 0
+					contentLength := 0
+					for {
+						line, err := r.ReadString('\n')
+						if err != nil {
+							return
+						}
+						n += len(line)
+						if v, ok := strings.CutPrefix(strings.ToLower(line), "content-length:"); ok {
+							contentLength, _ = strconv.Atoi(strings.TrimSpace(v))
+						}
+						if line == "\r\n" {
+							break
+						}
+					}
+					if _, err := io.CopyN(io.Discard, r, int64(contentLength)); err != nil {
+						return
+					}
+					seen <- n + contentLength
+					if _, err := io.WriteString(conn, response); err != nil {
+						return
+					}
+				}
+			}()
+		}
+	}()
+	return "http://" + listener.Addr().String()
+}
+
+func TestWireBytes(t *testing.T) {
+	responses := map[string]string{
+		"content-length": "HTTP/1.1 200 OK\r\nContent-Length: 5\r\nContent-Type: text/plain\r\n\r\nhello",
+		"chunked":        "HTTP/1.1 200 OK\r\nTransfer-Encoding: chunked\r\n\r\n5\r\nhello\r\n6\r\n world\r\n0\r\n\r\n",
+	}
+
+	for _, backend := range []Backend{BackendStandard, BackendFastHTTP} {
+		for name, response := range responses {
+			t.Run(string(backend)+"/"+name, func(t *testing.T) {
+				seen := make(chan int, 100)
+				url := setupRawTestServer(t, response, seen)
+				client, err := NewClient(backend)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				// The second request reuses the connection
+				for i := range 2 {
+					resp := client.Do(context.Background(), &Request{Method: http.MethodPost, URL: url, Body: []byte(`{"n":1}`)})
+					if resp.Error != nil {
+						t.Fatal(resp.Error)
+					}
+					if resp.WireBytesRead != int64(len(response)) {
+						t.Errorf("request %d: WireBytesRead = %d, want %d (body is %d bytes)", i, resp.WireBytesRead, len(response), len(resp.Body))
+					}
+					if want := <-seen; resp.WireBytesWritten != int64(want) {
+						t.Errorf("request %d: WireBytesWritten = %d, server read %d", i, resp.WireBytesWritten, want)
+					}
+				}
+				if created := client.Stats()[requestHostPort(url)].Created; created != 1 {
+					t.Errorf("%d connections created, want 1", created)
+				}
+			})
+		}
+	}
+}
+
+func TestWireBytesConcurrent(t *testing.T) {
+	response := "HTTP/1.1 200 OK\r\nContent-Length: 2\r\n\r\nok"
+	for _, backend := range []Backend{BackendStandard, BackendFastHTTP} {
+		t.Run(string(backend), func(t *testing.T) {
+			seen := make(chan int, 1000)
+			url := setupRawTestServer(t, response, seen)
+			client, err := NewClient(backend, WithMaxConnsPerHost(4))
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := client.Get(context.Background(), url, nil).WireBytesWritten
+
+			// Connections are handed between requests constantly; every
+			// response must still carry exactly its own bytes. fasthttp
+			// fails requests beyond MaxConnsPerHost instead of queueing them.
+			var wg sync.WaitGroup
+			for range 4 {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for range 100 {
+						resp := client.Get(context.Background(), url, nil)
+						if resp.Error != nil {
+							t.Error(resp.Error)
+							return
+						}
+						if resp.WireBytesRead != int64(len(response)) || resp.WireBytesWritten != want {
+							t.Errorf("wire bytes %d read, %d written; want %d, %d", resp.WireBytesRead, resp.WireBytesWritten, len(response), want)
+							return
+						}
+					}
+				}()
+			}
+			wg.Wait()
+		})
+	}
+}
+
+func TestWireBytesLocalAddr(t *testing.T) {
+	newConn := func(exchangeOnLocalAddr bool) *countedConn {
+		client, server := net.Pipe()
+		t.Cleanup(func() { client.Close() })
+		go io.Copy(io.Discard, server)
+		return &countedConn{Conn: client, counters: &hostCounters{}, exchangeOnLocalAddr: exchangeOnLocalAddr}
+	}
+	check := func(name string, addr net.Addr, want int64) {
+		t.Helper()
+		if read, written := wireBytes(addr); read != 0 || written != want {
+			t.Errorf("%s: wire bytes %d read, %d written; want 0, %d", name, read, written, want)
+		}
+	}
+
+	// fasthttp marks each request with LocalAddr, so repeated calls before
+	// any traffic must name the same exchange
+	conn := newConn(true)
+	first := conn.LocalAddr()
+	again := conn.LocalAddr()
+	conn.Write([]byte("abc"))
+	next := conn.LocalAddr()
+	conn.LocalAddr()
+	conn.Write([]byte("de"))
+	check("first", first, 3)
+	check("repeated", again, 3)
+	check("next", next, 2)
+
+	// On net/http connections exchanges begin only through beginExchange
+	conn = newConn(false)
+	addr := beginExchange(conn)
+	conn.Write([]byte("abc"))
+	if _, ok := conn.LocalAddr().(*connAddr); ok {
+		t.Error("LocalAddr of a net/http connection marked an exchange")
+	}
+	conn.LocalAddr()
+	conn.Write([]byte("de"))
+	check("net/http", addr, 5)
+}
+
+func TestWireBytesTLS(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"message": "success"}`))
+	}))
+	server.EnableHTTP2 = false
+	server.StartTLS()
+	defer server.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+
+	for _, backend := range []Backend{BackendStandard, BackendFastHTTP} {
+		t.Run(string(backend), func(t *testing.T) {
+			client, err := NewClient(backend, WithTLS(TLSOptions{RootCAs: roots}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			first := client.Get(context.Background(), server.URL, nil)
+			second := client.Get(context.Background(), server.URL, nil)
+			if first.Error != nil || second.Error != nil {
+				t.Fatal(first.Error, second.Error)
+			}
+
+			// The first request carries the handshake, which includes the
+			// server certificate; later ones only pay for TLS records
+			if first.WireBytesRead < second.WireBytesRead+500 {
+				t.Errorf("first request read %d bytes, second %d; want the handshake in the first", first.WireBytesRead, second.WireBytesRead)
+			}
+			if second.WireBytesRead <= int64(len(second.Body)) || second.WireBytesWritten == 0 {
+				t.Errorf("second request read %d and wrote %d bytes for a %d byte body", second.WireBytesRead, second.WireBytesWritten, len(second.Body))
+			}
+		})
+	}
+}
+
+func TestWireBytesAcrossRedirects(t *testing.T) {
+	server := setupRedirectTestServer("")
+	defer server.Close()
+
+	for _, backend := range []Backend{BackendStandard, BackendFastHTTP} {
+		t.Run(string(backend), func(t *testing.T) {
+			client, err := NewClient(backend)
+			if err != nil {
+				t.Fatal(err)
+			}
+			direct := client.Get(context.Background(), server.URL+"/echo", nil)
+			redirected := client.Get(context.Background(), server.URL+"/chain", nil)
+			if direct.Error != nil || redirected.Error != nil {
+				t.Fatal(direct.Error, redirected.Error)
+			}
+			if redirected.WireBytesRead <= direct.WireBytesRead || redirected.WireBytesWritten <= 2*direct.WireBytesWritten {
+				t.Errorf("three hops moved %d/%d bytes, one hop %d/%d", redirected.WireBytesRead, redirected.WireBytesWritten, direct.WireBytesRead, direct.WireBytesWritten)
+			}
+		})
+	}
+}
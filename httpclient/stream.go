@@ -0,0 +1,285 @@
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/valyala/fasthttp"
+)
+
+// streamResponse is a response whose body is read incrementally rather than
+// buffered. Body must always be closed.
+type streamResponse struct {
+	StatusCode int
+	Headers    map[string]string
+	Body       io.ReadCloser
+}
+
+// bodyStream is a request body that is read while the request is sent
+type bodyStream struct {
+	r io.Reader
+	// size is the body length, or -1 to send it chunked
+	size int64
+}
+
+// stream sends a single signed attempt and returns as soon as the response
+// headers arrive, following redirects as the RedirectPolicy allows.
+// Cancelling ctx ends the call on either backend, whether it is waiting for
+// the headers or blocked reading the body. When body is set it replaces
+// req.Body; signers see an empty body.
+func (c *Client) stream(ctx context.Context, req *Request, body *bodyStream) (*streamResponse, error) {
+	for hops := 0; ; hops++ {
+		resp, err := c.streamOnce(ctx, req, body)
+		if err != nil {
+			return nil, err
+		}
+
+		next, err := c.redirects.next(req, resp.StatusCode, resp.Headers, hops)
+		if err != nil {
+			resp.Body.Close()
+			return nil, err
+		}
+		if next == nil {
+			return resp, nil
+		}
+		if body != nil {
+			// A streamed body has been consumed, so only a redirect that
+			// drops it can be followed
+			if next.Method == req.Method {
+				return resp, nil
+			}
+			body = nil
+		}
+		resp.Body.Close()
+		req = next
+	}
+}
+
+// streamOnce is stream without redirects
+func (c *Client) streamOnce(ctx context.Context, req *Request, body *bodyStream) (*streamResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("error making request: %w", err)
+	}
+
+	if c.signer != nil {
+		req = req.Clone()
+		if err := c.signer.Sign(req); err != nil {
+			return nil, fmt.Errorf("error signing request: %w", err)
+		}
+	}
+
+	if c.faults != nil {
+		return c.faults.stream(ctx, c, req, body, c.streamDeliver)
+	}
+	return c.streamDeliver(ctx, req, body)
+}
+
+// streamDeliver is deliver for streamed responses
+func (c *Client) streamDeliver(ctx context.Context, req *Request, body *bodyStream) (*streamResponse, error) {
+	if c.vcr != nil {
+		return c.vcr.stream(ctx, req, body, c.streamRoundTrip)
+	}
+	return c.streamRoundTrip(ctx, req, body)
+}
+
+// streamRoundTrip is roundTrip for streamed responses
+func (c *Client) streamRoundTrip(ctx context.Context, req *Request, body *bodyStream) (*streamResponse, error) {
+	defer c.reportStats(req.URL)
+	if c.backend == BackendFastHTTP {
+		return c.streamFastHTTP(ctx, req, body)
+	}
+	return c.streamStandard(ctx, req, body)
+}
+
+func (c *Client) streamStandard(ctx context.Context, r *Request, body *bodyStream) (*streamResponse, error) {
+	var bodyReader io.Reader
+	if body != nil {
+		bodyReader = body.r
+	} else if r.Body != nil {
+		bodyReader = bytes.NewReader(r.Body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, r.Method, r.URL, bodyReader)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+	if body != nil {
+		req.ContentLength = body.size
+	}
+	for key, value := range r.Headers {
+		req.Header.Set(key, value)
+	}
+	proxyURL := c.forwardProxy(r.URL)
+	if proxyURL != nil {
+		forwardStandard(req, proxyURL)
+	}
+
+	resp, err := c.standardStream.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error making request: %w", err)
+	}
+	if proxyURL != nil && resp.StatusCode == http.StatusProxyAuthRequired {
+		resp.Body.Close()
+		return nil, fmt.Errorf("error making request: %w", ErrProxyAuth)
+	}
+
+	respHeaders := make(map[string]string)
+	for key, values := range resp.Header {
+		if len(values) > 0 {
+			respHeaders[key] = values[0]
+		}
+	}
+
+	return &streamResponse{
+		StatusCode: resp.StatusCode,
+		Headers:    respHeaders,
+		Body:       resp.Body,
+	}, nil
+}
+
+func (c *Client) streamFastHTTP(ctx context.Context, r *Request, body *bodyStream) (*streamResponse, error) {
+	req := fasthttp.AcquireRequest()
+	resp := fasthttp.AcquireResponse()
+
+	req.SetRequestURI(r.URL)
+	req.Header.SetMethod(r.Method)
+	for key, value := range r.Headers {
+		req.Header.Set(key, value)
+	}
+	if body != nil {
+		// fasthttp cannot abort a request that is being written, so the body
+		// stops at the next read once ctx is done
+		req.SetBodyStream(&contextReader{ctx: ctx, r: body.r}, int(body.size))
+	} else if r.Body != nil {
+		req.SetBody(r.Body)
+	}
+	resp.StreamBody = true
+	proxyURL := c.forwardProxy(r.URL)
+	if proxyURL != nil {
+		forwardFastHTTP(req, proxyURL)
+	}
+
+	// fasthttp cannot cancel a request before its headers arrive, so it runs
+	// on its own goroutine. A ctx deadline bounds it through DoDeadline; on
+	// cancellation the caller returns at once and the abandoned request is
+	// released when the server answers or the connection fails.
+	done := make(chan error, 1)
+	go func() {
+		if deadline, ok := ctx.Deadline(); ok {
+			done <- c.fastStream.DoDeadline(req, resp, deadline)
+		} else {
+			done <- c.fastStream.Do(req, resp)
+		}
+	}()
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		go func() {
+			if <-done == nil {
+				resp.SetConnectionClose()
+				resp.CloseBodyStream()
+			}
+			fasthttp.ReleaseRequest(req)
+			fasthttp.ReleaseResponse(resp)
+		}()
+		return nil, fmt.Errorf("error making request: %w", ctx.Err())
+	}
+	if err == nil && proxyURL != nil && resp.StatusCode() == http.StatusProxyAuthRequired {
+		resp.SetConnectionClose()
+		resp.CloseBodyStream()
+		err = ErrProxyAuth
+	}
+	if err != nil {
+		fasthttp.ReleaseRequest(req)
+		fasthttp.ReleaseResponse(resp)
+		return nil, fmt.Errorf("error making request: %w", err)
+	}
+
+	respHeaders := make(map[string]string)
+	resp.Header.VisitAll(func(key, value []byte) {
+		respHeaders[string(key)] = string(value)
+	})
+
+	respBody := &fastStreamBody{req: req, resp: resp, stream: resp.BodyStream()}
+	if respBody.stream == nil {
+		// Small bodies are read eagerly even in streaming mode
+		respBody.stream = bytes.NewReader(resp.Body())
+	}
+
+	// A blocked read can only be interrupted by closing the connection
+	localAddr := resp.LocalAddr()
+	if localAddr != nil {
+		respBody.stop = context.AfterFunc(ctx, func() {
+			respBody.mu.Lock()
+			respBody.cancelled = ctx.Err()
+			respBody.mu.Unlock()
+			c.stats.closeConn(localAddr)
+		})
+	}
+
+	return &streamResponse{
+		StatusCode: resp.StatusCode(),
+		Headers:    respHeaders,
+		Body:       respBody,
+	}, nil
+}
+
+// contextReader fails reads once its context is done
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
+
+// fastStreamBody owns a fasthttp request and response pair until the
+// streamed body is closed
+type fastStreamBody struct {
+	req    *fasthttp.Request
+	resp   *fasthttp.Response
+	stream io.Reader
+	stop   func() bool
+	eof    bool
+
+	mu        sync.Mutex
+	cancelled error
+}
+
+func (b *fastStreamBody) Read(p []byte) (int, error) {
+	n, err := b.stream.Read(p)
+	if err == io.EOF {
+		b.eof = true
+	} else if err != nil {
+		b.mu.Lock()
+		if b.cancelled != nil {
+			err = b.cancelled
+		}
+		b.mu.Unlock()
+	}
+	return n, err
+}
+
+func (b *fastStreamBody) Close() error {
+	if b.stop != nil {
+		b.stop()
+	}
+	// A partly read body leaves the connection unusable, so it must not go
+	// back to the pool
+	if !b.eof {
+		b.resp.SetConnectionClose()
+	}
+	err := b.resp.CloseBodyStream()
+	fasthttp.ReleaseRequest(b.req)
+	fasthttp.ReleaseResponse(b.resp)
+	return err
+}
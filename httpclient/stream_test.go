@@ -0,0 +1,53 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"golang-content/httpclient/httpclienttest"
+)
+
+func TestStreamStalledServer(t *testing.T) {
+	server := httpclienttest.NewServer()
+	defer server.Close()
+	// The server accepts the request and then sends nothing for a while
+	server.Handle(http.MethodGet, "/events").Latency(time.Second).Respond(http.StatusOK, "data: late\n\n")
+
+	for _, backend := range []Backend{BackendStandard, BackendFastHTTP} {
+		t.Run(string(backend), func(t *testing.T) {
+			client, err := NewClient(backend)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req := &Request{Method: http.MethodGet, URL: server.URL + "/events"}
+
+			t.Run("cancel", func(t *testing.T) {
+				ctx, cancel := context.WithCancel(context.Background())
+				time.AfterFunc(50*time.Millisecond, cancel)
+				start := time.Now()
+				_, err := client.stream(ctx, req, nil)
+				if !errors.Is(err, context.Canceled) {
+					t.Errorf("error = %v, want context.Canceled", err)
+				}
+				if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+					t.Errorf("cancelled stream returned after %v", elapsed)
+				}
+			})
+
+			t.Run("deadline", func(t *testing.T) {
+				ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+				defer cancel()
+				start := time.Now()
+				if _, err := client.stream(ctx, req, nil); err == nil {
+					t.Error("stream past its deadline got no error")
+				}
+				if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+					t.Errorf("stream past its deadline returned after %v", elapsed)
+				}
+			})
+		})
+	}
+}
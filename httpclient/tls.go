@@ -0,0 +1,284 @@
+package httpclient
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// ErrPinMismatch is returned during the TLS handshake when none of the
+// server's verified certificates match a configured SPKI pin
+var ErrPinMismatch = errors.New("tls: no certificate matches the pinned public keys")
+
+// TLSOptions describes the TLS settings shared by both backends
+type TLSOptions struct {
+	// RootCAFile is a PEM bundle of trusted roots; RootCAs is used when it is
+	// empty and the system pool when both are unset
+	RootCAFile string
+	RootCAs    *x509.CertPool
+
+	// CertFile and KeyFile hold the PEM client certificate and key for mutual TLS
+	CertFile string
+	KeyFile  string
+
+	// MinVersion defaults to TLS 1.2
+	MinVersion uint16
+	ServerName string
+
+	// PinnedSPKI lists base64 SHA-256 hashes of trusted SubjectPublicKeyInfo
+	// blocks; at least one certificate in the verified chain must match
+	PinnedSPKI []string
+
+	// ReloadInterval is how often the certificate files are checked for
+	// changes; zero loads them once
+	ReloadInterval time.Duration
+}
+
+// WithTLS applies the TLS options to the client's transport
+func WithTLS(opts TLSOptions) Option {
+	return func(cfg *clientConfig) error {
+		tlsConfig, verify, err := opts.config()
+		if err != nil {
+			return err
+		}
+		cfg.tlsConfig, cfg.tlsVerify = tlsConfig, verify
+		return nil
+	}
+}
+
+// Config builds a tls.Config usable with either net/http or fasthttp. When
+// RootCAFile is reloaded, the handshake only reports the SNI name, which is
+// empty for IP addresses, so connections to an IP need ServerName set to it.
+func (o TLSOptions) Config() (*tls.Config, error) {
+	config, _, err := o.config()
+	return config, err
+}
+
+// tlsHostVerifier checks a completed handshake against the host that was
+// dialed
+type tlsHostVerifier func(cs tls.ConnectionState, host string) error
+
+// config builds the tls.Config and, when the roots are reloaded and the
+// chain is verified by hand, the verifier that configForHost binds to each
+// dialed host
+func (o TLSOptions) config() (*tls.Config, tlsHostVerifier, error) {
+	if (o.CertFile == "") != (o.KeyFile == "") {
+		return nil, nil, errors.New("tls: CertFile and KeyFile must be set together")
+	}
+
+	pins := make(map[string]bool, len(o.PinnedSPKI))
+	for _, pin := range o.PinnedSPKI {
+		raw, err := base64.StdEncoding.DecodeString(pin)
+		if err != nil || len(raw) != sha256.Size {
+			return nil, nil, fmt.Errorf("tls: invalid SPKI pin %q", pin)
+		}
+		pins[pin] = true
+	}
+
+	files := &tlsFiles{opts: o}
+	if err := files.load(); err != nil {
+		return nil, nil, err
+	}
+
+	config := &tls.Config{
+		MinVersion: o.MinVersion,
+		ServerName: o.ServerName,
+		RootCAs:    o.RootCAs,
+	}
+	if config.MinVersion == 0 {
+		config.MinVersion = tls.VersionTLS12
+	}
+
+	if o.CertFile != "" {
+		config.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			cert, _ := files.current()
+			return cert, nil
+		}
+	}
+
+	// A root pool that can change at runtime cannot be expressed through
+	// RootCAs, so the chain is verified by hand against the current pool
+	reloadRoots := o.RootCAFile != "" && o.ReloadInterval > 0
+	if o.RootCAFile != "" && !reloadRoots {
+		_, config.RootCAs = files.current()
+	}
+	config.InsecureSkipVerify = reloadRoots
+
+	if len(pins) == 0 && !reloadRoots {
+		return config, nil, nil
+	}
+
+	verify := func(cs tls.ConnectionState, host string) error {
+		chains := cs.VerifiedChains
+		if reloadRoots {
+			_, pool := files.current()
+			var err error
+			chains, err = verifyPeer(cs, pool, host)
+			if err != nil {
+				return err
+			}
+		}
+		if len(pins) > 0 && !chainsMatchPins(chains, pins) {
+			return ErrPinMismatch
+		}
+		return nil
+	}
+	config.VerifyConnection = func(cs tls.ConnectionState) error {
+		host := o.ServerName
+		if host == "" {
+			host = cs.ServerName
+		}
+		return verify(cs, host)
+	}
+	if !reloadRoots {
+		return config, nil, nil
+	}
+	return config, verify, nil
+}
+
+// configForHost returns a copy of config for a connection to host. With a
+// verifier, the chain is checked against host itself rather than the SNI
+// name the handshake reports, which is empty when host is an IP address.
+func configForHost(config *tls.Config, host string, verify tlsHostVerifier) *tls.Config {
+	if config == nil {
+		config = &tls.Config{}
+	}
+	config = config.Clone()
+	if config.ServerName == "" {
+		config.ServerName = host
+	}
+	if verify != nil {
+		name := config.ServerName
+		config.VerifyConnection = func(cs tls.ConnectionState) error {
+			return verify(cs, name)
+		}
+	}
+	return config
+}
+
+// SPKIPin returns the base64 SHA-256 hash of the certificate's public key in
+// the form expected by TLSOptions.PinnedSPKI
+func SPKIPin(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func verifyPeer(cs tls.ConnectionState, roots *x509.CertPool, host string) ([][]*x509.Certificate, error) {
+	if len(cs.PeerCertificates) == 0 {
+		return nil, errors.New("tls: server presented no certificates")
+	}
+	// An empty DNSName skips the host check, so a missing name must fail
+	if host == "" {
+		return nil, errors.New("tls: no server name to verify the certificate against")
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range cs.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	chains, err := cs.PeerCertificates[0].Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		DNSName:       host,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("tls: error verifying server certificate: %w", err)
+	}
+	return chains, nil
+}
+
+func chainsMatchPins(chains [][]*x509.Certificate, pins map[string]bool) bool {
+	for _, chain := range chains {
+		for _, cert := range chain {
+			if pins[SPKIPin(cert)] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// tlsFiles keeps the certificate material loaded from disk and reloads it
+// when the files change
+type tlsFiles struct {
+	opts TLSOptions
+
+	mu        sync.Mutex
+	cert      *tls.Certificate
+	pool      *x509.CertPool
+	modTimes  map[string]time.Time
+	lastCheck time.Time
+}
+
+// load reads every configured file, replacing the current material only if
+// all of them parse
+func (f *tlsFiles) load() error {
+	modTimes := make(map[string]time.Time)
+	for _, name := range []string{f.opts.RootCAFile, f.opts.CertFile, f.opts.KeyFile} {
+		if name == "" {
+			continue
+		}
+		info, err := os.Stat(name)
+		if err != nil {
+			return fmt.Errorf("tls: %w", err)
+		}
+		modTimes[name] = info.ModTime()
+	}
+
+	var cert *tls.Certificate
+	if f.opts.CertFile != "" {
+		pair, err := tls.LoadX509KeyPair(f.opts.CertFile, f.opts.KeyFile)
+		if err != nil {
+			return fmt.Errorf("tls: error loading client certificate: %w", err)
+		}
+		cert = &pair
+	}
+
+	var pool *x509.CertPool
+	if f.opts.RootCAFile != "" {
+		pem, err := os.ReadFile(f.opts.RootCAFile)
+		if err != nil {
+			return fmt.Errorf("tls: %w", err)
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("tls: no certificates found in %s", f.opts.RootCAFile)
+		}
+	}
+
+	f.cert, f.pool, f.modTimes = cert, pool, modTimes
+	return nil
+}
+
+// current returns the loaded material, first reloading it if the reload
+// interval has passed and a file changed. A failed reload keeps the previous
+// material so that a half-written file does not break live connections.
+func (f *tlsFiles) current() (*tls.Certificate, *x509.CertPool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.opts.ReloadInterval > 0 && time.Since(f.lastCheck) >= f.opts.ReloadInterval {
+		f.lastCheck = time.Now()
+		if f.changed() {
+			_ = f.load()
+		}
+	}
+	return f.cert, f.pool
+}
+
+func (f *tlsFiles) changed() bool {
+	for name, modTime := range f.modTimes {
+		info, err := os.Stat(name)
+		if err == nil && !info.ModTime().Equal(modTime) {
+			return true
+		}
+	}
+	return false
+}
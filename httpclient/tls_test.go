@@ -0,0 +1,262 @@
+package httpclient
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type testCert struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+	keyPEM  []byte
+}
+
+// newTestCert issues a certificate for localhost and 127.0.0.1 signed by
+// parent, or a self-signed CA when parent is nil
+func newTestCert(t *testing.T, name string, parent *testCert) *testCert {
+	t.Helper()
+	return newTestCertFor(t, name, parent, []string{"localhost"}, []net.IP{net.ParseIP("127.0.0.1")})
+}
+
+// newTestCertFor is newTestCert for the given DNS names and IP addresses
+func newTestCertFor(t *testing.T, name string, parent *testCert, dnsNames []string, ips []net.IP) *testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		DNSNames:     dnsNames,
+		IPAddresses:  ips,
+	}
+
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+
+	return &testCert{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+}
+
+func writeTestFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// setupMTLSServer starts a TLS server that requires a client certificate
+// signed by ca
+func setupMTLSServer(t *testing.T, ca, serverCert *testCert) *httptest.Server {
+	t.Helper()
+	pair, err := tls.X509KeyPair(serverCert.certPEM, serverCert.keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca.cert)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	server.EnableHTTP2 = false
+	server.TLS = &tls.Config{
+		Certificates: []tls.Certificate{pair},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+	}
+	server.StartTLS()
+	return server
+}
+
+func TestClientMutualTLS(t *testing.T) {
+	ca := newTestCert(t, "test-ca", nil)
+	server := setupMTLSServer(t, ca, newTestCert(t, "localhost", ca))
+	defer server.Close()
+
+	dir := t.TempDir()
+	clientCert := newTestCert(t, "client-a", ca)
+	writeTestFile(t, filepath.Join(dir, "ca.pem"), ca.certPEM)
+	writeTestFile(t, filepath.Join(dir, "client.pem"), clientCert.certPEM)
+	writeTestFile(t, filepath.Join(dir, "client-key.pem"), clientCert.keyPEM)
+
+	for _, backend := range []Backend{BackendStandard, BackendFastHTTP} {
+		t.Run(string(backend), func(t *testing.T) {
+			client, err := NewClient(backend, WithTLS(TLSOptions{
+				RootCAFile: filepath.Join(dir, "ca.pem"),
+				CertFile:   filepath.Join(dir, "client.pem"),
+				KeyFile:    filepath.Join(dir, "client-key.pem"),
+				PinnedSPKI: []string{SPKIPin(ca.cert)},
+			}))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			resp := client.Get(context.Background(), server.URL, nil)
+			if resp.Error != nil {
+				t.Fatal(resp.Error)
+			}
+			if string(resp.Body) != "client-a" {
+				t.Errorf("server saw client %q, want client-a", resp.Body)
+			}
+		})
+	}
+}
+
+func TestClientPinMismatch(t *testing.T) {
+	ca := newTestCert(t, "test-ca", nil)
+	server := setupMTLSServer(t, ca, newTestCert(t, "localhost", ca))
+	defer server.Close()
+
+	other := newTestCert(t, "other-ca", nil)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	for _, backend := range []Backend{BackendStandard, BackendFastHTTP} {
+		t.Run(string(backend), func(t *testing.T) {
+			client, err := NewClient(backend, WithTLS(TLSOptions{
+				RootCAs:    roots,
+				PinnedSPKI: []string{SPKIPin(other.cert)},
+			}))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			resp := client.Get(context.Background(), server.URL, nil)
+			if !errors.Is(resp.Error, ErrPinMismatch) {
+				t.Errorf("error = %v, want ErrPinMismatch", resp.Error)
+			}
+		})
+	}
+}
+
+func TestClientReloadsCertificates(t *testing.T) {
+	ca := newTestCert(t, "test-ca", nil)
+	server := setupMTLSServer(t, ca, newTestCert(t, "localhost", ca))
+	defer server.Close()
+
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.pem")
+	certFile := filepath.Join(dir, "client.pem")
+	keyFile := filepath.Join(dir, "client-key.pem")
+
+	// Start out trusting the wrong CA so the first request fails
+	first := newTestCert(t, "client-a", ca)
+	writeTestFile(t, caFile, newTestCert(t, "other-ca", nil).certPEM)
+	writeTestFile(t, certFile, first.certPEM)
+	writeTestFile(t, keyFile, first.keyPEM)
+
+	client, err := NewClient(BackendStandard, WithTLS(TLSOptions{
+		RootCAFile:     caFile,
+		CertFile:       certFile,
+		KeyFile:        keyFile,
+		ReloadInterval: time.Millisecond,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp := client.Get(context.Background(), server.URL, nil); resp.Error == nil {
+		t.Fatal("expected an unknown authority error before reload")
+	}
+
+	second := newTestCert(t, "client-b", ca)
+	writeTestFile(t, caFile, ca.certPEM)
+	writeTestFile(t, certFile, second.certPEM)
+	writeTestFile(t, keyFile, second.keyPEM)
+	future := time.Now().Add(time.Minute)
+	for _, name := range []string{caFile, certFile, keyFile} {
+		os.Chtimes(name, future, future)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	resp := client.Get(context.Background(), server.URL, nil)
+	if resp.Error != nil {
+		t.Fatal(resp.Error)
+	}
+	if string(resp.Body) != "client-b" {
+		t.Errorf("server saw client %q, want client-b", resp.Body)
+	}
+}
+
+func TestClientReloadedRootsCheckHost(t *testing.T) {
+	ca := newTestCert(t, "test-ca", nil)
+	// Signed by the trusted CA, but for another host and no IP addresses
+	server := setupMTLSServer(t, ca, newTestCertFor(t, "evil.example", ca, []string{"evil.example"}, nil))
+	defer server.Close()
+
+	dir := t.TempDir()
+	clientCert := newTestCert(t, "client-a", ca)
+	writeTestFile(t, filepath.Join(dir, "ca.pem"), ca.certPEM)
+	writeTestFile(t, filepath.Join(dir, "client.pem"), clientCert.certPEM)
+	writeTestFile(t, filepath.Join(dir, "client-key.pem"), clientCert.keyPEM)
+	opts := TLSOptions{
+		RootCAFile:     filepath.Join(dir, "ca.pem"),
+		CertFile:       filepath.Join(dir, "client.pem"),
+		KeyFile:        filepath.Join(dir, "client-key.pem"),
+		ReloadInterval: time.Second,
+	}
+
+	// server.URL dials 127.0.0.1, which the certificate does not cover
+	for _, backend := range []Backend{BackendStandard, BackendFastHTTP} {
+		t.Run(string(backend), func(t *testing.T) {
+			client, err := NewClient(backend, WithTLS(opts))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp := client.Get(context.Background(), server.URL, nil); resp.Error == nil {
+				t.Error("certificate for evil.example was accepted for 127.0.0.1")
+			}
+		})
+	}
+
+	t.Run("Config", func(t *testing.T) {
+		config, err := opts.Config()
+		if err != nil {
+			t.Fatal(err)
+		}
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: config}}
+		if resp, err := client.Get(server.URL); err == nil {
+			resp.Body.Close()
+			t.Error("certificate for evil.example was accepted for 127.0.0.1")
+		}
+	})
+}
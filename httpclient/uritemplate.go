@@ -0,0 +1,311 @@
+package httpclient
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// templateOperator is the expansion behaviour of an RFC 6570 expression
+// operator, from the table in appendix A of the RFC
+type templateOperator struct {
+	first string
+	sep   string
+	// named expansions prefix each value with its variable name
+	named bool
+	// ifEmpty follows the name of a named variable whose value is empty
+	ifEmpty string
+	// reserved lets reserved characters through unencoded
+	reserved bool
+}
+
+var templateOperators = map[byte]templateOperator{
+	0:   {first: "", sep: ","},
+	'+': {first: "", sep: ",", reserved: true},
+	'#': {first: "#", sep: ",", reserved: true},
+	'.': {first: ".", sep: "."},
+	'/': {first: "/", sep: "/"},
+	';': {first: ";", sep: ";", named: true},
+	'?': {first: "?", sep: "&", named: true, ifEmpty: "="},
+	'&': {first: "&", sep: "&", named: true, ifEmpty: "="},
+}
+
+// templateValue is a variable's value as RFC 6570 sees it: a string, a
+// list or an associative array
+type templateValue struct {
+	str   string
+	list  []string
+	pairs [][2]string
+}
+
+// expandTemplate expands the RFC 6570 URI template tmpl, up to level 4,
+// with vars. Variables that are missing or nil are undefined and expand to
+// nothing, as the RFC requires.
+func expandTemplate(tmpl string, vars map[string]any) (string, error) {
+	var out strings.Builder
+	for {
+		open := strings.IndexByte(tmpl, '{')
+		literal := tmpl
+		if open >= 0 {
+			literal = tmpl[:open]
+		}
+		if strings.IndexByte(literal, '}') >= 0 {
+			return "", fmt.Errorf("unmatched } in template")
+		}
+		if open < 0 {
+			out.WriteString(encodeTemplate(tmpl, true))
+			return out.String(), nil
+		}
+		end := strings.IndexByte(tmpl[open:], '}')
+		if end < 0 {
+			return "", fmt.Errorf("unclosed { in template")
+		}
+		out.WriteString(encodeTemplate(tmpl[:open], true))
+		if err := expandExpression(&out, tmpl[open+1:open+end], vars); err != nil {
+			return "", err
+		}
+		tmpl = tmpl[open+end+1:]
+	}
+}
+
+func expandExpression(out *strings.Builder, expr string, vars map[string]any) error {
+	if expr == "" {
+		return fmt.Errorf("empty expression in template")
+	}
+	op, ok := templateOperators[expr[0]]
+	if ok {
+		expr = expr[1:]
+	} else {
+		op = templateOperators[0]
+	}
+
+	first := true
+	for _, spec := range strings.Split(expr, ",") {
+		name, explode := strings.CutSuffix(spec, "*")
+		prefix := -1
+		if n, length, ok := strings.Cut(name, ":"); ok {
+			var err error
+			prefix, err = strconv.Atoi(length)
+			if err != nil || prefix <= 0 || prefix >= 10000 || explode {
+				return fmt.Errorf("bad prefix modifier in {%s}", spec)
+			}
+			name = n
+		}
+		if !validVarName(name) {
+			return fmt.Errorf("bad variable name %q in template", name)
+		}
+
+		value, defined, err := toTemplateValue(vars[name])
+		if err != nil {
+			return fmt.Errorf("variable %s: %w", name, err)
+		}
+		if !defined {
+			continue
+		}
+		if prefix >= 0 && (value.list != nil || value.pairs != nil) {
+			return fmt.Errorf("prefix modifier on composite variable %s", name)
+		}
+
+		if first {
+			out.WriteString(op.first)
+			first = false
+		} else {
+			out.WriteString(op.sep)
+		}
+		expandValue(out, op, name, value, explode, prefix)
+	}
+	return nil
+}
+
+func expandValue(out *strings.Builder, op templateOperator, name string, value templateValue, explode bool, prefix int) {
+	// named writes name and the separator before a value
+	named := func(name, v string) {
+		out.WriteString(name)
+		if v == "" {
+			out.WriteString(op.ifEmpty)
+		} else {
+			out.WriteByte('=')
+		}
+	}
+
+	switch {
+	case value.list == nil && value.pairs == nil:
+		s := value.str
+		if prefix > 0 && utf8.RuneCountInString(s) > prefix {
+			s = string([]rune(s)[:prefix])
+		}
+		if op.named {
+			named(name, s)
+		}
+		out.WriteString(encodeTemplate(s, op.reserved))
+
+	case !explode:
+		var items []string
+		if value.list != nil {
+			items = value.list
+		} else {
+			for _, pair := range value.pairs {
+				items = append(items, pair[0], pair[1])
+			}
+		}
+		// Empty composites are undefined, so there is always a value
+		if op.named {
+			out.WriteString(name + "=")
+		}
+		for i, item := range items {
+			if i > 0 {
+				out.WriteByte(',')
+			}
+			out.WriteString(encodeTemplate(item, op.reserved))
+		}
+
+	case value.list != nil:
+		for i, item := range value.list {
+			if i > 0 {
+				out.WriteString(op.sep)
+			}
+			if op.named {
+				named(name, item)
+			}
+			out.WriteString(encodeTemplate(item, op.reserved))
+		}
+
+	default:
+		for i, pair := range value.pairs {
+			if i > 0 {
+				out.WriteString(op.sep)
+			}
+			key := encodeTemplate(pair[0], op.reserved)
+			if op.named {
+				named(key, pair[1])
+			} else {
+				out.WriteString(key + "=")
+			}
+			out.WriteString(encodeTemplate(pair[1], op.reserved))
+		}
+	}
+}
+
+func validVarName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '.' || c == '%') {
+			return false
+		}
+	}
+	return true
+}
+
+// toTemplateValue converts v to a string, list or associative array.
+// Empty lists and maps are undefined, like nil.
+func toTemplateValue(v any) (templateValue, bool, error) {
+	if v == nil {
+		return templateValue{}, false, nil
+	}
+	if s, ok := formatScalar(v); ok {
+		return templateValue{str: s}, true, nil
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		if rv.Len() == 0 {
+			return templateValue{}, false, nil
+		}
+		list := make([]string, rv.Len())
+		for i := range list {
+			s, ok := formatScalar(rv.Index(i).Interface())
+			if !ok {
+				return templateValue{}, false, fmt.Errorf("unsupported list element %T", rv.Index(i).Interface())
+			}
+			list[i] = s
+		}
+		return templateValue{list: list}, true, nil
+
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return templateValue{}, false, fmt.Errorf("map keys must be strings, got %s", rv.Type().Key())
+		}
+		if rv.Len() == 0 {
+			return templateValue{}, false, nil
+		}
+		// Maps have no order, so keys are sorted to keep expansions stable
+		keys := rv.MapKeys()
+		slices.SortFunc(keys, func(a, b reflect.Value) int { return strings.Compare(a.String(), b.String()) })
+		pairs := make([][2]string, len(keys))
+		for i, key := range keys {
+			s, ok := formatScalar(rv.MapIndex(key).Interface())
+			if !ok {
+				return templateValue{}, false, fmt.Errorf("unsupported map value %T", rv.MapIndex(key).Interface())
+			}
+			pairs[i] = [2]string{key.String(), s}
+		}
+		return templateValue{pairs: pairs}, true, nil
+	}
+	return templateValue{}, false, fmt.Errorf("unsupported type %T", v)
+}
+
+// formatScalar formats the single values that can appear in URLs. It
+// reports false for other types, such as slices and maps.
+func formatScalar(v any) (string, bool) {
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case bool:
+		return strconv.FormatBool(v), true
+	case int:
+		return strconv.Itoa(v), true
+	case int8, int16, int32, int64:
+		return strconv.FormatInt(reflect.ValueOf(v).Int(), 10), true
+	case uint, uint8, uint16, uint32, uint64, uintptr:
+		return strconv.FormatUint(reflect.ValueOf(v).Uint(), 10), true
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32), true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case time.Time:
+		return v.Format(time.RFC3339), true
+	case time.Duration:
+		return v.String(), true
+	case fmt.Stringer:
+		return v.String(), true
+	}
+	return "", false
+}
+
+// encodeTemplate percent-encodes s as RFC 6570 does: unreserved characters
+// pass, and with reserved set so do reserved characters and existing
+// percent-encoded triplets
+func encodeTemplate(s string, reserved bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case isUnreserved(c):
+			b.WriteByte(c)
+		case reserved && strings.IndexByte(":/?#[]@!$&'()*+,;=", c) >= 0:
+			b.WriteByte(c)
+		case reserved && c == '%' && i+2 < len(s) && isHex(s[i+1]) && isHex(s[i+2]):
+			b.WriteString(s[i : i+3])
+			i += 2
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func isUnreserved(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '.' || c == '_' || c == '~'
+}
+
+func isHex(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
@@ -0,0 +1,94 @@
+package httpclient
+
+import "testing"
+
+func TestExpandTemplate(t *testing.T) {
+	// The variables and most cases are the examples of RFC 6570 section 3.2;
+	// map keys are expanded in sorted order
+	vars := map[string]any{
+		"var":   "value",
+		"hello": "Hello World!",
+		"path":  "/foo/bar",
+		"list":  []string{"red", "green", "blue"},
+		"keys":  map[string]string{"semi": ";", "dot": ".", "comma": ","},
+		"empty": "",
+		"x":     1024,
+		"y":     768,
+		"undef": nil,
+		"word":  "héllo",
+		"ids":   []int{3, 1},
+	}
+
+	tests := []struct {
+		template string
+		want     string
+	}{
+		{"{var}", "value"},
+		{"{hello}", "Hello%20World%21"},
+		{"{+path}/here", "/foo/bar/here"},
+		{"{+hello}", "Hello%20World!"},
+		{"{#hello}", "#Hello%20World!"},
+		{"{var:3}", "val"},
+		{"{var:30}", "value"},
+		{"{word:2}", "h%C3%A9"},
+		{"{list}", "red,green,blue"},
+		{"{list*}", "red,green,blue"},
+		{"{keys}", "comma,%2C,dot,.,semi,%3B"},
+		{"{keys*}", "comma=%2C,dot=.,semi=%3B"},
+		{"{+keys}", "comma,,,dot,.,semi,;"},
+		{"{x,y}", "1024,768"},
+		{"{x,hello,y}", "1024,Hello%20World%21,768"},
+		{"{.var}", ".value"},
+		{"{.list*}", ".red.green.blue"},
+		{"{/var,x}/here", "/value/1024/here"},
+		{"{/list*}", "/red/green/blue"},
+		{"{/list*,path:4}", "/red/green/blue/%2Ffoo"},
+		{"{;x,y}", ";x=1024;y=768"},
+		{"{;x,y,empty}", ";x=1024;y=768;empty"},
+		{"{;list*}", ";list=red;list=green;list=blue"},
+		{"{;keys*}", ";comma=%2C;dot=.;semi=%3B"},
+		{"{?x,y,empty}", "?x=1024&y=768&empty="},
+		{"{?list}", "?list=red,green,blue"},
+		{"{?list*}", "?list=red&list=green&list=blue"},
+		{"{?keys*}", "?comma=%2C&dot=.&semi=%3B"},
+		{"?fixed=yes{&x}", "?fixed=yes&x=1024"},
+		{"{?ids*}", "?ids=3&ids=1"},
+		{"/items{?undef}", "/items"},
+		{"{var,undef}", "value"},
+		{"{?missing,x}", "?x=1024"},
+		{"/a b", "/a%20b"},
+	}
+	for _, tt := range tests {
+		got, err := expandTemplate(tt.template, vars)
+		if err != nil {
+			t.Errorf("expandTemplate(%q): %v", tt.template, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("expandTemplate(%q) = %q, want %q", tt.template, got, tt.want)
+		}
+	}
+}
+
+func TestExpandTemplateErrors(t *testing.T) {
+	vars := map[string]any{
+		"var":    "value",
+		"list":   []string{"a"},
+		"struct": struct{}{},
+	}
+	for _, template := range []string{
+		"{var",
+		"var}",
+		"{}",
+		"{var:0}",
+		"{var:x}",
+		"{bad name}",
+		"{list:2}",
+		"{var*:3}",
+		"{struct}",
+	} {
+		if got, err := expandTemplate(template, vars); err == nil {
+			t.Errorf("expandTemplate(%q) = %q, want an error", template, got)
+		}
+	}
+}
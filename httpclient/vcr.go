@@ -0,0 +1,456 @@
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// VCRMode selects whether a cassette is replayed, recorded or both
+type VCRMode int
+
+const (
+	// VCRReplay answers every request from the cassette and fails requests
+	// it has no recording for with ErrNoRecording
+	VCRReplay VCRMode = iota
+	// VCRRecord sends every request and replaces the cassette's contents
+	// with what it records
+	VCRRecord
+	// VCRReplayOrRecord replays the requests the cassette has and records
+	// the others
+	VCRReplayOrRecord
+)
+
+// MatchFields selects which parts of a request must equal a recording for
+// it to be replayed
+type MatchFields int
+
+const (
+	MatchMethod MatchFields = 1 << iota
+	// MatchURL compares URLs with their query parameters in any order
+	MatchURL
+	MatchBody
+)
+
+// Redacted replaces secrets in recorded interactions
+const Redacted = "REDACTED"
+
+// defaultRedactedHeaders and defaultRedactedParams are always redacted
+var (
+	defaultRedactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Amz-Security-Token", "X-Api-Key"}
+	defaultRedactedParams  = []string{"X-Amz-Credential", "X-Amz-Signature", "X-Amz-Security-Token", "access_token", "api_key"}
+)
+
+// VCROptions configures WithVCR
+type VCROptions struct {
+	Mode VCRMode
+	// Match defaults to MatchMethod | MatchURL
+	Match MatchFields
+	// MatchHeaders lists request headers whose values must also match
+	MatchHeaders []string
+	// RedactHeaders and RedactParams name request and response headers and
+	// URL query parameters to redact in addition to common credentials
+	RedactHeaders []string
+	RedactParams  []string
+	// Redact edits an interaction before it is recorded, e.g. to scrub
+	// secrets from bodies. Requests are passed through it with an empty
+	// response before matching, so it must treat both the same way.
+	Redact func(*Interaction)
+}
+
+// ErrNoRecording is returned in VCRReplay mode for a request the cassette
+// has no interaction for
+var ErrNoRecording = errors.New("vcr: no recorded interaction matches the request")
+
+// Interaction is a recorded request and the response it received
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// RecordedRequest is the redacted form of a sent request
+type RecordedRequest struct {
+	Method  string            `json:"method"`
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    RecordedBody      `json:"body,omitempty"`
+}
+
+// RecordedResponse is the redacted form of a received response
+type RecordedResponse struct {
+	StatusCode int               `json:"status_code"`
+	Headers    map[string]string `json:"headers,omitempty"`
+	Body       RecordedBody      `json:"body,omitempty"`
+}
+
+// RecordedBody is stored as text when it is valid UTF-8 and as base64
+// otherwise
+type RecordedBody []byte
+
+// MarshalJSON implements json.Marshaler
+func (b RecordedBody) MarshalJSON() ([]byte, error) {
+	if utf8.Valid(b) {
+		return json.Marshal(string(b))
+	}
+	return json.Marshal(struct {
+		Base64 string `json:"base64"`
+	}{base64.StdEncoding.EncodeToString(b)})
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (b *RecordedBody) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		*b = RecordedBody(text)
+		return nil
+	}
+	var encoded struct {
+		Base64 string `json:"base64"`
+	}
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return err
+	}
+	decoded, err := base64.StdEncoding.DecodeString(encoded.Base64)
+	if err != nil {
+		return err
+	}
+	*b = decoded
+	return nil
+}
+
+// Cassette is a file of recorded interactions. It is saved after every
+// recorded interaction.
+type Cassette struct {
+	path string
+
+	mu           sync.Mutex
+	interactions []Interaction
+	used         []bool
+}
+
+type cassetteFile struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// LoadCassette reads the cassette at path. A missing file gives an empty
+// cassette to record into.
+func LoadCassette(path string) (*Cassette, error) {
+	cassette := &Cassette{path: path}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cassette, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading cassette: %w", err)
+	}
+
+	var file cassetteFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("error parsing cassette %s: %w", path, err)
+	}
+	cassette.interactions = file.Interactions
+	cassette.used = make([]bool, len(file.Interactions))
+	return cassette, nil
+}
+
+// Interactions returns a copy of the recorded interactions
+func (c *Cassette) Interactions() []Interaction {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Interaction(nil), c.interactions...)
+}
+
+// find returns the first unused interaction accepted by match, or the first
+// accepted one when all have been replayed, so that repeated requests get
+// their recordings in order
+func (c *Cassette) find(match func(RecordedRequest) bool) (Interaction, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	first := -1
+	for i, interaction := range c.interactions {
+		if !match(interaction.Request) {
+			continue
+		}
+		if !c.used[i] {
+			c.used[i] = true
+			return interaction, true
+		}
+		if first < 0 {
+			first = i
+		}
+	}
+	if first < 0 {
+		return Interaction{}, false
+	}
+	return c.interactions[first], true
+}
+
+func (c *Cassette) add(interaction Interaction) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.interactions = append(c.interactions, interaction)
+	c.used = append(c.used, true)
+	return c.save()
+}
+
+func (c *Cassette) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.interactions = nil
+	c.used = nil
+}
+
+// save writes the cassette through a temporary file so a crash never
+// leaves a truncated one
+func (c *Cassette) save() error {
+	data, err := json.MarshalIndent(cassetteFile{Interactions: c.interactions}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding cassette: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o755); err != nil {
+		return fmt.Errorf("error saving cassette: %w", err)
+	}
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("error saving cassette: %w", err)
+	}
+	if err := os.Rename(tmp, c.path); err != nil {
+		return fmt.Errorf("error saving cassette: %w", err)
+	}
+	return nil
+}
+
+// WithVCR records requests to cassette or replays them from it, depending on
+// opts.Mode. Redirect hops are recorded one by one, after signing, so
+// signatures are redacted like any other credential. Streamed responses are
+// recorded as far as they were read.
+func WithVCR(cassette *Cassette, opts VCROptions) Option {
+	return func(cfg *clientConfig) error {
+		if cassette == nil {
+			return fmt.Errorf("cassette must not be nil")
+		}
+		if opts.Match == 0 {
+			opts.Match = MatchMethod | MatchURL
+		}
+		cfg.vcr = &vcr{cassette: cassette, opts: opts}
+		return nil
+	}
+}
+
+type vcr struct {
+	cassette *Cassette
+	opts     VCROptions
+	// rewind empties the cassette before the first recording in
+	// VCRRecord mode, so other clients replaying it are undisturbed until
+	// this one actually records
+	rewind sync.Once
+}
+
+// do answers req from the cassette or sends it and records the result
+func (v *vcr) do(ctx context.Context, req *Request, send func(context.Context, *Request) HTTPResponse) HTTPResponse {
+	recorded := v.recordRequest(req)
+	if interaction, ok := v.replay(recorded); ok {
+		return HTTPResponse{
+			StatusCode: interaction.Response.StatusCode,
+			Body:       append([]byte(nil), interaction.Response.Body...),
+			Headers:    copyHeaders(interaction.Response.Headers),
+		}
+	}
+	if v.opts.Mode == VCRReplay {
+		return HTTPResponse{Error: fmt.Errorf("%w: %s %s", ErrNoRecording, recorded.Method, recorded.URL)}
+	}
+
+	resp := send(ctx, req)
+	if resp.Error != nil {
+		return resp
+	}
+	if err := v.record(recorded, resp.StatusCode, resp.Headers, resp.Body); err != nil {
+		resp.Error = err
+	}
+	return resp
+}
+
+// stream is do for streamed responses, which are recorded when their body is
+// closed
+func (v *vcr) stream(ctx context.Context, req *Request, body *bodyStream, send func(context.Context, *Request, *bodyStream) (*streamResponse, error)) (*streamResponse, error) {
+	recorded := v.recordRequest(req)
+	if interaction, ok := v.replay(recorded); ok {
+		return &streamResponse{
+			StatusCode: interaction.Response.StatusCode,
+			Headers:    copyHeaders(interaction.Response.Headers),
+			Body:       io.NopCloser(bytes.NewReader(interaction.Response.Body)),
+		}, nil
+	}
+	if v.opts.Mode == VCRReplay {
+		return nil, fmt.Errorf("%w: %s %s", ErrNoRecording, recorded.Method, recorded.URL)
+	}
+
+	resp, err := send(ctx, req, body)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = &recordingBody{ReadCloser: resp.Body, record: func(body []byte) error {
+		return v.record(recorded, resp.StatusCode, resp.Headers, body)
+	}}
+	return resp, nil
+}
+
+func (v *vcr) replay(req RecordedRequest) (Interaction, bool) {
+	if v.opts.Mode == VCRRecord {
+		return Interaction{}, false
+	}
+	if v.opts.Redact != nil {
+		interaction := Interaction{Request: req}
+		v.opts.Redact(&interaction)
+		req = interaction.Request
+	}
+	return v.cassette.find(func(recorded RecordedRequest) bool {
+		return v.matches(recorded, req)
+	})
+}
+
+func (v *vcr) record(req RecordedRequest, statusCode int, headers map[string]string, body []byte) error {
+	interaction := Interaction{
+		Request: req,
+		Response: RecordedResponse{
+			StatusCode: statusCode,
+			Headers:    v.redactHeaders(headers),
+			Body:       append(RecordedBody(nil), body...),
+		},
+	}
+	if v.opts.Redact != nil {
+		v.opts.Redact(&interaction)
+	}
+	if v.opts.Mode == VCRRecord {
+		v.rewind.Do(v.cassette.reset)
+	}
+	return v.cassette.add(interaction)
+}
+
+// recordRequest returns the redacted form of req
+func (v *vcr) recordRequest(req *Request) RecordedRequest {
+	return RecordedRequest{
+		Method:  req.Method,
+		URL:     v.redactURL(req.URL),
+		Headers: v.redactHeaders(req.Headers),
+		Body:    append(RecordedBody(nil), req.Body...),
+	}
+}
+
+func (v *vcr) matches(recorded, req RecordedRequest) bool {
+	if v.opts.Match&MatchMethod != 0 && recorded.Method != req.Method {
+		return false
+	}
+	if v.opts.Match&MatchURL != 0 && canonicalURL(recorded.URL) != canonicalURL(req.URL) {
+		return false
+	}
+	if v.opts.Match&MatchBody != 0 && !bytes.Equal(recorded.Body, req.Body) {
+		return false
+	}
+	for _, name := range v.opts.MatchHeaders {
+		want, wantOK := lookupHeader(recorded.Headers, name)
+		got, gotOK := lookupHeader(req.Headers, name)
+		if want != got || wantOK != gotOK {
+			return false
+		}
+	}
+	return true
+}
+
+func (v *vcr) redactHeaders(headers map[string]string) map[string]string {
+	if len(headers) == 0 {
+		return nil
+	}
+	redacted := copyHeaders(headers)
+	for key := range redacted {
+		if containsFold(defaultRedactedHeaders, key) || containsFold(v.opts.RedactHeaders, key) {
+			redacted[key] = Redacted
+		}
+	}
+	return redacted
+}
+
+func (v *vcr) redactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.RawQuery == "" {
+		return rawURL
+	}
+	query := u.Query()
+	changed := false
+	for key := range query {
+		if containsFold(defaultRedactedParams, key) || containsFold(v.opts.RedactParams, key) {
+			query[key] = []string{Redacted}
+			changed = true
+		}
+	}
+	if !changed {
+		return rawURL
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// canonicalURL sorts the query so that parameter order does not matter
+func canonicalURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	u.RawQuery = u.Query().Encode()
+	return u.String()
+}
+
+func containsFold(names []string, name string) bool {
+	for _, candidate := range names {
+		if strings.EqualFold(candidate, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func copyHeaders(headers map[string]string) map[string]string {
+	if headers == nil {
+		return nil
+	}
+	clone := make(map[string]string, len(headers))
+	for key, value := range headers {
+		clone[key] = value
+	}
+	return clone
+}
+
+// recordingBody keeps what is read from a streamed body and records it when
+// the body is closed
+type recordingBody struct {
+	io.ReadCloser
+	buf    bytes.Buffer
+	record func([]byte) error
+	once   sync.Once
+}
+
+func (b *recordingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.buf.Write(p[:n])
+	return n, err
+}
+
+func (b *recordingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(func() {
+		if recordErr := b.record(b.buf.Bytes()); err == nil {
+			err = recordErr
+		}
+	})
+	return err
+}
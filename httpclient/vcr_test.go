@@ -0,0 +1,308 @@
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// setupVCRTestServer answers /text with a cookie, /binary with bytes that
+// are not valid UTF-8 and /echo with the method, body and X-Tenant header it
+// received
+func setupVCRTestServer() *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/text", func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "server-secret"})
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"message":"hello"}`)
+	})
+	mux.HandleFunc("/binary", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte{0xff, 0xfe, 0x00, 0x01})
+	})
+	mux.HandleFunc("/echo", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		fmt.Fprintf(w, "%s %s tenant=%s", r.Method, body, r.Header.Get("X-Tenant"))
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestVCRRecordAndReplay(t *testing.T) {
+	for _, backend := range []Backend{BackendStandard, BackendFastHTTP} {
+		t.Run(string(backend), func(t *testing.T) {
+			server := setupVCRTestServer()
+			path := filepath.Join(t.TempDir(), "cassette.json")
+			headers := map[string]string{"Authorization": "Bearer client-secret"}
+
+			cassette, err := LoadCassette(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			client, err := NewClient(backend, WithVCR(cassette, VCROptions{Mode: VCRRecord}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var recorded []HTTPResponse
+			for _, target := range []string{"/text?api_key=query-secret", "/binary"} {
+				resp := client.Get(context.Background(), server.URL+target, headers)
+				if resp.Error != nil {
+					t.Fatal(resp.Error)
+				}
+				recorded = append(recorded, resp)
+			}
+			server.Close()
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, secret := range []string{"client-secret", "server-secret", "query-secret"} {
+				if bytes.Contains(data, []byte(secret)) {
+					t.Errorf("cassette contains %q:\n%s", secret, data)
+				}
+			}
+
+			// Replay on both backends with the server gone
+			for _, replayBackend := range []Backend{BackendStandard, BackendFastHTTP} {
+				cassette, err := LoadCassette(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				client, err := NewClient(replayBackend, WithVCR(cassette, VCROptions{Mode: VCRReplay}))
+				if err != nil {
+					t.Fatal(err)
+				}
+				for i, target := range []string{"/text?api_key=other-secret", "/binary"} {
+					resp := client.Get(context.Background(), server.URL+target, nil)
+					if resp.Error != nil {
+						t.Fatalf("%s replay of %s: %v", replayBackend, target, resp.Error)
+					}
+					if resp.StatusCode != recorded[i].StatusCode || !bytes.Equal(resp.Body, recorded[i].Body) {
+						t.Errorf("%s replay of %s = %d %q, want %d %q", replayBackend, target, resp.StatusCode, resp.Body, recorded[i].StatusCode, recorded[i].Body)
+					}
+				}
+				if v, _ := lookupHeader(client.Get(context.Background(), server.URL+"/text?api_key=x", nil).Headers, "Set-Cookie"); v != Redacted {
+					t.Errorf("replayed Set-Cookie = %q, want %q", v, Redacted)
+				}
+			}
+		})
+	}
+}
+
+func TestVCRRecordingClientLeavesReplayAlone(t *testing.T) {
+	server := setupVCRTestServer()
+	defer server.Close()
+	cassette, err := LoadCassette(filepath.Join(t.TempDir(), "cassette.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder, err := NewClient(BackendStandard, WithVCR(cassette, VCROptions{Mode: VCRRecord}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp := recorder.Get(context.Background(), server.URL+"/text", nil); resp.Error != nil {
+		t.Fatal(resp.Error)
+	}
+
+	// Building another recording client does not empty the cassette the
+	// replaying one reads; its first recording does
+	player, err := NewClient(BackendStandard, WithVCR(cassette, VCROptions{Mode: VCRReplay}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rerecorder, err := NewClient(BackendStandard, WithVCR(cassette, VCROptions{Mode: VCRRecord}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp := player.Get(context.Background(), server.URL+"/text", nil); resp.Error != nil {
+		t.Errorf("replay after building a recording client: %v", resp.Error)
+	}
+	if resp := rerecorder.Get(context.Background(), server.URL+"/binary", nil); resp.Error != nil {
+		t.Fatal(resp.Error)
+	}
+	interactions := cassette.Interactions()
+	if len(interactions) != 1 || !strings.HasSuffix(interactions[0].Request.URL, "/binary") {
+		t.Errorf("after re-recording the cassette holds %+v, want only /binary", interactions)
+	}
+}
+
+func TestVCRMatching(t *testing.T) {
+	server := setupVCRTestServer()
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		opts    VCROptions
+		body    string
+		tenant  string
+		want    string
+		wantErr error
+	}{
+		{"method and url", VCROptions{}, "b", "t2", "POST a tenant=t1", nil},
+		{"body", VCROptions{Match: MatchMethod | MatchURL | MatchBody}, "b", "t1", "POST b tenant=t1", nil},
+		{"header", VCROptions{MatchHeaders: []string{"x-tenant"}}, "a", "t2", "POST b tenant=t2", nil},
+		{"no match", VCROptions{Match: MatchMethod | MatchURL | MatchBody}, "c", "t1", "", ErrNoRecording},
+	}
+
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	cassette, err := LoadCassette(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := NewClient(BackendStandard, WithVCR(cassette, VCROptions{Mode: VCRRecord}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, sent := range []struct{ body, tenant string }{{"a", "t1"}, {"b", "t1"}, {"b", "t2"}} {
+		resp := client.Do(context.Background(), &Request{Method: http.MethodPost, URL: server.URL + "/echo", Headers: map[string]string{"X-Tenant": sent.tenant}, Body: []byte(sent.body)})
+		if resp.Error != nil {
+			t.Fatal(resp.Error)
+		}
+	}
+
+	for _, backend := range []Backend{BackendStandard, BackendFastHTTP} {
+		for _, tt := range tests {
+			t.Run(string(backend)+"/"+tt.name, func(t *testing.T) {
+				cassette, err := LoadCassette(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				tt.opts.Mode = VCRReplay
+				client, err := NewClient(backend, WithVCR(cassette, tt.opts))
+				if err != nil {
+					t.Fatal(err)
+				}
+				resp := client.Do(context.Background(), &Request{Method: http.MethodPost, URL: server.URL + "/echo", Headers: map[string]string{"X-Tenant": tt.tenant}, Body: []byte(tt.body)})
+				if tt.wantErr != nil {
+					if !errors.Is(resp.Error, tt.wantErr) {
+						t.Errorf("err = %v, want %v", resp.Error, tt.wantErr)
+					}
+					return
+				}
+				if resp.Error != nil {
+					t.Fatal(resp.Error)
+				}
+				if string(resp.Body) != tt.want {
+					t.Errorf("got %q, want %q", resp.Body, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func TestVCRReplayOrRecord(t *testing.T) {
+	server := setupVCRTestServer()
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	for _, backend := range []Backend{BackendStandard, BackendFastHTTP} {
+		cassette, err := LoadCassette(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		client, err := NewClient(backend, WithVCR(cassette, VCROptions{Mode: VCRReplayOrRecord}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, target := range []string{"/text", "/binary", "/text"} {
+			if resp := client.Get(context.Background(), server.URL+target, nil); resp.Error != nil {
+				t.Fatal(resp.Error)
+			}
+		}
+		// The repeated request and the second client's requests replay
+		if n := len(cassette.Interactions()); n != 2 {
+			t.Errorf("%s: cassette has %d interactions, want 2", backend, n)
+		}
+	}
+}
+
+func TestVCRRedactHook(t *testing.T) {
+	server := setupVCRTestServer()
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	opts := VCROptions{
+		Match: MatchMethod | MatchURL | MatchBody,
+		Redact: func(interaction *Interaction) {
+			interaction.Request.Body = RecordedBody(strings.ReplaceAll(string(interaction.Request.Body), "hunter2", Redacted))
+			interaction.Response.Body = RecordedBody(strings.ReplaceAll(string(interaction.Response.Body), "hunter2", Redacted))
+		},
+	}
+
+	for _, mode := range []VCRMode{VCRRecord, VCRReplay} {
+		cassette, err := LoadCassette(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		opts.Mode = mode
+		client, err := NewClient(BackendFastHTTP, WithVCR(cassette, opts))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp := client.Do(context.Background(), &Request{Method: http.MethodPost, URL: server.URL + "/echo", Body: []byte("password=hunter2")})
+		if resp.Error != nil {
+			t.Fatal(resp.Error)
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte("hunter2")) {
+		t.Errorf("cassette contains the password:\n%s", data)
+	}
+}
+
+func TestVCRStreamedDownload(t *testing.T) {
+	var mu sync.Mutex
+	var ranges []string
+	server := setupRangeTestServer(0, &ranges, &mu)
+
+	cassettePath := filepath.Join(t.TempDir(), "cassette.json")
+	cassette, err := LoadCassette(cassettePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := NewClient(BackendStandard, WithVCR(cassette, VCROptions{Mode: VCRRecord}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := &Request{Method: http.MethodGet, URL: server.URL + "/file.bin"}
+	if _, err := client.Download(context.Background(), req, filepath.Join(t.TempDir(), "recorded.bin"), DownloadOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	server.Close()
+
+	for _, backend := range []Backend{BackendStandard, BackendFastHTTP} {
+		t.Run(string(backend), func(t *testing.T) {
+			cassette, err := LoadCassette(cassettePath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			client, err := NewClient(backend, WithVCR(cassette, VCROptions{Mode: VCRReplay}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			path := filepath.Join(t.TempDir(), "replayed.bin")
+			if _, err := client.Download(context.Background(), req, path, DownloadOptions{Checksum: testFileChecksum()}); err != nil {
+				t.Fatal(err)
+			}
+			got, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, testFile) {
+				t.Errorf("replayed download differs from the file")
+			}
+		})
+	}
+}